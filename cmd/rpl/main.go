@@ -66,6 +66,49 @@ Case operations:
  # changes "SearchQuery" to "ReplaceValue"
  # changes "searchQuery" to "replaceValue"
 
+ # change all instances of the multi-word identifier "user_account" with
+ # "member_profile" in any naming convention, matching whole identifiers
+ # and words within identifiers only
+ #
+ # flags: --preserve-case (-P)
+
+ rpl -P "user_account" "member_profile" *
+ #
+ # changes "UserAccount" to "MemberProfile"
+ # changes "userAccount" to "memberProfile"
+ # changes "user-account" to "member-profile"
+ # changes "USER_ACCOUNT" to "MEMBER_PROFILE"
+ # changes "user.account" to "member.profile"
+
+ # spell acronyms and brand names canonically when preserving case, except
+ # where the match is all lower case or part of a SCREAMING_SNAKE_CASE name;
+ # set the dictionary once with: casing = ["GraphQL", "HTTPS"] in the config
+ #
+ # flags: --preserve-case (-P), --casing
+
+ rpl -P --casing GraphQL "rest" "graphql" *
+ #
+ # changes "REST" to "GraphQL"
+ # changes "RestClient" to "GraphQLClient"
+ # changes "rest" to "graphql"
+ # changes "REST_URL" to "GRAPHQL_URL"
+
+ # ignore case with full unicode case folding, where characters may fold
+ # into more than one character, or with the turkic dotted and dotless i;
+ # normalize the content to nfc or nfd before matching so that composed
+ # and decomposed characters match, replacements are written in the
+ # normalization of the file
+ #
+ # flags: --ignore-case (-i), --fold, --normalize
+
+ rpl -i --fold full --normalize nfc "straße" "road" *
+ #
+ # changes "STRASSE", "Straße" and "strasse" to "road"
+
+ rpl -i --fold turkic "istanbul" "Ankara" *
+ #
+ # changes "İstanbul" and "istanbul" but not "ISTANBUL" or "ıstanbul"
+
  # don't actually change all instances of "search" with "replace"
  #
  # flags: --ignore-case (-i), --nop (-n)
@@ -89,6 +132,10 @@ Interactive operations:
  # an additional option, Select, is added which allows the user to walk
  # through all the edit groups to pick and choose, similarly to how git
  # works with the "git add --patch ..." operation
+ #
+ # on terminals at least 120 columns wide, F6 toggles between the unified
+ # diff and a side-by-side layout of the before and after content, aligned
+ # by line and scrolled together; narrower terminals use the unified diff
 
 
 Backup operations:
@@ -116,6 +163,78 @@ Backup operations:
  # first backup filename: example.txt.bak
  # second backup filename: example.txt.1.bak
 
+ # backup and change all instances of "search" with "replace"; backup
+ # file names are derived from a template of placeholders: {name} is the
+ # original file name, {stem} is the name without the extension, {ext} is
+ # the extension (with the period), {dir} is the original directory, {n}
+ # is a counter starting at 1, {ts} is the time the run started and {run}
+ # is a unique identifier for the run; relative templates are relative to
+ # the original file's directory and any directories are created as needed;
+ # backups from earlier runs are not searched (unless --all is given), so
+ # the template must place backups in a directory or have some literal text
+ # in the file name to tell the backups apart from the original files
+ #
+ # flags: --ignore-case (-i), --backup-template
+
+ rpl -i --backup-template "{name}.{ts}.orig" "search" "replace" *
+ #
+ # first backup filename: example.txt.20240102T030405.orig
+ # second backup filename: example.txt.20240102T030405.orig.1
+
+ rpl -i --backup-template ".backups/{stem}.{n}{ext}" "search" "replace" *
+ #
+ # first backup filename: .backups/example.1.txt
+ # second backup filename: .backups/example.2.txt
+
+ # backup and change all instances of "search" with "replace"; the original
+ # files are copied into a directory named for the run, mirroring their
+ # relative paths, along with a .jsonl manifest beside it listing each
+ # backup; the backups of the run are never searched and an existing
+ # backup is never replaced
+ #
+ # flags: --ignore-case (-i), --recurse (-R), --backup-dir
+
+ rpl -iR --backup-dir /tmp/rpl-backups "search" "replace" .
+ #
+ # backup filename: /tmp/rpl-backups/20240102T030405-1234/src/example.txt
+ # manifest: /tmp/rpl-backups/20240102T030405-1234.jsonl
+ #
+ # restoring the entire run is a single copy back:
+ #
+ #   cp -a /tmp/rpl-backups/20240102T030405-1234/src .
+
+ # list the backups of all files in the current directory, showing each
+ # original file with its chain of backups, the generation (the first
+ # backup made is generation zero), size and age of each backup; use the
+ # same --backup-extension (-B), --backup-template or --backup-dir given
+ # when making the backups, any configured [backup] settings (and @preset)
+ # are applied the same as when searching and replacing
+ #
+ # flags: --list-backups, --recurse (-R)
+
+ rpl --list-backups -R .
+
+ # show the differences between the latest backup (or the --generation
+ # selected) and the current original file
+ #
+ # flags: --diff-backup, --generation (-g)
+
+ rpl --diff-backup -g 0 example.txt
+
+ # restore the original file from the latest backup (or the --generation
+ # selected); the current file is backed up first unless --no-backup
+ #
+ # flags: --restore-backup, --generation (-g)
+
+ rpl --restore-backup -g 0 example.txt
+
+ # remove all but the newest 2 backups of each file which are also older
+ # than 7 days (units of "d" for days and "w" for weeks are supported)
+ #
+ # flags: --prune-backups, --recurse (-R), --keep, --older-than
+
+ rpl --prune-backups -R --keep 2 --older-than 7d .
+
 
 Unified diff output:
 
@@ -140,6 +259,23 @@ Unified diff output:
 
  rpl -neRd "search" "replaced" . 2> /tmp/search-replaced.patch
 
+ # save the diff output as a patch which "git apply" accepts, with paths
+ # relative to the current directory
+ #
+ # flags: --nop (-n), --recurse (-R), --show-diff (-d), --patch-format
+
+ rpl -nRd --patch-format git "search" "replaced" . > /tmp/search-replaced.patch
+ git apply /tmp/search-replaced.patch
+
+ # apply a previously saved patch, possibly to another checkout; hunks are
+ # applied at the nearest matching lines and up to --fuzz context lines may
+ # be ignored, any hunks that fail are saved to a .rej file next to the
+ # original and backups are made as with any other replacement
+ #
+ # flags: --apply-patch, --fuzz, --backup (-b)
+
+ rpl -b --apply-patch /tmp/search-replaced.patch
+
 
 Regular Expression operations:
 
@@ -238,11 +374,210 @@ File selection operations:
  # replaces "search" with "replace" in all files that do not start with the
  # word "example" and also end with .txt or .md extensions
 
+ # search the members of tar and zip archives as if the archives were
+ # directories, members are named like "archive.zip!/path/in/archive"
+ # and the --include globs apply to the members; changed archives are
+ # rewritten with only the changed members replaced, keeping the order,
+ # modes and timestamps of all members, and backups are copies of the
+ # entire original archive
+ #
+ # flags: --recurse (-R), --include (-I), --backup (-b), --archives
+
+ rpl -Rb --archives -I "*.html" "search" "replace" templates/
+ #
+ # supported archives: .tar, .tar.gz, .tgz, .tar.bz2, .tar.xz, .zip, .jar
+
+
+Timeouts and cancellation:
+
+ # stop searching and replacing after the given duration, or when
+ # interrupted with Ctrl-C (or quitting the interactive user-interface);
+ # files are never partially written, each file is reported as it is
+ # changed and a report of how many files were finished and which were not
+ # is printed, a second Ctrl-C exits immediately; the timeout is not
+ # applied with --interactive, as reviewing the changes takes however long
+ # the user needs
+ #
+ # flags: --recurse (-R), --timeout
+
+ rpl -R --timeout 5m "search" "replace" .
+
+ # skip any file taking longer than the given duration to search or
+ # replace, such as a file on a hung network mount, skipped files are
+ # reported as unfinished
+ #
+ # flags: --recurse (-R), --file-timeout
+
+ rpl -R --file-timeout 10s "search" "replace" .
+
+
+Filter operations:
+
+ # replace within the content read from stdin and write the result to
+ # stdout, in the same manner as sed; all search modes work the same as
+ # with files and any --show-diff output is written to stderr
+ #
+ # flags: --regex (-r), --filter
+
+ cat input.txt | rpl --filter -r 'a(.)' 'b${1}' > output.txt
+
+ # preserve the case of each instance replaced and save the changes made
+ # as a patch
+ #
+ # flags: --preserve-case (-P), --show-diff (-d), --filter
+
+ rpl --filter -P -d "search" "replace" < in.txt > out.txt 2> changes.patch
+
+
+Multi-line snippets:
+
+ # replace a copy-pasted block of code regardless of its indentation and
+ # line wrapping, any run of whitespace in the search matches any run of
+ # whitespace in the files, including newlines; the search and replace
+ # values are read from files (less any one trailing newline) and are not
+ # given as arguments, so there is no shell escaping or regex quoting
+ #
+ # flags: --recurse (-R), --show-diff (-d), --loose-whitespace,
+ #        --search-file, --replace-file
+
+ rpl -Rd --loose-whitespace --search-file old.txt --replace-file new.txt .
+
+ # only the search value read from a file, the replace is an argument
+ #
+ # flags: --loose-whitespace, --search-file
+
+ rpl --loose-whitespace --search-file old.txt "" *.go
+
+
+Replacement variables:
+
+ # the replacement may use ${FILE}, ${BASENAME}, ${DIR}, ${LINE},
+ # ${MATCH_INDEX} (counting from 1 within each file) and ${GLOBAL_INDEX}
+ # (counting from 1 across all files, in the order they are replaced)
+ #
+ # flags: --recurse (-R)
+
+ rpl -R "TODO" 'TODO(${BASENAME}:${LINE})' .
+
+ # number the placeholders sequentially across a tree, with a prefix given
+ # by --define; defined names take precedence over any regex group of the
+ # same name and "$${NAME}" is written as a literal "${NAME}"
+ #
+ # flags: --recurse (-R), --define (-D)
+
+ rpl -R -D PREFIX=item "__ID__" '${PREFIX}-${GLOBAL_INDEX}' .
+
+
+Compressed files:
+
+ # search within gzip, bzip2 and xz compressed files, detected by their
+ # content rather than the file extension, and recompress any changes in
+ # the same format; the gzip and bzip2 compression levels are kept and
+ # backups are copies of the compressed originals
+ #
+ # flags: --regex (-r), --backup (-b), --show-diff (-d), --decompress
+
+ rpl -rbd --decompress "password=.*" "password=REDACTED" /var/log/app.log.*.gz
+
+Structured files:
+
+ # only replace within the scalar values found at the key paths given,
+ # leaving the keys, comments and formatting of the file untouched; key
+ # paths are dot-separated with "*" matching any one key (or array index)
+ # and "**" matching any number of keys, array indexes may also be given
+ # as "ports[0]"; files without a .json, .yaml, .yml or .toml extension
+ # use the only format given key paths, if there is just the one; quoted
+ # strings are unescaped before searching and replacements are escaped for
+ # the same quoting, a replacement which cannot be written within a literal
+ # string is an error
+ #
+ # flags: --recurse (-R), --include (-I), --show-diff (-d),
+ #        --json-path, --yaml-path, --toml-path
+
+ rpl -Rd -I "*.yml" --yaml-path "services.*.image" "nginx:1.25" "nginx:1.27" .
+ rpl -d --json-path "**.version" "1.2.3" "1.2.4" package.json
+
+Markup files:
+
+ # only replace within the text content of HTML and XML files, never
+ # within tags, comments or script and style elements; character
+ # references are decoded before searching and replacements are escaped,
+ # so searching for "&" finds "&amp;" and writes "&amp;" back
+ #
+ # flags: --recurse (-R), --include (-I), --markup
+
+ rpl -R -I "*.html" --markup text "Terms & Conditions" "Terms of Use" .
+
+ # only replace within the values of the named attributes (or of all
+ # attributes when no names are given), names may be glob patterns
+ #
+ # flags: --recurse (-R), --include (-I), --markup
+
+ rpl -R -I "*.html" --markup "attr:href,src" "http://" "https://" .
+
+Markdown files:
+
+ # only replace within the prose of markdown files, leaving fenced and
+ # indented code blocks and inline code spans untouched
+ #
+ # flags: --recurse (-R), --include (-I), --markdown
+
+ rpl -R -I "*.md" --markdown prose "color" "colour" docs
+
+ # only replace within the code blocks and code spans, or only within the
+ # fenced code blocks of the languages given
+ #
+ # flags: --recurse (-R), --include (-I), --markdown
+
+ rpl -R -I "*.md" --markdown code:go,sh "oldpkg" "newpkg" docs
+
+Configuration files:
+
+ # default flag values are read from the user config file, located at
+ # $XDG_CONFIG_HOME/rpl/config (or ~/.config/rpl/config), and from the
+ # first .rpl.toml project config file found by walking up from the current
+ # directory; both are TOML files with the long flag names as keys, project
+ # settings take precedence over user settings (lists are combined) and
+ # command-line flags always take precedence over both
+ #
+ #   recurse = true
+ #   exclude = ["*.log"]
+ #   max-file-size = "10MiB"
+ #
+ #   [backup]
+ #   extension = ".bak"    # also: enabled, template and dir
+ #
+ #   [exclude-sets]
+ #   deps = ["vendor/*", "*/vendor/*", "node_modules/*", "*/node_modules/*"]
+ #
+ #   [presets.rename-api]
+ #   regex = true
+ #   include = ["*.go"]
+
+ # use a named set of exclude globs
+ #
+ # flags: --exclude-set
+
+ rpl --exclude-set deps "search" "replace" .
+
+ # use a named preset of flag values, the leading "@" argument is only
+ # treated as a preset name when the preset is defined
+ #
+ # flags: (none), with leading @preset argument
+
+ rpl @rename-api "OldName" "NewName" .
+
+ # show which config files were loaded, or ignore them completely
+ #
+ # flags: --verbose (-v), --no-config
+
+ rpl -v "search" "replace" .
+ rpl --no-config "search" "replace" .
+
 Limitations:
 
-* maximum file size: ` + replace.MaxFileSizeLabel + `
-* maximum number of files: ` + humanize.Comma(int64(rpl.MaxFileCount)) + `
-* more than 10k changes per file can consume gigabytes of memory
+* default maximum file size: ` + replace.MaxFileSizeLabel + ` (see --max-file-size)
+* files are searched in batches of: ` + humanize.Comma(int64(rpl.MaxFileCount)) + ` (see --max-files)
 `
 )
 
@@ -250,12 +585,6 @@ var (
 	notifier = notify.New(notify.Info).Make()
 )
 
-func init() {
-	// set a very aggressive gc threshold to curb large-files with many
-	// matches from consuming all available memory
-	debug.SetGCPercent(10)
-}
-
 func main() {
 	if profileType := env.Get("GO_CDK_PROFILE", ""); profileType != "" {
 		var p func(p *profile.Profile)
@@ -3,7 +3,10 @@ module github.com/go-curses/coreutils-replace
 go 1.21.5
 
 require (
+	github.com/BurntSushi/toml v1.3.2
+	github.com/dsnet/compress v0.0.1
 	github.com/dustin/go-humanize v1.0.1
+	github.com/gabriel-vasile/mimetype v1.4.3
 	github.com/go-corelibs/chdirs v1.1.1
 	github.com/go-corelibs/cli v0.4.0
 	github.com/go-corelibs/diff v1.1.1
@@ -15,11 +18,17 @@ require (
 	github.com/go-corelibs/replace v1.3.2
 	github.com/go-corelibs/scanners v1.0.0
 	github.com/go-corelibs/slices v1.3.0
+	github.com/go-corelibs/strcases v1.0.0
 	github.com/go-curses/cdk v0.5.22
 	github.com/go-curses/ctk v0.5.13
+	github.com/hexops/gotextdiff v1.0.3
+	github.com/maruel/natural v1.1.1
 	github.com/pkg/profile v1.7.0
 	github.com/smartystreets/goconvey v1.8.1
+	github.com/ulikunitz/xz v0.5.12
 	github.com/urfave/cli/v2 v2.27.1
+	golang.org/x/text v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -29,24 +38,20 @@ require (
 	github.com/creack/pty v1.1.21 // indirect
 	github.com/djherbis/times v1.6.0 // indirect
 	github.com/felixge/fgprof v0.9.3 // indirect
-	github.com/gabriel-vasile/mimetype v1.4.3 // indirect
 	github.com/ganbarodigital/go_glob v1.0.0 // indirect
 	github.com/gdamore/encoding v1.0.0 // indirect
 	github.com/go-corelibs/maps v1.1.0 // indirect
 	github.com/go-corelibs/maths v1.0.1 // indirect
-	github.com/go-corelibs/strcases v1.0.0 // indirect
 	github.com/go-curses/term v1.2.2-gocurses.1 // indirect
 	github.com/go-curses/terminfo v1.1.0 // indirect
 	github.com/gofrs/uuid v4.4.0+incompatible // indirect
 	github.com/google/pprof v0.0.0-20211214055906-6f57359322fd // indirect
 	github.com/gopherjs/gopherjs v1.17.2 // indirect
-	github.com/hexops/gotextdiff v1.0.3 // indirect
 	github.com/iancoleman/strcase v0.3.0 // indirect
 	github.com/jackdoe/go-gpmctl v0.0.0-20231210204613-737e8a242925 // indirect
 	github.com/jtolds/gls v4.20.0+incompatible // indirect
 	github.com/jtolio/gls v4.20.0+incompatible // indirect
 	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
-	github.com/maruel/natural v1.1.1 // indirect
 	github.com/mattn/go-colorable v0.1.12 // indirect
 	github.com/mattn/go-isatty v0.0.14 // indirect
 	github.com/mattn/go-runewidth v0.0.15 // indirect
@@ -66,5 +71,4 @@ require (
 	golang.org/x/net v0.20.0 // indirect
 	golang.org/x/sys v0.16.0 // indirect
 	golang.org/x/term v0.16.0 // indirect
-	golang.org/x/text v0.14.0 // indirect
 )
@@ -0,0 +1,438 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	slashpath "path"
+	"strings"
+	"time"
+)
+
+const (
+	// ArchiveSeparator separates the archive path from the member path
+	// within the virtual paths of archive members, ie: "archive.zip!/a.txt"
+	ArchiveSeparator = "!/"
+)
+
+const (
+	gArchiveTar = "tar"
+	gArchiveZip = "zip"
+)
+
+// ArchiveExtensions is the list of file extensions treated as archives with
+// the Worker.Archives option, the content is still checked before use
+var ArchiveExtensions = []string{
+	".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tar.xz",
+	".zip", ".jar",
+}
+
+// IsArchiveName returns true if the file name given has one of the
+// ArchiveExtensions
+func IsArchiveName(name string) (archive bool) {
+	lower := strings.ToLower(name)
+	for _, ext := range ArchiveExtensions {
+		if archive = strings.HasSuffix(lower, ext); archive {
+			return
+		}
+	}
+	return
+}
+
+// SplitArchivePath returns the archive and member paths of the virtual path
+// given, ok is false when the file is not an archive member
+func SplitArchivePath(file string) (archive, member string, ok bool) {
+	if idx := strings.Index(file, ArchiveSeparator); idx > 0 {
+		archive, member = file[:idx], file[idx+len(ArchiveSeparator):]
+		ok = member != ""
+	}
+	return
+}
+
+// cArchive is an archive loaded into memory, the entries keep the order and
+// headers of the original so that it can be rewritten with only the changed
+// members replaced
+type cArchive struct {
+	format  string
+	codec   *cCompression
+	comment string
+	entries []*cArchiveEntry
+	lookup  map[string]*cArchiveEntry
+
+	// state of the archive file when loaded
+	size    int64
+	modTime time.Time
+	perm    fs.FileMode
+}
+
+type cArchiveEntry struct {
+	name    string
+	tar     *tar.Header
+	zip     *zip.File
+	data    []byte
+	loaded  bool
+	changed bool
+}
+
+func (e *cArchiveEntry) regular() (regular bool) {
+	if e.tar != nil {
+		regular = e.tar.Typeflag == tar.TypeReg || e.tar.Typeflag == tar.TypeRegA
+	} else {
+		regular = e.zip.Mode().IsRegular()
+	}
+	return
+}
+
+func (e *cArchiveEntry) load() (data []byte, err error) {
+	if !e.loaded {
+		var rc io.ReadCloser
+		if rc, err = e.zip.Open(); err != nil {
+			return
+		}
+		defer func() { _ = rc.Close() }()
+		if e.data, err = io.ReadAll(rc); err != nil {
+			return
+		}
+		e.loaded = true
+	}
+	data = e.data
+	return
+}
+
+// cArchiveEntryInfo is the fs.FileInfo of an archive member
+type cArchiveEntryInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i cArchiveEntryInfo) Name() string       { return i.name }
+func (i cArchiveEntryInfo) Size() int64        { return i.size }
+func (i cArchiveEntryInfo) Mode() fs.FileMode  { return i.mode }
+func (i cArchiveEntryInfo) ModTime() time.Time { return i.modTime }
+func (i cArchiveEntryInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i cArchiveEntryInfo) Sys() interface{}   { return nil }
+
+func (e *cArchiveEntry) info() (info cArchiveEntryInfo) {
+	info.name = slashpath.Base(e.name)
+	if e.tar != nil {
+		info.size, info.mode, info.modTime = e.tar.Size, e.tar.FileInfo().Mode(), e.tar.ModTime
+	} else {
+		info.size, info.mode, info.modTime = int64(e.zip.UncompressedSize64), e.zip.Mode(), e.zip.Modified
+		if info.modTime.IsZero() {
+			info.modTime = e.zip.ModTime()
+		}
+	}
+	if e.changed {
+		info.size = int64(len(e.data))
+	}
+	return
+}
+
+// cleanMemberName returns the member name without any leading "./" or "/"
+func cleanMemberName(name string) (clean string) {
+	if clean = strings.TrimLeft(slashpath.Clean("/"+name), "/"); clean == "" {
+		clean = "."
+	}
+	return
+}
+
+// parseArchive loads the archive data given, detecting the format from the
+// content and decompressing compressed tar archives
+func parseArchive(data []byte) (a *cArchive, err error) {
+	a = &cArchive{lookup: make(map[string]*cArchiveEntry)}
+
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) || bytes.HasPrefix(data, []byte("PK\x05\x06")) {
+		a.format = gArchiveZip
+		var zr *zip.Reader
+		if zr, err = zip.NewReader(bytes.NewReader(data), int64(len(data))); err != nil {
+			a = nil
+			return
+		}
+		a.comment = zr.Comment
+		for _, f := range zr.File {
+			a.add(&cArchiveEntry{name: f.Name, zip: f})
+		}
+		return
+	}
+
+	if a.codec = detectCompression(data); a.codec != nil {
+		if data, err = a.codec.decompress(data, 0); err != nil {
+			a = nil
+			return
+		}
+	}
+	if len(data) < 262 || string(data[257:262]) != "ustar" {
+		err = fmt.Errorf("not a tar or zip archive")
+		a = nil
+		return
+	}
+	a.format = gArchiveTar
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		var hdr *tar.Header
+		if hdr, err = tr.Next(); err == io.EOF {
+			err = nil
+			return
+		} else if err != nil {
+			a = nil
+			return
+		}
+		entry := &cArchiveEntry{name: hdr.Name, tar: hdr, loaded: true}
+		if entry.data, err = io.ReadAll(tr); err != nil {
+			a = nil
+			return
+		}
+		a.add(entry)
+	}
+}
+
+func (a *cArchive) add(entry *cArchiveEntry) {
+	a.entries = append(a.entries, entry)
+	if entry.regular() {
+		// the last entry of the same name takes precedence, as with tar
+		a.lookup[cleanMemberName(entry.name)] = entry
+	}
+}
+
+// members returns the clean names of all the regular file members, in the
+// order of the archive
+func (a *cArchive) members() (names []string) {
+	for _, entry := range a.entries {
+		name := cleanMemberName(entry.name)
+		if a.lookup[name] == entry {
+			names = append(names, name)
+		}
+	}
+	return
+}
+
+// encode renders the archive with all entries in their original order, the
+// unchanged zip entries are copied without recompressing them
+func (a *cArchive) encode() (data []byte, err error) {
+	var buf bytes.Buffer
+	switch a.format {
+
+	case gArchiveZip:
+		zw := zip.NewWriter(&buf)
+		for _, entry := range a.entries {
+			if !entry.changed {
+				if err = zw.Copy(entry.zip); err != nil {
+					return
+				}
+				continue
+			}
+			hdr := entry.zip.FileHeader
+			hdr.CRC32, hdr.CompressedSize, hdr.UncompressedSize = 0, 0, 0
+			hdr.CompressedSize64, hdr.UncompressedSize64 = 0, 0
+			// the zip64 and timestamp fields are written again as needed
+			hdr.Extra = stripZipExtra(hdr.Extra, 0x0001, 0x5455)
+			var o io.Writer
+			if o, err = zw.CreateHeader(&hdr); err != nil {
+				return
+			} else if _, err = o.Write(entry.data); err != nil {
+				return
+			}
+		}
+		if a.comment != "" {
+			if err = zw.SetComment(a.comment); err != nil {
+				return
+			}
+		}
+		if err = zw.Close(); err != nil {
+			return
+		}
+
+	case gArchiveTar:
+		tw := tar.NewWriter(&buf)
+		for _, entry := range a.entries {
+			hdr := *entry.tar
+			hdr.Size = int64(len(entry.data))
+			if err = tw.WriteHeader(&hdr); err != nil {
+				return
+			} else if _, err = tw.Write(entry.data); err != nil {
+				return
+			}
+		}
+		if err = tw.Close(); err != nil {
+			return
+		}
+	}
+
+	data = buf.Bytes()
+	if a.codec != nil {
+		data, err = a.codec.compress(data)
+	}
+	return
+}
+
+// stripZipExtra returns the zip extra fields given without any of the field
+// ids given
+func stripZipExtra(extra []byte, ids ...uint16) (stripped []byte) {
+	for len(extra) >= 4 {
+		id := uint16(extra[0]) | uint16(extra[1])<<8
+		size := int(uint16(extra[2])|uint16(extra[3])<<8) + 4
+		if size > len(extra) {
+			break
+		}
+		var skip bool
+		for _, other := range ids {
+			if skip = id == other; skip {
+				break
+			}
+		}
+		if !skip {
+			stripped = append(stripped, extra[:size]...)
+		}
+		extra = extra[size:]
+	}
+	return
+}
+
+// isArchive returns true if the Worker.Archives option is set and the file
+// given is named like an archive and is not itself an archive member
+func (w *Worker) isArchive(file string) (archive bool) {
+	if w.Archives {
+		if _, _, member := SplitArchivePath(file); !member {
+			archive = IsArchiveName(file)
+		}
+	}
+	return
+}
+
+// archiveMember returns the archive and member of the virtual path given,
+// only when the Worker.Archives option is set
+func (w *Worker) archiveMember(file string) (archive, member string, ok bool) {
+	if w.Archives {
+		archive, member, ok = SplitArchivePath(file)
+	}
+	return
+}
+
+// openArchive returns the loaded archive, reusing the last loaded state when
+// the archive file has not changed since
+func (w *Worker) openArchive(archive string) (a *cArchive, err error) {
+	var info fs.FileInfo
+	if info, err = w.stat(archive); err != nil {
+		return
+	}
+
+	w.archivesLock.Lock()
+	defer w.archivesLock.Unlock()
+	if cached, present := w.archives[archive]; present {
+		if cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+			a = cached
+			return
+		}
+	}
+
+	var data []byte
+	if data, err = w.readFile(archive); err != nil {
+		return
+	} else if a, err = parseArchive(data); err != nil {
+		err = fmt.Errorf("%q %w", archive, err)
+		return
+	}
+	a.size, a.modTime, a.perm = info.Size(), info.ModTime(), info.Mode().Perm()
+	if w.archives == nil {
+		w.archives = make(map[string]*cArchive)
+	}
+	w.archives[archive] = a
+	return
+}
+
+// listArchive returns the virtual paths of all the regular file members of
+// the archive given
+func (w *Worker) listArchive(archive string) (files []string, err error) {
+	var a *cArchive
+	if a, err = w.openArchive(archive); err != nil {
+		return
+	}
+	for _, name := range a.members() {
+		files = append(files, archive+ArchiveSeparator+name)
+	}
+	return
+}
+
+func (w *Worker) archiveEntry(archive, member string) (a *cArchive, entry *cArchiveEntry, err error) {
+	if a, err = w.openArchive(archive); err != nil {
+		return
+	}
+	var present bool
+	if entry, present = a.lookup[cleanMemberName(member)]; !present {
+		err = &fs.PathError{Op: "open", Path: archive + ArchiveSeparator + member, Err: fs.ErrNotExist}
+	}
+	return
+}
+
+func (w *Worker) statArchiveMember(archive, member string) (info fs.FileInfo, err error) {
+	var entry *cArchiveEntry
+	if _, entry, err = w.archiveEntry(archive, member); err == nil {
+		info = entry.info()
+	}
+	return
+}
+
+func (w *Worker) readArchiveMember(archive, member string) (data []byte, err error) {
+	var entry *cArchiveEntry
+	if _, entry, err = w.archiveEntry(archive, member); err != nil {
+		return
+	}
+	w.archivesLock.Lock()
+	defer w.archivesLock.Unlock()
+	var loaded []byte
+	if loaded, err = entry.load(); err == nil {
+		data = append([]byte{}, loaded...)
+	}
+	return
+}
+
+// writeArchiveMember replaces the content of the member and rewrites the
+// entire archive, keeping the order, modes and timestamps of all entries
+func (w *Worker) writeArchiveMember(archive, member string, data []byte) (err error) {
+	var a *cArchive
+	var entry *cArchiveEntry
+	if a, entry, err = w.archiveEntry(archive, member); err != nil {
+		return
+	}
+
+	w.archivesLock.Lock()
+	previous, changed := entry.data, entry.changed
+	entry.data, entry.changed, entry.loaded = append([]byte{}, data...), true, true
+	var encoded []byte
+	encoded, err = a.encode()
+	if err != nil {
+		entry.data, entry.changed = previous, changed
+	}
+	w.archivesLock.Unlock()
+	if err != nil {
+		return
+	}
+
+	if err = w.writeFile(archive, encoded, a.perm); err != nil {
+		return
+	}
+	// the archive is loaded again, as the zip entries copied are now stale
+	w.archivesLock.Lock()
+	delete(w.archives, archive)
+	w.archivesLock.Unlock()
+	return
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestArchives(t *testing.T) {
+	t.Parallel()
+
+	stamp := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	members := []struct {
+		name    string
+		content string
+		mode    fs.FileMode
+	}{
+		{"tpl/", "", fs.ModeDir | 0755},
+		{"tpl/a.html", "Hello OldCo\n", 0644},
+		{"tpl/b.txt", "no match\n", 0644},
+		{"c.txt", "OldCo again\n", 0600},
+	}
+
+	makeZip := func() (data string) {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for _, m := range members {
+			hdr := &zip.FileHeader{Name: m.name, Method: zip.Deflate, Modified: stamp}
+			hdr.SetMode(m.mode)
+			o, err := zw.CreateHeader(hdr)
+			So(err, ShouldBeNil)
+			_, _ = o.Write([]byte(m.content))
+		}
+		So(zw.Close(), ShouldBeNil)
+		data = buf.String()
+		return
+	}
+
+	makeTarGz := func() (data string) {
+		var buf bytes.Buffer
+		gz, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		tw := tar.NewWriter(gz)
+		for _, m := range members {
+			hdr := &tar.Header{Name: "./" + m.name, Mode: int64(m.mode.Perm()), ModTime: stamp, Typeflag: tar.TypeReg, Size: int64(len(m.content))}
+			if m.mode.IsDir() {
+				hdr.Typeflag = tar.TypeDir
+			}
+			So(tw.WriteHeader(hdr), ShouldBeNil)
+			_, _ = tw.Write([]byte(m.content))
+		}
+		So(tw.Close(), ShouldBeNil)
+		So(gz.Close(), ShouldBeNil)
+		data = buf.String()
+		return
+	}
+
+	replaceAll := func(m *MemFileSystem, options ...Option) (w *Worker, backups []string) {
+		var err error
+		w, err = New(append([]Option{
+			WithFileSystem(m),
+			WithQuiet(true),
+			WithSearch("OldCo", "NewCo"),
+			WithArchives(true),
+		}, options...)...)
+		So(err, ShouldBeNil)
+		So(w.InitTargets(nil), ShouldBeNil)
+		So(w.FindMatching(nil), ShouldBeNil)
+		for iter := w.StartIterating(); iter.Valid(); iter.Next() {
+			_, _, backup, ee := iter.ApplyAll()
+			So(ee, ShouldBeNil)
+			backups = append(backups, backup)
+		}
+		return
+	}
+
+	Convey("Virtual Paths", t, func() {
+		archive, member, ok := SplitArchivePath("/src/one.zip!/tpl/a.html")
+		So(ok, ShouldBeTrue)
+		So(archive, ShouldEqual, "/src/one.zip")
+		So(member, ShouldEqual, "tpl/a.html")
+		_, _, ok = SplitArchivePath("/src/one.zip")
+		So(ok, ShouldBeFalse)
+		So(IsArchiveName("bundle.TAR.GZ"), ShouldBeTrue)
+		So(IsArchiveName("notes.txt"), ShouldBeFalse)
+		So(cleanMemberName("./tpl/a.html"), ShouldEqual, "tpl/a.html")
+	})
+
+	Convey("Zip Archives", t, func() {
+		m := NewMemFileSystem(map[string]string{"/src/one.zip": makeZip()})
+		w, backups := replaceAll(m, WithPaths("/src/one.zip"), WithBackupExtension(".bak"))
+		So(w.Matched, ShouldEqual, []string{"/src/one.zip!/tpl/a.html", "/src/one.zip!/c.txt"})
+		So(w.FilesCount(), ShouldEqual, 3)
+		// the archive is only backed up once
+		So(backups, ShouldEqual, []string{"/src/one.zip.bak", "/src/one.zip.bak"})
+
+		data, _ := fs.ReadFile(m, "src/one.zip")
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		So(err, ShouldBeNil)
+		So(zr.File, ShouldHaveLength, len(members))
+		for idx, f := range zr.File {
+			So(f.Name, ShouldEqual, members[idx].name)
+			So(f.Mode(), ShouldEqual, members[idx].mode)
+			So(f.Modified.Equal(stamp), ShouldBeTrue)
+		}
+		rc, _ := zr.File[3].Open()
+		content, _ := io.ReadAll(rc)
+		So(string(content), ShouldEqual, "NewCo again\n")
+
+		data, _ = fs.ReadFile(m, "src/one.zip.bak")
+		So(string(data), ShouldEqual, makeZip())
+	})
+
+	Convey("Compressed Tar Archives", t, func() {
+		m := NewMemFileSystem(map[string]string{"/src/two.tar.gz": makeTarGz()})
+		w, _ := replaceAll(m, WithPaths("/src"), WithRecurse(true), WithInclude("*.html"))
+		So(w.Matched, ShouldEqual, []string{"/src/two.tar.gz!/tpl/a.html"})
+
+		data, _ := fs.ReadFile(m, "src/two.tar.gz")
+		c := detectCompression(data)
+		So(c, ShouldNotBeNil)
+		So(c.level, ShouldEqual, gzip.BestCompression)
+		data, _ = c.decompress(data, 0)
+		tr := tar.NewReader(bytes.NewReader(data))
+		for _, member := range members {
+			hdr, err := tr.Next()
+			So(err, ShouldBeNil)
+			So(hdr.Name, ShouldEqual, "./"+member.name)
+			So(hdr.ModTime.Equal(stamp), ShouldBeTrue)
+			So(hdr.FileInfo().Mode().Perm(), ShouldEqual, member.mode.Perm())
+			content, _ := io.ReadAll(tr)
+			if member.name == "tpl/a.html" {
+				So(string(content), ShouldEqual, "Hello NewCo\n")
+			} else {
+				So(string(content), ShouldEqual, member.content)
+			}
+		}
+	})
+
+	Convey("Without Archives", t, func() {
+		m := NewMemFileSystem(map[string]string{"/src/one.zip": makeZip()})
+		w, err := New(WithFileSystem(m), WithQuiet(true), WithSearch("OldCo", "NewCo"), WithPaths("/src/one.zip"))
+		So(err, ShouldBeNil)
+		So(w.InitTargets(nil), ShouldBeNil)
+		So(w.FindMatching(nil), ShouldBeNil)
+		So(w.Matched, ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// BackupManifestExtension is appended to the run directory name for the
+	// manifest file written beside each run directory within the --backup-dir,
+	// keeping the manifest outside the mirrored paths of the run
+	BackupManifestExtension = ".jsonl"
+)
+
+// BackupManifestEntry is a single line of a backup run manifest, recording
+// the original file and where its pre-image was copied to
+type BackupManifestEntry struct {
+	// Original is the absolute path of the file changed
+	Original string `json:"original"`
+	// Backup is the path of the copy, relative to the run directory
+	Backup string `json:"backup"`
+	// Size is the size of the original file before it was changed
+	Size int64 `json:"size"`
+	// Time is when the backup was made
+	Time time.Time `json:"time"`
+}
+
+// cBackupDir copies the pre-image of every file changed during a run into a
+// mirror of the directory structure, within a directory named for the run
+type cBackupDir struct {
+	w        *Worker
+	run      string // run directory, absolute
+	manifest string // run manifest, absolute
+	cwd      string
+	// done are the backups made during this run, keyed by original
+	done map[string]string
+
+	sync.Mutex
+}
+
+func newBackupDir(w *Worker, dir, runID string) (b *cBackupDir, err error) {
+	var abs string
+	if abs, err = w.abs(dir); err != nil {
+		return
+	} else if w.isFile(abs) {
+		err = fmt.Errorf("%q is not a directory", dir)
+		return
+	}
+	b = &cBackupDir{
+		w:        w,
+		run:      filepath.Join(abs, runID),
+		manifest: filepath.Join(abs, runID+BackupManifestExtension),
+		done:     make(map[string]string),
+	}
+	if b.cwd, err = w.getwd(); err != nil {
+		b = nil
+	}
+	return
+}
+
+// Contains returns true if the file given is the run manifest or is within
+// the run directory
+func (b *cBackupDir) Contains(file string) (contains bool) {
+	if abs, err := b.w.abs(file); err == nil {
+		contains = abs == b.run || abs == b.manifest || strings.HasPrefix(abs, b.run+string(filepath.Separator))
+	}
+	return
+}
+
+// Path returns the mirrored backup path for the target given, targets within
+// the current working directory keep their relative path and all others use
+// their absolute path
+func (b *cBackupDir) Path(target string) (backup string) {
+	abs, _ := b.w.abs(target)
+	rel, err := filepath.Rel(b.cwd, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		rel = strings.TrimPrefix(abs, filepath.VolumeName(abs))
+	}
+	backup = filepath.Join(b.run, rel)
+	return
+}
+
+// Backup copies the target to its mirrored backup path and appends it to the
+// run manifest. When the target was already backed up during this run, the
+// first copy is kept because it is the true pre-image of the run, otherwise
+// an existing backup path is an error
+func (b *cBackupDir) Backup(target string) (backup string, err error) {
+	original, _ := b.w.abs(target)
+
+	b.Lock()
+	defer b.Unlock()
+	if recorded, present := b.done[original]; present {
+		backup = recorded
+		return
+	}
+
+	backup = b.Path(target)
+	if b.w.exists(backup) {
+		err = fmt.Errorf("backup %q already exists", backup)
+		return
+	} else if err = b.w.mkdirAll(filepath.Dir(backup)); err != nil {
+		return
+	} else if err = b.w.copyFile(target, backup); err != nil {
+		return
+	}
+	b.done[original] = backup
+
+	entry := BackupManifestEntry{Original: original, Size: b.w.fileSize(target), Time: time.Now()}
+	entry.Backup, _ = filepath.Rel(b.run, backup)
+
+	var data []byte
+	if data, err = json.Marshal(entry); err != nil {
+		return
+	}
+	err = b.w.appendFile(b.manifest, append(data, '\n'), 0644)
+	return
+}
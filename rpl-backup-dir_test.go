@@ -0,0 +1,156 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBackupDir(t *testing.T) {
+	m := &sync.Mutex{}
+
+	Convey("Apply", t, func() {
+		m.Lock()
+		defer m.Unlock()
+		outio, errio, w := makeWorker()
+		defer outio.Restore()
+		defer errio.Restore()
+		dir := t.TempDir()
+		cwd, _ := os.Getwd()
+		So(os.Chdir(dir), ShouldEqual, nil)
+		defer func() { _ = os.Chdir(cwd) }()
+		So(os.MkdirAll(filepath.Join(dir, "src"), 0755), ShouldEqual, nil)
+		target := filepath.Join(dir, "src", "file.txt")
+		So(os.WriteFile(target, []byte("hello world\n"), 0644), ShouldEqual, nil)
+		w.Paths = []string{dir}
+		w.Recurse = true
+		w.Search = "hello"
+		w.Replace = "olleh"
+		w.Backup = true
+		w.BackupDir = filepath.Join(dir, "backups")
+		w.RunID = "test"
+		So(w.Init(), ShouldEqual, nil)
+		So(w.Exclude, ShouldHaveLength, 0)
+		So(w.BackupRunPath(), ShouldEqual, filepath.Join(dir, "backups", "test"))
+		So(w.InitTargets(nil), ShouldEqual, nil)
+		So(w.FindMatching(nil), ShouldEqual, nil)
+		So(w.Matched, ShouldEqual, []string{target})
+
+		expected := filepath.Join(dir, "backups", "test", "src", "file.txt")
+		iter := w.StartIterating()
+		_, _, backup, err := iter.ApplyAll()
+		So(err, ShouldEqual, nil)
+		So(backup, ShouldEqual, expected)
+		data, _ := os.ReadFile(expected)
+		So(string(data), ShouldEqual, "hello world\n")
+		So(w.BackupManifestPath(), ShouldEqual, filepath.Join(dir, "backups", "test.jsonl"))
+		data, _ = os.ReadFile(w.BackupManifestPath())
+		So(string(data), ShouldContainSubstring, `"backup":"src/file.txt"`)
+
+		// the backup directory is not searched
+		So(os.WriteFile(target, []byte("hello again\n"), 0644), ShouldEqual, nil)
+		So(w.FindMatching(nil), ShouldEqual, nil)
+		So(w.Matched, ShouldEqual, []string{target})
+
+		// the first pre-image of the run is kept
+		iter = w.StartIterating()
+		_, _, backup, err = iter.ApplyAll()
+		So(err, ShouldEqual, nil)
+		So(backup, ShouldEqual, expected)
+		data, _ = os.ReadFile(expected)
+		So(string(data), ShouldEqual, "hello world\n")
+		data, _ = os.ReadFile(w.BackupManifestPath())
+		So(strings.Count(string(data), "\n"), ShouldEqual, 1)
+
+		// backups from another run with the same id are not replaced
+		So(os.WriteFile(target, []byte("hello again\n"), 0644), ShouldEqual, nil)
+		_, _, w2 := makeWorker()
+		w2.Paths = []string{dir}
+		w2.Recurse = true
+		w2.Search = "hello"
+		w2.Replace = "olleh"
+		w2.Backup = true
+		w2.BackupDir = filepath.Join(dir, "backups")
+		w2.RunID = "test"
+		So(w2.Init(), ShouldEqual, nil)
+		So(w2.InitTargets(nil), ShouldEqual, nil)
+		So(w2.FindMatching(nil), ShouldEqual, nil)
+		So(w2.Matched, ShouldEqual, []string{target})
+		_, _, _, err = w2.StartIterating().ApplyAll()
+		So(err, ShouldNotEqual, nil)
+		data, _ = os.ReadFile(expected)
+		So(string(data), ShouldEqual, "hello world\n")
+		data, _ = os.ReadFile(target)
+		So(string(data), ShouldEqual, "hello again\n")
+	})
+
+	Convey("Manifest", t, func() {
+		m.Lock()
+		defer m.Unlock()
+		outio, errio, w := makeWorker()
+		defer outio.Restore()
+		defer errio.Restore()
+		dir := t.TempDir()
+		cwd, _ := os.Getwd()
+		So(os.Chdir(dir), ShouldEqual, nil)
+		defer func() { _ = os.Chdir(cwd) }()
+		So(os.MkdirAll(filepath.Join(dir, "src"), 0755), ShouldEqual, nil)
+		for _, name := range []string{"manifest.jsonl", "a.txt"} {
+			So(os.WriteFile(filepath.Join(dir, "src", name), []byte("hello\n"), 0644), ShouldEqual, nil)
+		}
+		So(os.Chdir(filepath.Join(dir, "src")), ShouldEqual, nil)
+		w.Paths = []string{filepath.Join(dir, "src", "manifest.jsonl"), filepath.Join(dir, "src", "a.txt")}
+		w.Search = "hello"
+		w.Replace = "world"
+		w.Backup = true
+		w.BackupDir = filepath.Join(dir, "bak")
+		w.RunID = "run"
+		So(w.Init(), ShouldEqual, nil)
+		So(w.InitTargets(nil), ShouldEqual, nil)
+		So(w.FindMatching(nil), ShouldEqual, nil)
+		So(w.Matched, ShouldEqual, w.Paths)
+		for iter := w.StartIterating(); iter.Valid(); iter.Next() {
+			_, _, _, err := iter.ApplyAll()
+			So(err, ShouldEqual, nil)
+		}
+
+		// a target named like a manifest is mirrored as-is
+		data, _ := os.ReadFile(filepath.Join(dir, "bak", "run", "manifest.jsonl"))
+		So(string(data), ShouldEqual, "hello\n")
+		data, _ = os.ReadFile(filepath.Join(dir, "bak", "run.jsonl"))
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		So(lines, ShouldHaveLength, 2)
+		So(lines[0], ShouldContainSubstring, `"backup":"manifest.jsonl"`)
+		So(lines[1], ShouldContainSubstring, `"backup":"a.txt"`)
+	})
+
+	Convey("Invalid", t, func() {
+		m.Lock()
+		defer m.Unlock()
+		outio, errio, w := makeWorker()
+		defer outio.Restore()
+		defer errio.Restore()
+		w.Search = "hello"
+		w.BackupDir = t.TempDir()
+		w.BackupTemplate = "{name}.bak"
+		So(w.Init(), ShouldNotEqual, nil)
+	})
+}
@@ -0,0 +1,336 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/go-corelibs/globs"
+	"github.com/go-corelibs/path"
+	"github.com/go-corelibs/scanners"
+)
+
+// BackupLookup finds the backups made with one of the backup naming schemes:
+// the --backup-extension (or default) naming, a --backup-template or a
+// --backup-dir
+type BackupLookup struct {
+	// Extension is the --backup-extension, empty for the default naming
+	Extension string
+	// Template is the --backup-template, if any
+	Template string
+	// Dir is the --backup-dir, if any
+	Dir string
+}
+
+// MakeBackupLookup returns the BackupLookup for the backup flags of the
+// cli.Context given, which should already have any configuration applied
+// (see ApplyConfig)
+func MakeBackupLookup(ctx *cli.Context) (l BackupLookup, err error) {
+	l = BackupLookup{
+		Extension: ctx.String(BackupExtensionFlag.Name),
+		Template:  ctx.String(BackupTemplateFlag.Name),
+		Dir:       ctx.String(BackupDirFlag.Name),
+	}
+	if l.Template != "" && l.Dir != "" {
+		err = fmt.Errorf("--%s cannot be used with --%s", BackupTemplateFlag.Name, BackupDirFlag.Name)
+	} else if l.Template != "" {
+		if _, err = ParseBackupTemplate(l.Template, "", time.Time{}); err != nil {
+			err = fmt.Errorf("--%s %w", BackupTemplateFlag.Name, err)
+		}
+	}
+	return
+}
+
+// Find looks for backups within the target given, grouped by their original
+// files, see FindBackups. Backups made with a template are only found for
+// original files which still exist
+func (l BackupLookup) Find(target string, recurse, all bool) (chains []*BackupChain, err error) {
+	switch {
+	case l.Dir != "":
+		chains, err = findBackupDirChains(l.Dir, target, recurse, all)
+	case l.Template != "":
+		chains, err = findBackupTemplateChains(l.Template, target, recurse, all)
+	default:
+		extension, separator := BackupNaming(l.Extension)
+		chains, err = FindBackups(target, recurse, all, extension, separator)
+	}
+	return
+}
+
+// FindFile returns the backup chain of the file given along with the backup
+// of the generation given, or the latest when negative. With the extension
+// naming, the file given can also be one of the backups, which is selected
+// when the generation is negative
+func (l BackupLookup) FindFile(file string, generation int) (chain *BackupChain, backup BackupFile, err error) {
+	var abs string
+	if abs, err = filepath.Abs(file); err != nil {
+		return
+	}
+	if l.Dir == "" && l.Template == "" {
+		extension, separator := BackupNaming(l.Extension)
+		if original, g, ok := ParseBackupName(abs, extension, separator); ok {
+			if abs = original; generation < 0 {
+				generation = g
+			}
+		}
+	}
+
+	var chains []*BackupChain
+	if chains, err = l.Find(abs, false, true); err != nil {
+		return
+	} else if len(chains) == 0 {
+		err = fmt.Errorf("%w: backups of %q", ErrNotFound, abs)
+		return
+	}
+	chain = chains[0]
+	backup, err = chain.Select(generation)
+	return
+}
+
+// Restore copies the backup given over the original file of the chain. When
+// keep is true, the current original is first backed up using the template,
+// or otherwise the extension naming, which is returned as the saved path
+func (l BackupLookup) Restore(chain *BackupChain, backup BackupFile, keep bool) (saved string, err error) {
+	if l.Template == "" {
+		extension, separator := BackupNaming(l.Extension)
+		saved, err = chain.Restore(backup, keep, extension, separator)
+		return
+	}
+	if keep && chain.Exists() {
+		now := time.Now()
+		var t *BackupTemplate
+		if t, err = ParseBackupTemplate(l.Template, fmt.Sprintf("%s-%d", now.Format(BackupTimestampFormat), os.Getpid()), now); err != nil {
+			return
+		}
+		saved = t.Next(chain.Original)
+		if err = os.MkdirAll(filepath.Dir(saved), 0755); err != nil {
+			saved = ""
+			return
+		} else if _, err = path.CopyFile(chain.Original, saved); err != nil {
+			saved = ""
+			return
+		}
+	}
+	_, err = path.CopyFile(backup.Path, chain.Original)
+	return
+}
+
+// walkBackupOriginals calls fn with each file within the target given, the
+// same as FindBackups walks the target
+func walkBackupOriginals(target string, recurse, all bool, fn func(file string)) (err error) {
+	if path.IsFile(target) {
+		fn(target)
+	} else if path.IsDir(target) {
+		err = filepath.WalkDir(target, func(file string, d fs.DirEntry, e error) error {
+			if e != nil {
+				return nil
+			} else if d.IsDir() {
+				if file != target && (!recurse || (!all && path.IsHidden(file))) {
+					return filepath.SkipDir
+				}
+				return nil
+			} else if all || !path.IsHidden(file) {
+				fn(file)
+			}
+			return nil
+		})
+	} else {
+		err = fmt.Errorf("%w: %q", ErrNotFound, target)
+	}
+	return
+}
+
+// sortBackupChains sorts the chains by original and the backups of each chain
+// by their modification times, numbering the generations in that order
+func sortBackupChains(chains []*BackupChain) {
+	sort.Slice(chains, func(i, j int) bool {
+		return chains[i].Original < chains[j].Original
+	})
+	for _, chain := range chains {
+		sort.SliceStable(chain.Backups, func(i, j int) bool {
+			return chain.Backups[i].ModTime.Before(chain.Backups[j].ModTime)
+		})
+		for idx := range chain.Backups {
+			chain.Backups[idx].Generation = idx
+		}
+	}
+}
+
+// backupTemplatePatterns returns the glob and regular expression matching
+// the backups of the original file given, made with the template given
+func backupTemplatePatterns(template, original string) (glob string, rx *regexp.Regexp, err error) {
+	dir, name := filepath.Split(original)
+	ext := filepath.Ext(name)
+	// the wildcard placeholders become private use runes until the literal
+	// text is quoted
+	const counter, timestamp, run = "\U00100000", "\U00100001", "\U00100002"
+	pattern := strings.NewReplacer(
+		"{name}", name,
+		"{stem}", strings.TrimSuffix(name, ext),
+		"{ext}", ext,
+		"{dir}", filepath.Clean(dir),
+		"{n}", counter,
+		"{ts}", timestamp,
+		"{run}", run,
+	).Replace(template)
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(dir, pattern)
+	}
+
+	glob = strings.NewReplacer(
+		counter, "[0-9]*",
+		timestamp, "*",
+		run, "*",
+	).Replace(globEscape(pattern))
+
+	expression := strings.NewReplacer(
+		counter, `[0-9]+`,
+		timestamp, `[0-9]{8}T[0-9]{6}`,
+		run, `[^/]+`,
+	).Replace(regexp.QuoteMeta(pattern))
+	if !strings.Contains(template, "{n}") {
+		expression += `(?:\.[0-9]+)?`
+	}
+	rx, err = regexp.Compile(`^` + expression + `$`)
+	return
+}
+
+// findBackupTemplateChains looks for the backups made with the template given
+// of the original files within the target
+func findBackupTemplateChains(template, target string, recurse, all bool) (chains []*BackupChain, err error) {
+	if target, err = filepath.Abs(target); err != nil {
+		return
+	}
+	var excluded globs.Globs
+	if excluded, err = globs.Parse(backupTemplateGlobs(template)...); err != nil {
+		return
+	}
+
+	err = walkBackupOriginals(target, recurse, all, func(original string) {
+		if excluded.Match(original) {
+			// this is one of the backups
+			return
+		}
+		glob, rx, e := backupTemplatePatterns(template, original)
+		if e != nil {
+			return
+		}
+		candidates, _ := filepath.Glob(glob)
+		if !strings.Contains(template, "{n}") {
+			more, _ := filepath.Glob(glob + ".[0-9]*")
+			candidates = append(candidates, more...)
+		}
+		chain := &BackupChain{Original: original}
+		for _, file := range candidates {
+			if !rx.MatchString(file) {
+				continue
+			} else if stat, ee := os.Stat(file); ee == nil && !stat.IsDir() {
+				chain.Backups = append(chain.Backups, BackupFile{
+					Path:    file,
+					Size:    stat.Size(),
+					ModTime: stat.ModTime(),
+				})
+			}
+		}
+		if len(chain.Backups) > 0 {
+			chains = append(chains, chain)
+		}
+	})
+
+	sortBackupChains(chains)
+	return
+}
+
+// findBackupDirChains looks for the backups of the original files within the
+// target, listed by the run manifests within the backup directory given
+func findBackupDirChains(dir, target string, recurse, all bool) (chains []*BackupChain, err error) {
+	if dir, err = filepath.Abs(dir); err != nil {
+		return
+	} else if target, err = filepath.Abs(target); err != nil {
+		return
+	}
+	isDir := path.IsDir(target)
+
+	within := func(original string) (ok bool) {
+		if original == target {
+			return true
+		} else if !isDir || !strings.HasPrefix(original, target+string(filepath.Separator)) {
+			return false
+		}
+		rel := strings.TrimPrefix(original, target+string(filepath.Separator))
+		parts := strings.Split(rel, string(filepath.Separator))
+		if !recurse && len(parts) > 1 {
+			return false
+		}
+		for _, part := range parts {
+			if !all && strings.HasPrefix(part, ".") {
+				return false
+			}
+		}
+		return true
+	}
+
+	var manifests []string
+	if manifests, err = filepath.Glob(filepath.Join(globEscape(dir), "*"+BackupManifestExtension)); err != nil {
+		return
+	}
+
+	lookup := make(map[string]*BackupChain)
+	for _, manifest := range manifests {
+		run := strings.TrimSuffix(manifest, BackupManifestExtension)
+		fh, e := os.Open(manifest)
+		if e != nil {
+			continue
+		}
+		scanners.ScanLines(fh, func(line string) (stop bool) {
+			var entry BackupManifestEntry
+			if e := json.Unmarshal([]byte(line), &entry); e != nil || !within(entry.Original) {
+				return
+			}
+			backup := filepath.Join(run, entry.Backup)
+			stat, e := os.Stat(backup)
+			if e != nil {
+				// pruned or removed
+				return
+			}
+			chain, present := lookup[entry.Original]
+			if !present {
+				chain = &BackupChain{Original: entry.Original}
+				lookup[entry.Original] = chain
+				chains = append(chains, chain)
+			}
+			chain.Backups = append(chain.Backups, BackupFile{
+				Path:    backup,
+				Size:    stat.Size(),
+				ModTime: entry.Time,
+			})
+			return
+		})
+		_ = fh.Close()
+	}
+
+	sortBackupChains(chains)
+	return
+}
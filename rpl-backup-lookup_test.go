@@ -0,0 +1,209 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBackupLookup(t *testing.T) {
+	m := &sync.Mutex{}
+
+	// makeProject changes to a new directory containing c.txt
+	makeProject := func() (dir string, restore func()) {
+		dir = t.TempDir()
+		So(os.WriteFile(filepath.Join(dir, "c.txt"), []byte("hello world\n"), 0644), ShouldEqual, nil)
+		cwd, _ := os.Getwd()
+		So(os.Chdir(dir), ShouldEqual, nil)
+		restore = func() { _ = os.Chdir(cwd) }
+		return
+	}
+
+	// backupAll makes a backup of c.txt while replacing its content
+	backupAll := func(dir, template, backupDir, runID string) {
+		outio, errio, w := makeWorker()
+		defer outio.Restore()
+		defer errio.Restore()
+		w.Paths = []string{filepath.Join(dir, "c.txt")}
+		w.Search = "hello"
+		w.Replace = "olleh"
+		w.Backup = true
+		w.BackupTemplate = template
+		w.BackupDir = backupDir
+		w.RunID = runID
+		So(w.Init(), ShouldEqual, nil)
+		So(w.InitTargets(nil), ShouldEqual, nil)
+		So(w.FindMatching(nil), ShouldEqual, nil)
+		_, _, _, err := w.StartIterating().ApplyAll()
+		So(err, ShouldEqual, nil)
+	}
+
+	Convey("Extension", t, func() {
+		m.Lock()
+		defer m.Unlock()
+		dir, restore := makeProject()
+		defer restore()
+		So(os.WriteFile(filepath.Join(dir, "c.txt.bak"), []byte("hello world\n"), 0644), ShouldEqual, nil)
+		So(os.WriteFile(filepath.Join(dir, "c.txt~"), []byte("hello\n"), 0644), ShouldEqual, nil)
+
+		lookup := BackupLookup{Extension: ".bak"}
+		chains, err := lookup.Find(".", false, false)
+		So(err, ShouldEqual, nil)
+		So(chains, ShouldHaveLength, 1)
+		So(chains[0].Original, ShouldEqual, filepath.Join(dir, "c.txt"))
+		So(chains[0].Backups, ShouldHaveLength, 1)
+		So(chains[0].Backups[0].Path, ShouldEqual, filepath.Join(dir, "c.txt.bak"))
+
+		// the backup file itself selects the chain
+		chain, backup, err := lookup.FindFile("c.txt.bak", -1)
+		So(err, ShouldEqual, nil)
+		So(chain.Original, ShouldEqual, filepath.Join(dir, "c.txt"))
+		So(backup.Path, ShouldEqual, filepath.Join(dir, "c.txt.bak"))
+
+		// the default naming
+		chains, err = BackupLookup{}.Find(".", false, false)
+		So(err, ShouldEqual, nil)
+		So(chains, ShouldHaveLength, 1)
+		So(chains[0].Backups, ShouldHaveLength, 1)
+		So(chains[0].Backups[0].Path, ShouldEqual, filepath.Join(dir, "c.txt~"))
+	})
+
+	Convey("Template", t, func() {
+		m.Lock()
+		defer m.Unlock()
+		dir, restore := makeProject()
+		defer restore()
+
+		lookup := BackupLookup{Template: "bak/{stem}.{n}{ext}"}
+		backupAll(dir, lookup.Template, "", "")
+		So(os.WriteFile(filepath.Join(dir, "c.txt"), []byte("hello again\n"), 0644), ShouldEqual, nil)
+		backupAll(dir, lookup.Template, "", "")
+
+		chains, err := lookup.Find(".", true, false)
+		So(err, ShouldEqual, nil)
+		So(chains, ShouldHaveLength, 1)
+		So(chains[0].Original, ShouldEqual, filepath.Join(dir, "c.txt"))
+		So(chains[0].Backups, ShouldHaveLength, 2)
+		So(chains[0].Backups[0].Path, ShouldEqual, filepath.Join(dir, "bak", "c.1.txt"))
+		So(chains[0].Backups[1].Path, ShouldEqual, filepath.Join(dir, "bak", "c.2.txt"))
+
+		chain, backup, err := lookup.FindFile("c.txt", 0)
+		So(err, ShouldEqual, nil)
+		saved, err := lookup.Restore(chain, backup, true)
+		So(err, ShouldEqual, nil)
+		So(saved, ShouldEqual, filepath.Join(dir, "bak", "c.3.txt"))
+		data, _ := os.ReadFile(filepath.Join(dir, "c.txt"))
+		So(string(data), ShouldEqual, "hello world\n")
+		data, _ = os.ReadFile(saved)
+		So(string(data), ShouldEqual, "olleh again\n")
+	})
+
+	Convey("Dir", t, func() {
+		m.Lock()
+		defer m.Unlock()
+		dir, restore := makeProject()
+		defer restore()
+
+		lookup := BackupLookup{Dir: filepath.Join(dir, ".backups")}
+		backupAll(dir, "", lookup.Dir, "first")
+		So(os.WriteFile(filepath.Join(dir, "c.txt"), []byte("hello again\n"), 0644), ShouldEqual, nil)
+		backupAll(dir, "", lookup.Dir, "second")
+
+		chains, err := lookup.Find(".", false, false)
+		So(err, ShouldEqual, nil)
+		So(chains, ShouldHaveLength, 1)
+		So(chains[0].Original, ShouldEqual, filepath.Join(dir, "c.txt"))
+		So(chains[0].Backups, ShouldHaveLength, 2)
+		So(chains[0].Backups[0].Path, ShouldEqual, filepath.Join(dir, ".backups", "first", "c.txt"))
+		So(chains[0].Backups[1].Path, ShouldEqual, filepath.Join(dir, ".backups", "second", "c.txt"))
+
+		chain, backup, err := lookup.FindFile("c.txt", -1)
+		So(err, ShouldEqual, nil)
+		So(chain.Original, ShouldEqual, filepath.Join(dir, "c.txt"))
+		So(backup.Path, ShouldEqual, filepath.Join(dir, ".backups", "second", "c.txt"))
+	})
+
+	Convey("Configured", t, func() {
+		m.Lock()
+		defer m.Unlock()
+		dir, restore := makeProject()
+		defer restore()
+		t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+		configure := func(config string) {
+			So(os.WriteFile(filepath.Join(dir, ProjectConfigFileName), []byte(config), 0644), ShouldEqual, nil)
+		}
+		configure("[backup]\nextension = \".bak\"\n\n[presets.old]\nbackup-extension = \".old\"\n")
+		So(os.WriteFile(filepath.Join(dir, "c.txt.bak"), []byte("hello world\n"), 0644), ShouldEqual, nil)
+
+		ctx := makeConfigContext(".")
+		_, _, args, err := ApplyConfig(ctx)
+		So(err, ShouldEqual, nil)
+		So(args, ShouldEqual, []string{"."})
+		lookup, err := MakeBackupLookup(ctx)
+		So(err, ShouldEqual, nil)
+		So(lookup, ShouldEqual, BackupLookup{Extension: ".bak"})
+		chains, err := lookup.Find(args[0], false, false)
+		So(err, ShouldEqual, nil)
+		So(chains, ShouldHaveLength, 1)
+		So(chains[0].Backups[0].Path, ShouldEqual, filepath.Join(dir, "c.txt.bak"))
+
+		// presets are applied
+		ctx = makeConfigContext("@old", ".")
+		_, preset, args, err := ApplyConfig(ctx)
+		So(err, ShouldEqual, nil)
+		So(preset, ShouldEqual, "old")
+		So(args, ShouldEqual, []string{"."})
+		lookup, err = MakeBackupLookup(ctx)
+		So(err, ShouldEqual, nil)
+		So(lookup.Extension, ShouldEqual, ".old")
+
+		// --no-config is the default naming
+		ctx = makeConfigContext("--no-config", ".")
+		_, _, _, err = ApplyConfig(ctx)
+		So(err, ShouldEqual, nil)
+		lookup, err = MakeBackupLookup(ctx)
+		So(err, ShouldEqual, nil)
+		chains, err = lookup.Find(".", false, false)
+		So(err, ShouldEqual, nil)
+		So(chains, ShouldHaveLength, 0)
+
+		configure("[backup]\ntemplate = \"bak/{stem}.{n}{ext}\"\n")
+		ctx = makeConfigContext()
+		_, _, _, err = ApplyConfig(ctx)
+		So(err, ShouldEqual, nil)
+		lookup, err = MakeBackupLookup(ctx)
+		So(err, ShouldEqual, nil)
+		So(lookup, ShouldEqual, BackupLookup{Template: "bak/{stem}.{n}{ext}"})
+
+		configure("[backup]\ndir = \".backups\"\n")
+		ctx = makeConfigContext()
+		_, _, _, err = ApplyConfig(ctx)
+		So(err, ShouldEqual, nil)
+		lookup, err = MakeBackupLookup(ctx)
+		So(err, ShouldEqual, nil)
+		So(lookup, ShouldEqual, BackupLookup{Dir: filepath.Join(dir, ".backups")})
+
+		ctx = makeConfigContext("--backup-template", "{name}.orig")
+		_, _, _, err = ApplyConfig(ctx)
+		So(err, ShouldEqual, nil)
+		_, err = MakeBackupLookup(ctx)
+		So(err, ShouldNotEqual, nil)
+	})
+}
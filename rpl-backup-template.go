@@ -0,0 +1,185 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-corelibs/path"
+)
+
+const (
+	// BackupTimestampFormat is the time format used for the {ts} placeholder
+	BackupTimestampFormat = "20060102T150405"
+)
+
+// BackupPlaceholders are the placeholders supported by BackupTemplate
+var BackupPlaceholders = []string{
+	"{name}", // basename of the original file, ie: "file.txt"
+	"{stem}", // basename without the extension, ie: "file"
+	"{ext}",  // extension including the period, ie: ".txt"
+	"{dir}",  // directory of the original file
+	"{n}",    // counter, starting at 1, incremented until the name is unused
+	"{ts}",   // timestamp of when the run started
+	"{run}",  // unique identifier of the run
+}
+
+// BackupTemplate derives backup file names from a template of placeholders.
+// Relative templates are relative to the directory of the original file and
+// templates containing directories place backups within those directories
+type BackupTemplate struct {
+	template string
+	runID    string
+	started  time.Time
+}
+
+// ParseBackupTemplate validates the template given and constructs a new
+// BackupTemplate instance for the run identified by runID
+func ParseBackupTemplate(template, runID string, started time.Time) (t *BackupTemplate, err error) {
+	if strings.TrimSpace(template) == "" {
+		err = fmt.Errorf("empty template")
+		return
+	}
+
+	for rest := template; rest != ""; {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			err = fmt.Errorf("unterminated placeholder in %q", template)
+			return
+		}
+		placeholder := rest[start : start+end+1]
+		var valid bool
+		for _, known := range BackupPlaceholders {
+			if valid = placeholder == known; valid {
+				break
+			}
+		}
+		if !valid {
+			err = fmt.Errorf("unknown placeholder %q in %q", placeholder, template)
+			return
+		}
+		rest = rest[start+end+1:]
+	}
+
+	if clean := filepath.Clean(template); clean == "{name}" || clean == "{stem}{ext}" {
+		err = fmt.Errorf("template %q would overwrite the original file", template)
+		return
+	}
+
+	if len(backupTemplateGlobs(template)) == 0 {
+		err = fmt.Errorf("template %q cannot be told apart from the original files", template)
+		return
+	}
+
+	t = &BackupTemplate{
+		template: template,
+		runID:    runID,
+		started:  started,
+	}
+	return
+}
+
+// String returns the template text
+func (t *BackupTemplate) String() (template string) {
+	template = t.template
+	return
+}
+
+// Expand returns the backup file name for the target and counter given,
+// without checking if the backup file exists
+func (t *BackupTemplate) Expand(target string, counter int) (backup string) {
+	dir, name := filepath.Split(target)
+	ext := filepath.Ext(name)
+	backup = strings.NewReplacer(
+		"{name}", name,
+		"{stem}", strings.TrimSuffix(name, ext),
+		"{ext}", ext,
+		"{dir}", filepath.Clean(dir),
+		"{n}", strconv.Itoa(counter),
+		"{ts}", t.started.Format(BackupTimestampFormat),
+		"{run}", t.runID,
+	).Replace(t.template)
+	if !filepath.IsAbs(backup) {
+		backup = filepath.Join(dir, backup)
+	}
+	return
+}
+
+// Next returns the first backup file name for the target that does not exist.
+// When the template has no {n} placeholder and the name is already in use, a
+// period and the counter is appended
+func (t *BackupTemplate) Next(target string) (backup string) {
+	backup = t.next(target, path.Exists)
+	return
+}
+
+// next is Next using the exists func given to check for existing backups
+func (t *BackupTemplate) next(target string, exists func(file string) bool) (backup string) {
+	counted := strings.Contains(t.template, "{n}")
+	for counter := 1; ; counter++ {
+		if counted {
+			backup = t.Expand(target, counter)
+		} else if backup = t.Expand(target, 0); counter > 1 {
+			backup += "." + strconv.Itoa(counter-1)
+		}
+		if !exists(backup) {
+			return
+		}
+	}
+}
+
+// Globs returns the glob patterns matching backups made with this template,
+// any directory the template places backups within is matched entirely and
+// otherwise the basename of the backups is matched, including the counter
+// suffix added when the template has no {n} placeholder
+func (t *BackupTemplate) Globs() (patterns []string) {
+	patterns = backupTemplateGlobs(t.template)
+	return
+}
+
+// backupTemplateGlobs returns the glob patterns for the template given, which
+// are empty only when the backups cannot be told apart from other files
+func backupTemplateGlobs(template string) (patterns []string) {
+	wildcards := func(input string) (glob string) {
+		glob = input
+		for _, placeholder := range BackupPlaceholders {
+			glob = strings.ReplaceAll(glob, placeholder, "*")
+		}
+		return
+	}
+
+	dir, name := filepath.Split(template)
+	if dir = filepath.Clean(dir); dir != "." {
+		dir = wildcards(dir)
+		if patterns = append(patterns, dir+"/*"); !filepath.IsAbs(dir) {
+			patterns = append(patterns, "*/"+dir+"/*")
+		}
+	}
+
+	if glob := wildcards(name); strings.Trim(glob, "*.") != "" {
+		if patterns = append(patterns, glob); !strings.Contains(template, "{n}") {
+			patterns = append(patterns, glob+".[0-9]*")
+		}
+	}
+	return
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBackupTemplate(t *testing.T) {
+	m := &sync.Mutex{}
+	started := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	Convey("Parse", t, func() {
+		for _, template := range []string{"", " ", "{name", "{nope}.bak", "{name}", "./{stem}{ext}", "{stem}{n}{ext}", "{name}.{n}"} {
+			tmpl, err := ParseBackupTemplate(template, "run", started)
+			So(err, ShouldNotEqual, nil)
+			So(tmpl, ShouldBeNil)
+		}
+		tmpl, err := ParseBackupTemplate("{name}.{ts}.orig", "run", started)
+		So(err, ShouldEqual, nil)
+		So(tmpl.String(), ShouldEqual, "{name}.{ts}.orig")
+	})
+
+	Convey("Expand", t, func() {
+		tmpl, _ := ParseBackupTemplate("{name}.{ts}.orig", "run", started)
+		So(tmpl.Expand("/tmp/file.txt", 1), ShouldEqual, "/tmp/file.txt.20240102T030405.orig")
+		So(tmpl.Globs(), ShouldEqual, []string{"*.*.orig", "*.*.orig.[0-9]*"})
+		tmpl, _ = ParseBackupTemplate("backups/{stem}.{n}{ext}", "run", started)
+		So(tmpl.Expand("/tmp/file.txt", 2), ShouldEqual, "/tmp/backups/file.2.txt")
+		So(tmpl.Globs(), ShouldEqual, []string{"backups/*", "*/backups/*"})
+		tmpl, _ = ParseBackupTemplate("/var/backups/{run}/{name}", "run", started)
+		So(tmpl.Expand("/tmp/file.txt", 1), ShouldEqual, "/var/backups/run/file.txt")
+		So(tmpl.Globs(), ShouldEqual, []string{"/var/backups/*/*"})
+		tmpl, _ = ParseBackupTemplate("{name}.{n}.orig", "run", started)
+		So(tmpl.Globs(), ShouldEqual, []string{"*.*.orig"})
+	})
+
+	Convey("Next", t, func() {
+		dir := t.TempDir()
+		target := filepath.Join(dir, "file.txt")
+		tmpl, _ := ParseBackupTemplate("{name}.orig", "run", started)
+		So(tmpl.Next(target), ShouldEqual, target+".orig")
+		So(os.WriteFile(target+".orig", nil, 0644), ShouldEqual, nil)
+		So(tmpl.Next(target), ShouldEqual, target+".orig.1")
+		tmpl, _ = ParseBackupTemplate("{name}.{n}.orig", "run", started)
+		So(tmpl.Next(target), ShouldEqual, target+".1.orig")
+	})
+
+	Convey("Apply", t, func() {
+		m.Lock()
+		defer m.Unlock()
+		outio, errio, w := makeWorker()
+		defer outio.Restore()
+		defer errio.Restore()
+		dir := t.TempDir()
+		target := filepath.Join(dir, "file.txt")
+		So(os.WriteFile(target, []byte("hello world\n"), 0644), ShouldEqual, nil)
+		w.Search = "hello"
+		w.Replace = "olleh"
+		w.Backup = true
+		w.BackupTemplate = "backups/{stem}.{run}{ext}"
+		w.RunID = "test"
+		w.Matched = []string{target}
+		So(w.Init(), ShouldEqual, nil)
+
+		expected := filepath.Join(dir, "backups", "file.test.txt")
+
+		w.Nop = true
+		iter := w.StartIterating()
+		_, _, backup, err := iter.ApplyAll()
+		So(err, ShouldEqual, nil)
+		So(backup, ShouldEqual, expected)
+		So(filepath.Join(dir, "backups"), ShouldNotBeIn, listDir(dir))
+
+		w.Nop = false
+		iter = w.StartIterating()
+		_, _, backup, err = iter.ApplyAll()
+		So(err, ShouldEqual, nil)
+		So(backup, ShouldEqual, expected)
+		data, _ := os.ReadFile(backup)
+		So(string(data), ShouldEqual, "hello world\n")
+		data, _ = os.ReadFile(target)
+		So(string(data), ShouldEqual, "olleh world\n")
+	})
+
+	Convey("Repeated Runs", t, func() {
+		m.Lock()
+		defer m.Unlock()
+		run := func(dir, template string) {
+			outio, errio, w := makeWorker()
+			defer outio.Restore()
+			defer errio.Restore()
+			w.Paths = []string{dir}
+			w.Search = "hello"
+			w.Replace = "hello world"
+			w.Backup = true
+			w.BackupTemplate = template
+			w.Recurse = true
+			So(w.Init(), ShouldEqual, nil)
+			So(w.InitTargets(nil), ShouldEqual, nil)
+			So(w.FindMatching(nil), ShouldEqual, nil)
+			So(w.Matched, ShouldEqual, []string{filepath.Join(dir, "c.txt")})
+			for iter := w.StartIterating(); iter.Valid(); iter.Next() {
+				_, _, _, err := iter.ApplyAll()
+				So(err, ShouldEqual, nil)
+			}
+		}
+		read := func(dir, name string) (content string) {
+			data, _ := os.ReadFile(filepath.Join(dir, name))
+			content = string(data)
+			return
+		}
+
+		dir := t.TempDir()
+		So(os.WriteFile(filepath.Join(dir, "c.txt"), []byte("hello\n"), 0644), ShouldEqual, nil)
+		run(dir, "bak/{stem}.{n}{ext}")
+		run(dir, "bak/{stem}.{n}{ext}")
+		So(read(dir, "c.txt"), ShouldEqual, "hello world world\n")
+		So(read(dir, "bak/c.1.txt"), ShouldEqual, "hello\n")
+		So(read(dir, "bak/c.2.txt"), ShouldEqual, "hello world\n")
+		_, err := os.Stat(filepath.Join(dir, "bak", "bak"))
+		So(err, ShouldNotEqual, nil)
+
+		dir = t.TempDir()
+		So(os.WriteFile(filepath.Join(dir, "c.txt"), []byte("hello\n"), 0644), ShouldEqual, nil)
+		run(dir, "{name}.orig")
+		run(dir, "{name}.orig")
+		run(dir, "{name}.orig")
+		So(read(dir, "c.txt"), ShouldEqual, "hello world world world\n")
+		So(read(dir, "c.txt.orig"), ShouldEqual, "hello\n")
+		So(read(dir, "c.txt.orig.1"), ShouldEqual, "hello world\n")
+		So(read(dir, "c.txt.orig.2"), ShouldEqual, "hello world world\n")
+		_, err = os.Stat(filepath.Join(dir, "c.txt.orig.1.orig"))
+		So(err, ShouldNotEqual, nil)
+	})
+
+	Convey("Invalid", t, func() {
+		m.Lock()
+		defer m.Unlock()
+		outio, errio, w := makeWorker()
+		defer outio.Restore()
+		defer errio.Restore()
+		w.Search = "hello"
+		w.BackupTemplate = "{name}"
+		So(w.Init(), ShouldNotEqual, nil)
+	})
+}
+
+func listDir(dir string) (paths []string) {
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		paths = append(paths, filepath.Join(dir, entry.Name()))
+	}
+	return
+}
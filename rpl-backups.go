@@ -0,0 +1,291 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hexops/gotextdiff"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+
+	"github.com/go-corelibs/path"
+)
+
+// BackupNaming returns the extension and separator used with path.BackupName
+// for the backup extension given, an empty extension is the default naming
+// of "file~", "file~1~", "file~2~" and so on, while custom extensions are
+// named "file.bak", "file.1.bak", "file.2.bak" and so on
+func BackupNaming(extension string) (ext, separator string) {
+	if ext = extension; ext != "" {
+		separator = "."
+		return
+	}
+	ext = DefaultBackupExtension
+	separator = DefaultBackupSeparator
+	return
+}
+
+// ParseBackupName checks if the file name given is a backup produced by
+// path.BackupName with the extension and separator given, returning the name
+// of the original file and the generation of the backup. The first backup of
+// a file is generation zero. When the name is ambiguous, an existing original
+// file is preferred
+func ParseBackupName(name, extension, separator string) (original string, generation int, ok bool) {
+	if extension == "" || !strings.HasSuffix(name, extension) {
+		return
+	}
+	label := strings.TrimSuffix(name, extension)
+	if label == "" || strings.HasSuffix(label, string(filepath.Separator)) {
+		return
+	}
+	original, ok = label, true
+	if path.IsFile(label) {
+		return
+	}
+	if idx := strings.LastIndex(label, separator); idx > 0 {
+		if n, err := strconv.Atoi(label[idx+len(separator):]); err == nil && n > 0 {
+			original, generation = label[:idx], n
+		}
+	}
+	return
+}
+
+// ParseAge parses the value given as a time.Duration, with additional support
+// for days ("d") and weeks ("w") units, ie: "7d" or "2w"
+func ParseAge(value string) (age time.Duration, err error) {
+	var unit time.Duration
+	switch {
+	case strings.HasSuffix(value, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(value, "w"):
+		unit = 7 * 24 * time.Hour
+	default:
+		age, err = time.ParseDuration(value)
+		return
+	}
+	var n float64
+	if n, err = strconv.ParseFloat(value[:len(value)-1], 64); err != nil {
+		err = fmt.Errorf("invalid age %q", value)
+		return
+	}
+	age = time.Duration(n * float64(unit))
+	return
+}
+
+// BackupFile is a single backup of an original file
+type BackupFile struct {
+	Path       string
+	Generation int
+	Size       int64
+	ModTime    time.Time
+}
+
+// BackupChain is the list of all backups of an original file, sorted from
+// the oldest to the newest
+type BackupChain struct {
+	Original string
+	Backups  []BackupFile
+}
+
+// Exists returns true if the original file still exists
+func (c *BackupChain) Exists() (exists bool) {
+	exists = path.IsFile(c.Original)
+	return
+}
+
+// Latest returns the newest generation of backup
+func (c *BackupChain) Latest() (backup BackupFile, ok bool) {
+	if ok = len(c.Backups) > 0; ok {
+		backup = c.Backups[len(c.Backups)-1]
+	}
+	return
+}
+
+// Get returns the backup with the generation given
+func (c *BackupChain) Get(generation int) (backup BackupFile, ok bool) {
+	for _, backup = range c.Backups {
+		if ok = backup.Generation == generation; ok {
+			return
+		}
+	}
+	backup = BackupFile{}
+	return
+}
+
+// Select returns the backup with the generation given, or the latest backup
+// when the generation is negative
+func (c *BackupChain) Select(generation int) (backup BackupFile, err error) {
+	var ok bool
+	if generation < 0 {
+		backup, ok = c.Latest()
+	} else {
+		backup, ok = c.Get(generation)
+	}
+	if !ok {
+		err = fmt.Errorf("%w: backup generation %d of %q", ErrNotFound, generation, c.Original)
+	}
+	return
+}
+
+// Diff returns the unified diff of the backup given against the current
+// content of the original file
+func (c *BackupChain) Diff(backup BackupFile) (unified string, err error) {
+	var before, after []byte
+	if before, err = os.ReadFile(backup.Path); err != nil {
+		return
+	}
+	if c.Exists() {
+		if after, err = os.ReadFile(c.Original); err != nil {
+			return
+		}
+	}
+	edits := myers.ComputeEdits(span.URIFromPath(c.Original), string(before), string(after))
+	unified = fmt.Sprint(gotextdiff.ToUnified(backup.Path, c.Original, string(before), edits))
+	return
+}
+
+// Restore copies the backup given over the original file. When keep is true,
+// the current original is first backed up as a new generation, which is
+// returned as the saved path
+func (c *BackupChain) Restore(backup BackupFile, keep bool, extension, separator string) (saved string, err error) {
+	if keep && c.Exists() {
+		for saved = path.BackupName(c.Original, extension, separator); path.Exists(saved); {
+			saved = path.BackupName(saved, extension, separator)
+		}
+		if _, err = path.CopyFile(c.Original, saved); err != nil {
+			saved = ""
+			return
+		}
+	}
+	_, err = path.CopyFile(backup.Path, c.Original)
+	return
+}
+
+// Prunable returns the backups which are not one of the newest keep
+// generations and, when olderThan is greater than zero, were modified more
+// than olderThan ago
+func (c *BackupChain) Prunable(keep int, olderThan time.Duration, now time.Time) (backups []BackupFile) {
+	for idx, backup := range c.Backups {
+		if len(c.Backups)-idx <= keep {
+			break
+		} else if olderThan > 0 && now.Sub(backup.ModTime) < olderThan {
+			continue
+		}
+		backups = append(backups, backup)
+	}
+	return
+}
+
+// FindBackups looks for backups within the target given, grouped by their
+// original files. Directories are only searched recursively when recurse is
+// true and hidden paths are skipped unless all is true
+func FindBackups(target string, recurse, all bool, extension, separator string) (chains []*BackupChain, err error) {
+	if target, err = filepath.Abs(target); err != nil {
+		return
+	}
+
+	lookup := make(map[string]*BackupChain)
+	add := func(file string) {
+		original, generation, ok := ParseBackupName(file, extension, separator)
+		if !ok {
+			return
+		}
+		var stat os.FileInfo
+		if stat, err = os.Stat(file); err != nil {
+			err = nil
+			return
+		}
+		chain, present := lookup[original]
+		if !present {
+			chain = &BackupChain{Original: original}
+			lookup[original] = chain
+			chains = append(chains, chain)
+		}
+		chain.Backups = append(chain.Backups, BackupFile{
+			Path:       file,
+			Generation: generation,
+			Size:       stat.Size(),
+			ModTime:    stat.ModTime(),
+		})
+	}
+
+	if path.IsFile(target) {
+		// find the backups of this one file
+		var matches []string
+		if matches, err = filepath.Glob(globEscape(target) + "*"); err != nil {
+			return
+		}
+		for _, file := range matches {
+			if original, _, ok := ParseBackupName(file, extension, separator); ok && original == target {
+				add(file)
+			}
+		}
+	} else if path.IsDir(target) {
+		err = filepath.WalkDir(target, func(file string, d fs.DirEntry, e error) error {
+			if e != nil {
+				return nil
+			} else if d.IsDir() {
+				if file != target && (!recurse || (!all && path.IsHidden(file))) {
+					return filepath.SkipDir
+				}
+				return nil
+			} else if !all && path.IsHidden(file) {
+				return nil
+			}
+			add(file)
+			return nil
+		})
+	} else {
+		err = fmt.Errorf("%w: %q", ErrNotFound, target)
+		return
+	}
+
+	sort.Slice(chains, func(i, j int) bool {
+		return chains[i].Original < chains[j].Original
+	})
+	for _, chain := range chains {
+		// generation numbers are reused after pruning, so the modification
+		// times take precedence
+		sort.Slice(chain.Backups, func(i, j int) bool {
+			a, b := chain.Backups[i], chain.Backups[j]
+			if !a.ModTime.Equal(b.ModTime) {
+				return a.ModTime.Before(b.ModTime)
+			}
+			return a.Generation < b.Generation
+		})
+	}
+	return
+}
+
+func globEscape(value string) (escaped string) {
+	var buf strings.Builder
+	for _, r := range value {
+		switch r {
+		case '*', '?', '[', '\\':
+			buf.WriteRune('\\')
+		}
+		buf.WriteRune(r)
+	}
+	escaped = buf.String()
+	return
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBackups(t *testing.T) {
+
+	Convey("Naming", t, func() {
+		ext, sep := BackupNaming("")
+		So(ext, ShouldEqual, "~")
+		So(sep, ShouldEqual, "~")
+		ext, sep = BackupNaming(".bak")
+		So(ext, ShouldEqual, ".bak")
+		So(sep, ShouldEqual, ".")
+
+		for _, test := range []struct {
+			name, ext, sep string
+			original       string
+			generation     int
+			ok             bool
+		}{
+			{"/nope/file.txt", "~", "~", "", 0, false},
+			{"/nope/file.txt~", "~", "~", "/nope/file.txt", 0, true},
+			{"/nope/file.txt~1~", "~", "~", "/nope/file.txt", 1, true},
+			{"/nope/file.txt~12~", "~", "~", "/nope/file.txt", 12, true},
+			{"/nope/file.txt.bak", ".bak", ".", "/nope/file.txt", 0, true},
+			{"/nope/file.txt.3.bak", ".bak", ".", "/nope/file.txt", 3, true},
+			{"/nope/~", "~", "~", "", 0, false},
+		} {
+			original, generation, ok := ParseBackupName(test.name, test.ext, test.sep)
+			So(ok, ShouldEqual, test.ok)
+			So(original, ShouldEqual, test.original)
+			So(generation, ShouldEqual, test.generation)
+		}
+	})
+
+	Convey("Age", t, func() {
+		age, err := ParseAge("36h")
+		So(err, ShouldEqual, nil)
+		So(age, ShouldEqual, 36*time.Hour)
+		age, err = ParseAge("7d")
+		So(err, ShouldEqual, nil)
+		So(age, ShouldEqual, 7*24*time.Hour)
+		age, err = ParseAge("2w")
+		So(err, ShouldEqual, nil)
+		So(age, ShouldEqual, 14*24*time.Hour)
+		_, err = ParseAge("xd")
+		So(err, ShouldNotEqual, nil)
+	})
+
+	Convey("Chains", t, func() {
+		dir := t.TempDir()
+		target := filepath.Join(dir, "file.txt")
+		So(os.WriteFile(target, []byte("three\n"), 0644), ShouldEqual, nil)
+		So(os.WriteFile(target+"~", []byte("one\n"), 0644), ShouldEqual, nil)
+		So(os.WriteFile(target+"~1~", []byte("two\n"), 0644), ShouldEqual, nil)
+		So(os.WriteFile(target+".bak", []byte("bak\n"), 0644), ShouldEqual, nil)
+		old := time.Now().Add(-48 * time.Hour)
+		So(os.Chtimes(target+"~", old, old), ShouldEqual, nil)
+
+		chains, err := FindBackups(dir, false, false, "~", "~")
+		So(err, ShouldEqual, nil)
+		So(chains, ShouldHaveLength, 1)
+		chain := chains[0]
+		So(chain.Original, ShouldEqual, target)
+		So(chain.Backups, ShouldHaveLength, 2)
+		latest, ok := chain.Latest()
+		So(ok, ShouldEqual, true)
+		So(latest.Path, ShouldEqual, target+"~1~")
+
+		chains, err = FindBackups(target, false, false, ".bak", ".")
+		So(err, ShouldEqual, nil)
+		So(chains, ShouldHaveLength, 1)
+		So(chains[0].Backups, ShouldHaveLength, 1)
+
+		unified, err := chain.Diff(latest)
+		So(err, ShouldEqual, nil)
+		So(unified, ShouldContainSubstring, "-two\n+three\n")
+
+		So(chain.Prunable(1, 0, time.Now()), ShouldHaveLength, 1)
+		So(chain.Prunable(0, 24*time.Hour, time.Now()), ShouldHaveLength, 1)
+		So(chain.Prunable(0, 72*time.Hour, time.Now()), ShouldHaveLength, 0)
+		So(chain.Prunable(2, 0, time.Now()), ShouldHaveLength, 0)
+
+		first, err := chain.Select(0)
+		So(err, ShouldEqual, nil)
+		saved, err := chain.Restore(first, true, "~", "~")
+		So(err, ShouldEqual, nil)
+		So(saved, ShouldEqual, target+"~2~")
+		data, _ := os.ReadFile(target)
+		So(string(data), ShouldEqual, "one\n")
+		data, _ = os.ReadFile(saved)
+		So(string(data), ShouldEqual, "three\n")
+
+		_, err = chain.Select(5)
+		So(err, ShouldNotEqual, nil)
+	})
+}
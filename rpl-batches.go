@@ -0,0 +1,332 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-corelibs/path"
+	rpl "github.com/go-corelibs/replace"
+)
+
+// cTargetWalker lazily expands the Worker.Targets into the list of files to
+// search, in the same depth-first order as rpl.FindAllIncluded, without
+// holding the entire list of files in memory
+type cTargetWalker struct {
+	w *Worker
+
+	// stack of paths yet to be processed, the last list is processed first
+	stack [][]string
+	// index of the Worker.Targets entry currently being walked
+	index int
+	// directory targets, used to skip files already found by walking an
+	// earlier target
+	dirs map[string]int
+	// file targets, used to skip files already given as an earlier target
+	files map[string]int
+}
+
+func newTargetWalker(w *Worker) (t *cTargetWalker) {
+	t = &cTargetWalker{
+		w:     w,
+		index: -1,
+		dirs:  make(map[string]int),
+		files: make(map[string]int),
+	}
+	if w.Recurse {
+		for idx, target := range w.Targets {
+			if w.isDir(target) {
+				t.dirs[target] = idx
+			} else {
+				t.files[target] = idx
+			}
+		}
+	}
+	return
+}
+
+// seen returns true if the file given is an earlier file target or is within
+// a directory target that was walked before the current target
+func (t *cTargetWalker) seen(file string) (seen bool) {
+	if idx, present := t.files[file]; present && idx < t.index {
+		return true
+	}
+	for dir, idx := range t.dirs {
+		if idx < t.index && (dir == "." || strings.HasPrefix(file, dir+string(filepath.Separator))) {
+			return true
+		}
+	}
+	return
+}
+
+func (t *cTargetWalker) check(file string) (allowed bool) {
+	if !t.w.All && path.IsHidden(file) {
+		return
+	} else if t.seen(file) {
+		return
+	} else if t.w.backupDir != nil && t.w.backupDir.Contains(file) {
+		return
+	}
+	allowed = rpl.IsIncluded(t.w.Include, t.w.Exclude, file)
+	return
+}
+
+// checkArchive is check for archives, which are only excluded by the exclude
+// globs as the include globs are applied to the archive members
+func (t *cTargetWalker) checkArchive(file string) (allowed bool) {
+	if !t.w.All && path.IsHidden(file) {
+		return
+	} else if t.seen(file) {
+		return
+	} else if t.w.backupDir != nil && t.w.backupDir.Contains(file) {
+		return
+	}
+	allowed = !t.w.Exclude.Match(file)
+	return
+}
+
+// next returns the next file to be searched, ok is false when there are no
+// more files
+func (t *cTargetWalker) next() (file string, ok bool) {
+	for {
+		last := len(t.stack) - 1
+		if last < 0 {
+			if t.index+1 >= len(t.w.Targets) {
+				return
+			}
+			t.index += 1
+			t.stack = append(t.stack, []string{t.w.Targets[t.index]})
+			continue
+		}
+		if len(t.stack[last]) == 0 {
+			t.stack = t.stack[:last]
+			continue
+		}
+
+		target := t.stack[last][0]
+		t.stack[last] = t.stack[last][1:]
+
+		if t.w.isFile(target) {
+			if t.w.isArchive(target) && t.checkArchive(target) {
+				// archive members are walked as a virtual directory, the
+				// archive itself is searched if it cannot be opened
+				if members, err := t.w.listArchive(target); err == nil {
+					t.stack = append(t.stack, members)
+					continue
+				}
+			}
+			if t.check(target) {
+				return target, true
+			}
+		} else if t.w.Recurse && t.w.isDir(target) {
+			if t.w.backupDir != nil && t.w.backupDir.Contains(target) {
+				continue
+			}
+			files := t.w.listFiles(target)
+			dirs := t.w.listDirs(target)
+			// files are processed before any sub-directories
+			t.stack = append(t.stack, dirs, files)
+		}
+	}
+}
+
+// matchFile checks a single file for the search term, respecting the size and
+// binary file limits in the same way as the go-corelibs/replace finders
+func (w *Worker) matchFile(file string) (matched bool, err error) {
+	if w.isArchive(file) {
+		// only searched when the archive could not be opened
+		if _, err = w.openArchive(file); err == nil {
+			err = rpl.ErrBinaryFile
+		}
+		return
+	}
+
+	var info fs.FileInfo
+	if info, err = w.stat(file); err != nil {
+		return
+	} else if !w.NoLimits && info.Mode().IsRegular() && info.Size() > w.GetMaxFileSize() {
+		err = rpl.ErrLargeFile
+		return
+	} else if !w.BinAsText && !info.Mode().IsRegular() {
+		err = rpl.ErrBinaryFile
+		return
+	}
+
+	var data []byte
+	if data, err = w.readFile(file); err != nil {
+		return
+	} else if data, _, err = w.decodeText(data); err != nil {
+		return
+	} else if !w.BinAsText && !isPlainText(data) {
+		err = rpl.ErrBinaryFile
+		return
+	}
+
+	// includeHidden and recurse are handled by the cTargetWalker
+	if w.isScoped() {
+		// only the scoped parts of the file are considered
+		var edits *Edits
+		if edits, err = w.findScopedEdits(file, string(data)); err == nil {
+			matched = edits.Len() > 0
+		}
+	} else if w.isFolding() || w.LooseWhitespace || w.isConventionSearch() {
+		// these match differently than the plain search
+		matched = len(w.findContentEdits(string(data))) > 0
+	} else if w.Regex {
+		if w.MultiLine {
+			matched = w.Pattern.Match(data)
+		} else {
+			lines := strings.Split(string(data), "\n")
+			last := len(lines) - 1
+			for idx, line := range lines {
+				if idx < last {
+					line += "\n"
+				}
+				if matched = w.Pattern.MatchString(line); matched {
+					return
+				}
+			}
+		}
+	} else if w.PreserveCase || w.IgnoreCase {
+		matched = strings.Contains(strings.ToLower(string(data)), strings.ToLower(w.Search))
+	} else {
+		matched = strings.Contains(string(data), w.Search)
+	}
+	return
+}
+
+// matchFileContext is matchFile limited by the context given and the
+// FileTimeout
+func (w *Worker) matchFileContext(ctx context.Context, file string) (matched bool, err error) {
+	var m bool
+	var e error
+	if err = w.runFileContext(ctx, func() {
+		m, e = w.matchFile(file)
+	}); err == nil {
+		matched, err = m, e
+	}
+	return
+}
+
+// findNextBatch replaces the Worker.Files and Worker.Matched lists with the
+// next batch of at most GetMaxFiles files (unless NoLimits is set)
+func (w *Worker) findNextBatch() (err error) {
+	ctx := w.findContext()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	w.Files, w.Matched, w.finished = nil, nil, nil
+	if w.walker == nil {
+		return
+	}
+
+	limit := w.GetMaxFiles()
+	for w.NoLimits || len(w.Files) < limit {
+		if err = ctx.Err(); err != nil {
+			// the walker is kept for reporting the unfinished targets
+			break
+		}
+		file, ok := w.walker.next()
+		if !ok {
+			// all targets walked
+			w.walker = nil
+			break
+		}
+		w.emit(ScanStartedEvent{File: file})
+		matched, ee := w.matchFileContext(ctx, file)
+		w.emit(ScanFinishedEvent{File: file, Matched: matched, Err: ee})
+		if isContextErr(ee) {
+			w.unfinished = append(w.unfinished, file)
+			err = ee
+			break
+		} else if ee != nil {
+			w.emit(ErrorEvent{File: file, Err: ee})
+		}
+		w.Files = append(w.Files, file)
+		if matched {
+			w.Matched = append(w.Matched, file)
+			w.emit(MatchFoundEvent{File: file})
+		} else if errors.Is(ee, rpl.ErrLargeFile) {
+			w.oversized = append(w.oversized, file)
+		} else if errors.Is(ee, ErrFileTimeout) {
+			w.unfinished = append(w.unfinished, file)
+		}
+		if w.findFn != nil {
+			w.findFn(file, matched, ee)
+		}
+	}
+
+	if err != nil {
+		// cancelled, the files matched so far are never worked on
+		w.unfinished = append(w.unfinished, w.Matched...)
+	}
+
+	w.batches += 1
+	w.filesCount += len(w.Files)
+	w.matchedCount += len(w.Matched)
+	return
+}
+
+// NextBatch moves the Worker.Files and Worker.Matched lists to the next batch
+// of files, returning false if there are no more batches
+func (w *Worker) NextBatch() (ok bool, err error) {
+	for w.HasMoreBatches() {
+		if err = w.findNextBatch(); err != nil {
+			return
+		} else if len(w.Files) > 0 {
+			ok = true
+			return
+		}
+	}
+	return
+}
+
+// HasMoreBatches returns true if there are still targets to be searched
+func (w *Worker) HasMoreBatches() (more bool) {
+	more = w.walker != nil
+	return
+}
+
+// BatchCount returns the number of batches searched so far
+func (w *Worker) BatchCount() (count int) {
+	count = w.batches
+	return
+}
+
+// FilesCount returns the total number of files searched so far, across all
+// batches
+func (w *Worker) FilesCount() (count int) {
+	count = w.filesCount
+	return
+}
+
+// MatchedCount returns the total number of files matched so far, across all
+// batches
+func (w *Worker) MatchedCount() (count int) {
+	count = w.matchedCount
+	return
+}
+
+// Oversized returns the list of files skipped so far for being larger than
+// the GetMaxFileSize limit, across all batches
+func (w *Worker) Oversized() (files []string) {
+	files = w.oversized
+	return
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-corelibs/strcases"
+)
+
+// gCasingMaxWords is the most words a casing dictionary entry may span, ie:
+// "GraphQL" is the two words "Graph" and "QL"
+const gCasingMaxWords = 4
+
+// cCasing is the casing dictionary, mapping the lower case spelling of the
+// acronyms and brand names to their canonical spelling
+type cCasing map[string]string
+
+// add adds the canonical spellings given
+func (d cCasing) add(spellings ...string) (err error) {
+	for _, spelling := range spellings {
+		if spelling = strings.TrimSpace(spelling); spelling == "" {
+			continue
+		}
+		for _, r := range spelling {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				err = fmt.Errorf("%q is not a single identifier", spelling)
+				return
+			}
+		}
+		if len(wordSpans(spelling)) > gCasingMaxWords {
+			err = fmt.Errorf("%q has more than %d words", spelling, gCasingMaxWords)
+			return
+		}
+		d[strings.ToLower(spelling)] = spelling
+	}
+	return
+}
+
+// canonical returns the text with any words (or adjacent words) found in the
+// dictionary spelled canonically, the first word is left as-is when keepFirst
+// is true, as with the leading word of lowerCamelCase
+func (d cCasing) canonical(text string, keepFirst bool) (modified string) {
+	if len(d) == 0 {
+		return text
+	}
+	spans := wordSpans(text)
+	var buf strings.Builder
+	last := 0
+	for idx := 0; idx < len(spans); idx++ {
+		if keepFirst && idx == 0 {
+			continue
+		}
+		// the longest run of adjacent words found in the dictionary
+		for count := gCasingMaxWords; count > 0; count-- {
+			end := idx + count - 1
+			if end >= len(spans) {
+				continue
+			}
+			adjacent := true
+			for j := idx + 1; j <= end; j++ {
+				adjacent = adjacent && spans[j][0] == spans[j-1][1]
+			}
+			if !adjacent {
+				continue
+			}
+			start, stop := spans[idx][0], spans[end][1]
+			if spelling, ok := d[strings.ToLower(text[start:stop])]; ok {
+				buf.WriteString(text[last:start])
+				buf.WriteString(spelling)
+				last = stop
+				idx = end
+				break
+			}
+		}
+	}
+	buf.WriteString(text[last:])
+	modified = buf.String()
+	return
+}
+
+// applyCase is strcases.Case.Apply with the casing dictionary applied to
+// the cases which are not deliberately all lower or upper case. Upper case
+// matches which are part of a SCREAMING_SNAKE or SCREAMING-KEBAB identifier
+// are not changed
+func (d cCasing) applyCase(c strcases.Case, text, content string, start, end int) (modified string) {
+	modified = c.Apply(text)
+	switch c {
+	case strcases.UpperCase:
+		isJoiner := func(r rune) bool { return r == '_' || r == '-' }
+		before, _ := utf8.DecodeLastRuneInString(content[:start])
+		after, _ := utf8.DecodeRuneInString(content[end:])
+		if (start > 0 && isJoiner(before)) || (end < len(content) && isJoiner(after)) {
+			return
+		}
+		modified = d.canonical(modified, false)
+	case strcases.CamelCase, strcases.UnknownCase:
+		modified = d.canonical(modified, false)
+	case strcases.LowerCamelCase:
+		modified = d.canonical(modified, true)
+	}
+	return
+}
+
+// applyConvention is cConvention.apply with the casing dictionary applied to
+// the camel and title case conventions
+func (d cCasing) applyConvention(c cConvention, words []string) (modified string) {
+	modified = c.apply(words)
+	switch c.style {
+	case cCamelWords, cTitleWords:
+		modified = d.canonical(modified, false)
+	case cLowerCamelWords:
+		modified = d.canonical(modified, true)
+	}
+	return
+}
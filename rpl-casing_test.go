@@ -0,0 +1,59 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCasing(t *testing.T) {
+	t.Parallel()
+
+	Convey("Dictionary", t, func() {
+		d := make(cCasing)
+		So(d.add("GraphQL", " HTTPS ", "iOS", ""), ShouldBeNil)
+		So(d, ShouldEqual, cCasing{"graphql": "GraphQL", "https": "HTTPS", "ios": "iOS"})
+		So(d.add("graph-ql"), ShouldNotBeNil)
+
+		So(d.canonical("GRAPHQL", false), ShouldEqual, "GraphQL")
+		So(d.canonical("GraphqlClient", false), ShouldEqual, "GraphQLClient")
+		So(d.canonical("Https.Ios", false), ShouldEqual, "HTTPS.iOS")
+		So(d.canonical("graphqlGraphql", true), ShouldEqual, "graphqlGraphQL")
+
+		_, err := New(WithCasing("not valid"))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Preserve Case", t, func() {
+		modified := func(search, replace, content string, casing ...string) (output string) {
+			w, err := New(WithQuiet(true), WithSearch(search, replace), WithPreserveCase(true), WithCasing(casing...))
+			So(err, ShouldBeNil)
+			output = w.findEdits("test", content).Modified()
+			return
+		}
+
+		content := "the API, an Api, api.example.com, API_URL, NewApiClient"
+		So(modified("api", "graphql", content), ShouldEqual,
+			"the GRAPHQL, an Graphql, graphql.example.com, GRAPHQL_URL, NewGraphqlClient")
+		So(modified("api", "graphql", content, "GraphQL"), ShouldEqual,
+			"the GraphQL, an GraphQL, graphql.example.com, GRAPHQL_URL, NewGraphQLClient")
+		So(modified("Http", "Https", "Http and HTTP", "HTTPS"), ShouldEqual, "HTTPS and HTTPS")
+
+		So(modified("rest_client", "graphql_client", "RestClient restClient rest_client", "GraphQL"), ShouldEqual,
+			"GraphQLClient graphqlClient graphql_client")
+	})
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"time"
+
+	dsbzip2 "github.com/dsnet/compress/bzip2"
+	"github.com/ulikunitz/xz"
+
+	rpl "github.com/go-corelibs/replace"
+)
+
+const (
+	// CompressionGzip is the name of the gzip compression format
+	CompressionGzip = "gzip"
+	// CompressionBzip2 is the name of the bzip2 compression format
+	CompressionBzip2 = "bzip2"
+	// CompressionXz is the name of the xz compression format
+	CompressionXz = "xz"
+)
+
+var (
+	gGzipMagic  = []byte{0x1f, 0x8b}
+	gBzip2Magic = []byte("BZh")
+	gXzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+)
+
+// cCompression records the format and settings of a compressed file, so that
+// the modified content can be recompressed in the same manner
+type cCompression struct {
+	format string
+	level  int
+	header gzip.Header
+	check  byte
+}
+
+// detectCompression returns the compression of the data given, detected by
+// the magic bytes, or nil if the data is not compressed
+func detectCompression(data []byte) (c *cCompression) {
+	switch {
+	case bytes.HasPrefix(data, gGzipMagic) && len(data) > 8:
+		// the extra flags byte records the maximum and fastest levels
+		c = &cCompression{format: CompressionGzip, level: gzip.DefaultCompression}
+		switch data[8] {
+		case 2:
+			c.level = gzip.BestCompression
+		case 4:
+			c.level = gzip.BestSpeed
+		}
+	case bytes.HasPrefix(data, gBzip2Magic) && len(data) > 3 && data[3] >= '1' && data[3] <= '9':
+		// the block size is the level
+		c = &cCompression{format: CompressionBzip2, level: int(data[3] - '0')}
+	case bytes.HasPrefix(data, gXzMagic) && len(data) > 7:
+		// the preset level is not recorded, only the integrity check
+		c = &cCompression{format: CompressionXz, check: data[7] & 0x0f}
+	}
+	return
+}
+
+// decompress returns the decompressed data, returning rpl.ErrLargeFile if the
+// decompressed size exceeds the limit given, when the limit is positive
+func (c *cCompression) decompress(data []byte, limit int64) (text []byte, err error) {
+	var r io.Reader
+	switch c.format {
+	case CompressionGzip:
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(bytes.NewReader(data)); err != nil {
+			return
+		}
+		c.header = gz.Header
+		r = gz
+	case CompressionBzip2:
+		r = bzip2.NewReader(bytes.NewReader(data))
+	case CompressionXz:
+		if r, err = xz.NewReader(bytes.NewReader(data)); err != nil {
+			return
+		}
+	}
+	if limit > 0 {
+		r = io.LimitReader(r, limit+1)
+	}
+	if text, err = io.ReadAll(r); err == nil && limit > 0 && int64(len(text)) > limit {
+		text, err = nil, rpl.ErrLargeFile
+	}
+	return
+}
+
+// compress returns the text given compressed with the same format and level
+// as the original data
+func (c *cCompression) compress(text []byte) (data []byte, err error) {
+	var buf bytes.Buffer
+	var wc io.WriteCloser
+	switch c.format {
+	case CompressionGzip:
+		var gz *gzip.Writer
+		if gz, err = gzip.NewWriterLevel(&buf, c.level); err != nil {
+			return
+		}
+		gz.Header = c.header
+		if !gz.ModTime.IsZero() {
+			gz.ModTime = time.Now()
+		}
+		wc = gz
+	case CompressionBzip2:
+		if wc, err = dsbzip2.NewWriter(&buf, &dsbzip2.WriterConfig{Level: c.level}); err != nil {
+			return
+		}
+	case CompressionXz:
+		config := xz.WriterConfig{CheckSum: c.check, NoCheckSum: c.check == xz.None}
+		if wc, err = config.NewWriter(&buf); err != nil {
+			return
+		}
+	}
+	if _, err = wc.Write(text); err != nil {
+		return
+	} else if err = wc.Close(); err == nil {
+		data = buf.Bytes()
+	}
+	return
+}
+
+// decodeText decompresses the file data given when the Worker.Decompress
+// option is set and the data is compressed, otherwise the data is returned
+// as-is with a nil codec
+func (w *Worker) decodeText(data []byte) (text []byte, codec *cCompression, err error) {
+	text = data
+	if !w.Decompress {
+		return
+	} else if codec = detectCompression(data); codec == nil {
+		return
+	}
+	var limit int64
+	if !w.NoLimits {
+		limit = w.GetMaxFileSize()
+	}
+	if text, err = codec.decompress(data, limit); err != nil {
+		codec = nil
+	}
+	return
+}
+
+// encodeText recompresses the modified content with the codec given, if any
+func encodeText(codec *cCompression, modified string) (content string, err error) {
+	if content = modified; codec == nil {
+		return
+	}
+	var data []byte
+	if data, err = codec.compress([]byte(modified)); err == nil {
+		content = string(data)
+	}
+	return
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	rpl "github.com/go-corelibs/replace"
+)
+
+func TestCompression(t *testing.T) {
+	t.Parallel()
+
+	compressed := func(format string, level int, text string) (data string) {
+		c := &cCompression{format: format, level: level}
+		if format == CompressionXz {
+			c.check = 0x1 // CRC32
+		}
+		raw, err := c.compress([]byte(text))
+		So(err, ShouldBeNil)
+		data = string(raw)
+		return
+	}
+
+	Convey("Detection and Round Trips", t, func() {
+		So(detectCompression([]byte("plain text")), ShouldBeNil)
+
+		for _, tc := range []struct {
+			format string
+			level  int
+		}{
+			{CompressionGzip, gzip.BestCompression},
+			{CompressionGzip, gzip.BestSpeed},
+			{CompressionGzip, gzip.DefaultCompression},
+			{CompressionBzip2, 3},
+			{CompressionXz, 0},
+		} {
+			data := compressed(tc.format, tc.level, "hello world\n")
+			c := detectCompression([]byte(data))
+			So(c, ShouldNotBeNil)
+			So(c.format, ShouldEqual, tc.format)
+			So(c.level, ShouldEqual, tc.level)
+			if tc.format == CompressionXz {
+				So(c.check, ShouldEqual, 0x1)
+			}
+			text, err := c.decompress([]byte(data), 0)
+			So(err, ShouldBeNil)
+			So(string(text), ShouldEqual, "hello world\n")
+		}
+
+		data := compressed(CompressionGzip, gzip.DefaultCompression, strings.Repeat("a", 100))
+		_, err := detectCompression([]byte(data)).decompress([]byte(data), 99)
+		So(errors.Is(err, rpl.ErrLargeFile), ShouldBeTrue)
+	})
+
+	Convey("Worker Decompress", t, func() {
+		original := compressed(CompressionBzip2, 5, "secret=abc\nother\n")
+		m := NewMemFileSystem(map[string]string{
+			"/logs/app.log.bz2": original,
+			"/logs/app.log":     "secret=def\n",
+		})
+
+		w, err := New(
+			WithFileSystem(m),
+			WithQuiet(true),
+			WithSearch("secret", "REDACTED"),
+			WithPaths("/logs"),
+			WithRecurse(true),
+		)
+		So(err, ShouldBeNil)
+		So(w.InitTargets(nil), ShouldBeNil)
+		So(w.FindMatching(nil), ShouldBeNil)
+		So(w.Matched, ShouldEqual, []string{"/logs/app.log"})
+
+		w, err = New(
+			WithFileSystem(m),
+			WithQuiet(true),
+			WithSearch("secret", "REDACTED"),
+			WithPaths("/logs/app.log.bz2"),
+			WithBackupExtension(".bak"),
+			WithShowDiff(true),
+			WithDecompress(true),
+		)
+		So(err, ShouldBeNil)
+		So(w.InitTargets(nil), ShouldBeNil)
+		So(w.FindMatching(nil), ShouldBeNil)
+		So(w.Matched, ShouldEqual, []string{"/logs/app.log.bz2"})
+
+		iter := w.StartIterating()
+		count, unified, backup, err := iter.ApplyAll()
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 1)
+		So(unified, ShouldContainSubstring, "+REDACTED=abc\n")
+		So(backup, ShouldEqual, "/logs/app.log.bz2.bak")
+
+		data, _ := fs.ReadFile(m, "logs/app.log.bz2.bak")
+		So(string(data), ShouldEqual, original)
+		data, _ = fs.ReadFile(m, "logs/app.log.bz2")
+		c := detectCompression(data)
+		So(c, ShouldNotBeNil)
+		So(c.format, ShouldEqual, CompressionBzip2)
+		So(c.level, ShouldEqual, 5)
+		text, err := c.decompress(data, 0)
+		So(err, ShouldBeNil)
+		So(string(text), ShouldEqual, "REDACTED=abc\nother\n")
+	})
+}
@@ -0,0 +1,329 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/urfave/cli/v2"
+
+	"github.com/go-corelibs/path"
+)
+
+const (
+	// ConfigDirName is the name of the directory within $XDG_CONFIG_HOME
+	// containing the user ConfigFileName
+	ConfigDirName = "rpl"
+	// ConfigFileName is the name of the user configuration file
+	ConfigFileName = "config"
+	// ProjectConfigFileName is the name of the project configuration file,
+	// found by walking up from the current working directory
+	ProjectConfigFileName = ".rpl.toml"
+)
+
+// Config is the combination of the user and project configuration files.
+// Both files are TOML formatted, with top-level keys being the long names of
+// command-line flags, for example:
+//
+//	recurse = true
+//	exclude = ["vendor", "node_modules"]
+//	max-file-size = "10MiB"
+//
+//	[backup]
+//	extension = ".bak"
+//
+//	[exclude-sets]
+//	go = ["vendor", "*.pb.go"]
+//
+//	[presets.rename-api]
+//	regex = true
+//	include = ["*.go"]
+//
+// Project settings take precedence over user settings, with the exception of
+// lists (such as exclude) which are combined
+type Config struct {
+	// Files is the list of configuration files loaded, from the lowest to
+	// the highest precedence
+	Files []string
+	// Options are the default flag values
+	Options map[string]interface{}
+	// ExcludeSets are the named lists of exclude globs
+	ExcludeSets map[string][]string
+	// Presets are the named sets of flag values
+	Presets map[string]map[string]interface{}
+}
+
+// cBackupConfigKeys maps the [backup] table keys to their flag names
+var cBackupConfigKeys = map[string]string{
+	"enabled":   BackupFlag.Name,
+	"extension": BackupExtensionFlag.Name,
+	"template":  BackupTemplateFlag.Name,
+	"dir":       BackupDirFlag.Name,
+}
+
+// UserConfigFile returns the path to the user configuration file, which is
+// within $XDG_CONFIG_HOME (or $HOME/.config when not set)
+func UserConfigFile() (file string) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".config")
+		}
+	}
+	if dir != "" {
+		file = filepath.Join(dir, ConfigDirName, ConfigFileName)
+	}
+	return
+}
+
+// FindProjectConfigFile walks up from the directory given, returning the
+// first ProjectConfigFileName found
+func FindProjectConfigFile(dir string) (file string) {
+	var err error
+	if dir, err = filepath.Abs(dir); err != nil {
+		return
+	}
+	for {
+		if check := filepath.Join(dir, ProjectConfigFileName); path.IsFile(check) {
+			file = check
+			return
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return
+		}
+		dir = parent
+	}
+}
+
+// LoadConfig loads the user configuration file and the project configuration
+// file found from the directory given, neither file is required to exist
+func LoadConfig(dir string) (c *Config, err error) {
+	c = &Config{
+		Options:     make(map[string]interface{}),
+		ExcludeSets: make(map[string][]string),
+		Presets:     make(map[string]map[string]interface{}),
+	}
+	for _, file := range []string{UserConfigFile(), FindProjectConfigFile(dir)} {
+		if file == "" || !path.IsFile(file) {
+			continue
+		} else if err = c.load(file); err != nil {
+			c = nil
+			return
+		}
+	}
+	return
+}
+
+func (c *Config) load(file string) (err error) {
+	var data map[string]interface{}
+	if _, err = toml.DecodeFile(file, &data); err != nil {
+		err = fmt.Errorf("config %q: %w", file, err)
+		return
+	}
+
+	for key, value := range data {
+		table, isTable := value.(map[string]interface{})
+		switch key {
+
+		case "backup":
+			if !isTable {
+				err = fmt.Errorf("config %q: [backup] is not a table", file)
+				return
+			}
+			for setting, v := range table {
+				name, ok := cBackupConfigKeys[setting]
+				if !ok {
+					err = fmt.Errorf("config %q: unknown [backup] setting %q", file, setting)
+					return
+				}
+				if dir, ok := v.(string); ok && setting == "dir" && dir != "" && !filepath.IsAbs(dir) {
+					// relative to the configuration file
+					v = filepath.Join(filepath.Dir(file), dir)
+				}
+				c.Options[name] = v
+			}
+
+		case "exclude-sets":
+			if !isTable {
+				err = fmt.Errorf("config %q: [exclude-sets] is not a table", file)
+				return
+			}
+			for name, v := range table {
+				var globs []string
+				if globs, err = configValueStrings(v); err != nil {
+					err = fmt.Errorf("config %q: exclude-set %q %w", file, name, err)
+					return
+				}
+				c.ExcludeSets[name] = globs
+			}
+
+		case "presets":
+			if !isTable {
+				err = fmt.Errorf("config %q: [presets] is not a table", file)
+				return
+			}
+			for name, v := range table {
+				options, ok := v.(map[string]interface{})
+				if !ok {
+					err = fmt.Errorf("config %q: [presets.%s] is not a table", file, name)
+					return
+				}
+				c.Presets[name] = options
+			}
+
+		default:
+			c.Options[key] = mergeConfigValue(c.Options[key], value)
+		}
+	}
+
+	c.Files = append(c.Files, file)
+	return
+}
+
+// mergeConfigValue returns the value given, unless both are lists in which
+// case the lists are combined
+func mergeConfigValue(previous, value interface{}) (merged interface{}) {
+	if before, ok := previous.([]interface{}); ok {
+		if after, ok := value.([]interface{}); ok {
+			merged = append(append([]interface{}{}, before...), after...)
+			return
+		}
+	}
+	merged = value
+	return
+}
+
+// HasPreset returns true if the named preset is defined
+func (c *Config) HasPreset(name string) (ok bool) {
+	if c != nil {
+		_, ok = c.Presets[name]
+	}
+	return
+}
+
+// GetExcludeSet returns the named list of exclude globs
+func (c *Config) GetExcludeSet(name string) (globs []string, ok bool) {
+	if c != nil {
+		globs, ok = c.ExcludeSets[name]
+	}
+	return
+}
+
+// Apply sets the configured default values (and the named preset values, if
+// not empty) of all the flags not already set on the command-line
+func (c *Config) Apply(ctx *cli.Context, preset string) (err error) {
+	options := make(map[string]interface{})
+	for key, value := range c.Options {
+		options[key] = value
+	}
+	if preset != "" {
+		values, ok := c.Presets[preset]
+		if !ok {
+			err = fmt.Errorf("%w: preset %q", ErrNotFound, preset)
+			return
+		}
+		for key, value := range values {
+			options[key] = mergeConfigValue(options[key], value)
+		}
+	}
+
+	lookup := make(map[string]cli.Flag)
+	for _, flag := range ctx.App.Flags {
+		for _, name := range flag.Names() {
+			lookup[name] = flag
+		}
+	}
+
+	// sorted for consistent error reporting
+	var keys []string
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		flag, ok := lookup[key]
+		if !ok {
+			err = fmt.Errorf("config: unknown setting %q", key)
+			return
+		}
+		name := flag.Names()[0]
+		switch name {
+		case HelpFlag.Name, UsageFlag.Name, VersionFlag.Name, NoConfigFlag.Name:
+			err = fmt.Errorf("config: unsupported setting %q", key)
+			return
+		}
+		if ctx.IsSet(name) {
+			// command-line flags always win
+			continue
+		}
+		var values []string
+		if values, err = configValueStrings(options[key]); err != nil {
+			err = fmt.Errorf("config: setting %q %w", key, err)
+			return
+		}
+		if _, isSlice := flag.(*cli.StringSliceFlag); !isSlice && len(values) != 1 {
+			err = fmt.Errorf("config: setting %q does not accept a list", key)
+			return
+		}
+		for _, value := range values {
+			if err = ctx.Set(name, value); err != nil {
+				err = fmt.Errorf("config: setting %q %w", key, err)
+				return
+			}
+		}
+	}
+	return
+}
+
+func configValueStrings(value interface{}) (values []string, err error) {
+	switch v := value.(type) {
+	case string:
+		values = []string{v}
+	case bool:
+		values = []string{strconv.FormatBool(v)}
+	case int64:
+		values = []string{strconv.FormatInt(v, 10)}
+	case float64:
+		values = []string{strconv.FormatFloat(v, 'f', -1, 64)}
+	case []interface{}:
+		for _, item := range v {
+			var more []string
+			if more, err = configValueStrings(item); err != nil {
+				return
+			} else if len(more) != 1 {
+				err = fmt.Errorf("unsupported nested list")
+				return
+			}
+			values = append(values, more...)
+		}
+	default:
+		err = fmt.Errorf("unsupported value type %T", value)
+	}
+	return
+}
+
+// String returns a summary of the loaded configuration files
+func (c *Config) String() (summary string) {
+	summary = strings.Join(c.Files, ", ")
+	return
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/urfave/cli/v2"
+)
+
+func makeConfigContext(args ...string) (ctx *cli.Context) {
+	app := &cli.App{Flags: []cli.Flag{
+		BackupFlag, BackupExtensionFlag, BackupTemplateFlag, BackupDirFlag,
+		RecurseFlag, ExcludeFlag, IgnoreCaseFlag, MaxFilesFlag, MaxFileSizeFlag,
+		HelpFlag, NoConfigFlag,
+	}}
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, f := range app.Flags {
+		_ = f.Apply(set)
+	}
+	_ = set.Parse(args)
+	ctx = cli.NewContext(app, set, nil)
+	return
+}
+
+func TestConfig(t *testing.T) {
+
+	Convey("Load", t, func() {
+		tmp := t.TempDir()
+		xdg := filepath.Join(tmp, "xdg")
+		project := filepath.Join(tmp, "project")
+		nested := filepath.Join(project, "nested", "deeper")
+		So(os.MkdirAll(filepath.Join(xdg, ConfigDirName), 0755), ShouldEqual, nil)
+		So(os.MkdirAll(nested, 0755), ShouldEqual, nil)
+		t.Setenv("XDG_CONFIG_HOME", xdg)
+
+		So(UserConfigFile(), ShouldEqual, filepath.Join(xdg, ConfigDirName, ConfigFileName))
+		So(FindProjectConfigFile(nested), ShouldEqual, "")
+
+		c, err := LoadConfig(nested)
+		So(err, ShouldEqual, nil)
+		So(c.Files, ShouldHaveLength, 0)
+
+		So(os.WriteFile(UserConfigFile(), []byte(`
+exclude = ["*.log"]
+max-files = 10
+
+[backup]
+extension = ".bak"
+`), 0644), ShouldEqual, nil)
+		So(os.WriteFile(filepath.Join(project, ProjectConfigFileName), []byte(`
+recurse = true
+exclude = ["*.tmp"]
+max-files = 20
+
+[backup]
+dir = ".backups"
+
+[exclude-sets]
+deps = ["vendor/*", "node_modules/*"]
+
+[presets.loud]
+ignore-case = true
+exclude = ["*.out"]
+`), 0644), ShouldEqual, nil)
+		So(FindProjectConfigFile(nested), ShouldEqual, filepath.Join(project, ProjectConfigFileName))
+
+		c, err = LoadConfig(nested)
+		So(err, ShouldEqual, nil)
+		So(c.Files, ShouldEqual, []string{UserConfigFile(), filepath.Join(project, ProjectConfigFileName)})
+		So(c.Options["exclude"], ShouldEqual, []interface{}{"*.log", "*.tmp"})
+		So(c.Options["max-files"], ShouldEqual, int64(20))
+		So(c.Options[BackupDirFlag.Name], ShouldEqual, filepath.Join(project, ".backups"))
+		So(c.Options[BackupExtensionFlag.Name], ShouldEqual, ".bak")
+		So(c.HasPreset("loud"), ShouldEqual, true)
+		So(c.HasPreset("quiet"), ShouldEqual, false)
+		globs, ok := c.GetExcludeSet("deps")
+		So(ok, ShouldEqual, true)
+		So(globs, ShouldEqual, []string{"vendor/*", "node_modules/*"})
+
+		Convey("Apply", func() {
+			ctx := makeConfigContext()
+			So(c.Apply(ctx, ""), ShouldEqual, nil)
+			So(ctx.Bool(RecurseFlag.Name), ShouldEqual, true)
+			So(ctx.Int(MaxFilesFlag.Name), ShouldEqual, 20)
+			So(ctx.StringSlice(ExcludeFlag.Name), ShouldEqual, []string{"*.log", "*.tmp"})
+			So(ctx.Bool(IgnoreCaseFlag.Name), ShouldEqual, false)
+
+			ctx = makeConfigContext("-X", "*.txt", "--max-files", "5")
+			So(c.Apply(ctx, "loud"), ShouldEqual, nil)
+			So(ctx.Int(MaxFilesFlag.Name), ShouldEqual, 5)
+			So(ctx.StringSlice(ExcludeFlag.Name), ShouldEqual, []string{"*.txt"})
+			So(ctx.Bool(IgnoreCaseFlag.Name), ShouldEqual, true)
+
+			ctx = makeConfigContext()
+			So(c.Apply(ctx, "loud"), ShouldEqual, nil)
+			So(ctx.StringSlice(ExcludeFlag.Name), ShouldEqual, []string{"*.log", "*.tmp", "*.out"})
+
+			err = c.Apply(makeConfigContext(), "quiet")
+			So(errors.Is(err, ErrNotFound), ShouldEqual, true)
+		})
+
+		Convey("Invalid", func() {
+			c.Options["nope"] = true
+			So(c.Apply(makeConfigContext(), ""), ShouldNotEqual, nil)
+			delete(c.Options, "nope")
+			c.Options["help"] = true
+			So(c.Apply(makeConfigContext(), ""), ShouldNotEqual, nil)
+			delete(c.Options, "help")
+			c.Options["max-files"] = []interface{}{int64(1), int64(2)}
+			So(c.Apply(makeConfigContext(), ""), ShouldNotEqual, nil)
+
+			So(os.WriteFile(UserConfigFile(), []byte("[backup]\nnope = 1\n"), 0644), ShouldEqual, nil)
+			_, err = LoadConfig(nested)
+			So(err, ShouldNotEqual, nil)
+			So(os.WriteFile(UserConfigFile(), []byte("this is not toml"), 0644), ShouldEqual, nil)
+			_, err = LoadConfig(nested)
+			So(err, ShouldNotEqual, nil)
+		})
+	})
+}
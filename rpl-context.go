@@ -0,0 +1,190 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Context returns the context of the current run, which is cancelled by
+// Cancel or when the Timeout is exceeded (unless Interactive). Context returns the background
+// context when the Worker has not been initialized
+func (w *Worker) Context() (ctx context.Context) {
+	if ctx = w.ctx; ctx == nil {
+		ctx = context.Background()
+	}
+	return
+}
+
+// Cancel stops the current run, any searching in progress is abandoned and
+// no further files are written. Cancel is safe to call more than once
+func (w *Worker) Cancel() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+}
+
+// Cancelled returns the reason the Worker context is done, either
+// context.Canceled or context.DeadlineExceeded, or nil if still running
+func (w *Worker) Cancelled() (err error) {
+	err = w.Context().Err()
+	return
+}
+
+// Finished returns the list of matched files within the current batch which
+// had their changes applied, see FinishedCount for the total of all batches
+func (w *Worker) Finished() (files []string) {
+	files = w.finished
+	return
+}
+
+// FinishedCount returns the total number of matched files which had their
+// changes applied so far, across all batches
+func (w *Worker) FinishedCount() (count int) {
+	count = w.finishedCount
+	return
+}
+
+// Unfinished returns the list of files which were not completely processed,
+// either because the FileTimeout was exceeded or the run stopped before the
+// file was searched or had its changes applied. Directories not yet walked are
+// included as-is
+func (w *Worker) Unfinished() (files []string) {
+	seen := make(map[string]struct{})
+	add := func(file string) {
+		if _, present := seen[file]; !present {
+			seen[file] = struct{}{}
+			files = append(files, file)
+		}
+	}
+
+	for _, file := range w.unfinished {
+		add(file)
+	}
+
+	done := make(map[string]struct{})
+	for _, file := range w.finished {
+		done[file] = struct{}{}
+	}
+
+	var start int
+	if w.iter != nil {
+		start = w.iter.pos
+	}
+	for idx := start; idx < len(w.Matched); idx++ {
+		if _, present := done[w.Matched[idx]]; !present {
+			add(w.Matched[idx])
+		}
+	}
+
+	if w.walker != nil {
+		for _, file := range w.walker.remaining() {
+			add(file)
+		}
+	}
+	return
+}
+
+// finish records the file given as having had its changes applied
+func (w *Worker) finish(file string) {
+	w.finished = append(w.finished, file)
+	w.finishedCount += 1
+}
+
+// findContext returns the context given to FindMatchingContext, used for
+// searching all subsequent batches
+func (w *Worker) findContext() (ctx context.Context) {
+	if ctx = w.findCtx; ctx == nil {
+		ctx = w.Context()
+	}
+	return
+}
+
+// runFileContext calls fn and waits for it to return or for the context given
+// to be done, limited to the FileTimeout when set. The fn is left running in
+// the background when abandoned, so it must not modify anything the caller
+// reads after an error is returned
+func (w *Worker) runFileContext(ctx context.Context, fn func()) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	fileCtx := ctx
+	if w.FileTimeout > 0 {
+		var cancel context.CancelFunc
+		fileCtx, cancel = context.WithTimeout(ctx, w.FileTimeout)
+		defer cancel()
+	}
+
+	if fileCtx.Done() == nil {
+		// never cancelled, no need for a goroutine
+		fn()
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-fileCtx.Done():
+		select {
+		case <-done:
+			// finished at the same time
+		default:
+			if err = ctx.Err(); err == nil {
+				err = fmt.Errorf("%w (%v)", ErrFileTimeout, w.FileTimeout)
+			}
+		}
+	}
+	return
+}
+
+// isContextErr returns true if the error is from a cancelled context
+func isContextErr(err error) (is bool) {
+	is = errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+	return
+}
+
+// remaining returns the paths which are yet to be walked, in the order they
+// would have been walked
+func (t *cTargetWalker) remaining() (paths []string) {
+	pending := func(target string) (ok bool) {
+		if t.w.isFile(target) {
+			ok = t.check(target)
+		} else {
+			ok = t.w.Recurse && t.w.isDir(target)
+		}
+		return
+	}
+	for idx := len(t.stack) - 1; idx >= 0; idx-- {
+		for _, target := range t.stack[idx] {
+			if pending(target) {
+				paths = append(paths, target)
+			}
+		}
+	}
+	for idx := t.index + 1; idx < len(t.w.Targets); idx++ {
+		if pending(t.w.Targets[idx]) {
+			paths = append(paths, t.w.Targets[idx])
+		}
+	}
+	return
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestContext(t *testing.T) {
+	m := &sync.Mutex{}
+
+	makeFiles := func() (dir string) {
+		dir = t.TempDir()
+		_ = os.Mkdir(filepath.Join(dir, "sub"), 0755)
+		for _, name := range []string{"a.txt", "b.txt", "c.txt", "sub/d.txt"} {
+			_ = os.WriteFile(filepath.Join(dir, name), []byte("hello world\n"), 0644)
+		}
+		return
+	}
+
+	Convey("Cancel Searching", t, func() {
+		m.Lock()
+		defer m.Unlock()
+		outio, errio, _ := makeWorker()
+		defer outio.Restore()
+		defer errio.Restore()
+
+		dir := makeFiles()
+		w, err := New(WithSearch("hello", "bye"), WithPaths(dir), WithRecurse(true))
+		So(err, ShouldBeNil)
+		So(w.InitTargets(nil), ShouldBeNil)
+
+		var searched []string
+		err = w.FindMatching(func(file string, matched bool, err error) {
+			if searched = append(searched, file); len(searched) == 2 {
+				w.Cancel()
+			}
+		})
+		So(errors.Is(err, context.Canceled), ShouldBeTrue)
+		So(w.Cancelled(), ShouldEqual, context.Canceled)
+		So(searched, ShouldHaveLength, 2)
+		So(w.Finished(), ShouldHaveLength, 0)
+		So(w.Unfinished(), ShouldEqual, []string{
+			filepath.Join(dir, "a.txt"),
+			filepath.Join(dir, "b.txt"),
+			filepath.Join(dir, "c.txt"),
+			filepath.Join(dir, "sub"),
+		})
+	})
+
+	Convey("Cancel Replacing", t, func() {
+		m.Lock()
+		defer m.Unlock()
+		outio, errio, _ := makeWorker()
+		defer outio.Restore()
+		defer errio.Restore()
+
+		dir := makeFiles()
+		w, err := New(WithSearch("hello", "bye"), WithPaths(dir), WithRecurse(true))
+		So(err, ShouldBeNil)
+		So(w.InitTargets(nil), ShouldBeNil)
+		So(w.FindMatching(nil), ShouldBeNil)
+
+		iter := w.StartIterating()
+		_, _, _, err = iter.ApplyAll()
+		So(err, ShouldBeNil)
+		iter.Next()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, _, _, err = iter.ApplyAllContext(ctx)
+		So(errors.Is(err, context.Canceled), ShouldBeTrue)
+		data, _ := os.ReadFile(filepath.Join(dir, "b.txt"))
+		So(string(data), ShouldEqual, "hello world\n")
+
+		w.Cancel()
+		_, _, _, err = iter.ApplyAll()
+		So(errors.Is(err, context.Canceled), ShouldBeTrue)
+		So(w.Finished(), ShouldEqual, []string{filepath.Join(dir, "a.txt")})
+		So(w.FinishedCount(), ShouldEqual, 1)
+		So(w.Unfinished(), ShouldEqual, []string{
+			filepath.Join(dir, "b.txt"),
+			filepath.Join(dir, "c.txt"),
+			filepath.Join(dir, "sub/d.txt"),
+		})
+	})
+
+	Convey("Finished Batches", t, func() {
+		m.Lock()
+		defer m.Unlock()
+		outio, errio, _ := makeWorker()
+		defer outio.Restore()
+		defer errio.Restore()
+
+		dir := makeFiles()
+		w, err := New(
+			WithSearch("hello", "bye"),
+			WithPaths(dir),
+			WithRecurse(true),
+			WithMaxFiles(1),
+		)
+		So(err, ShouldBeNil)
+		So(w.InitTargets(nil), ShouldBeNil)
+		So(w.FindMatching(nil), ShouldBeNil)
+
+		for {
+			iter := w.StartIterating()
+			So(iter, ShouldNotBeNil)
+			_, _, _, err = iter.ApplyAll()
+			So(err, ShouldBeNil)
+			// only the files of the current batch are kept
+			So(w.Finished(), ShouldEqual, w.Matched)
+			if !w.HasMoreBatches() {
+				break
+			} else if _, err = w.NextBatch(); err != nil || len(w.Matched) == 0 {
+				break
+			}
+		}
+		So(err, ShouldBeNil)
+		So(w.FinishedCount(), ShouldEqual, 4)
+		So(w.Unfinished(), ShouldHaveLength, 0)
+		data, _ := os.ReadFile(filepath.Join(dir, "sub", "d.txt"))
+		So(string(data), ShouldEqual, "bye world\n")
+	})
+
+	Convey("Timeouts", t, func() {
+		m.Lock()
+		defer m.Unlock()
+		outio, errio, _ := makeWorker()
+		defer outio.Restore()
+		defer errio.Restore()
+
+		w, err := New(WithSearch("hello", "bye"), WithTimeout(time.Nanosecond))
+		So(err, ShouldBeNil)
+		<-w.Context().Done()
+		So(w.Cancelled(), ShouldEqual, context.DeadlineExceeded)
+
+		// the user's review time is not limited
+		w, err = New(WithSearch("hello", "bye"), WithTimeout(time.Nanosecond), WithInteractive(true))
+		So(err, ShouldBeNil)
+		time.Sleep(time.Millisecond)
+		So(w.Cancelled(), ShouldBeNil)
+		_, hasDeadline := w.Context().Deadline()
+		So(hasDeadline, ShouldBeFalse)
+
+		w, err = New(WithSearch("hello", "bye"), WithFileTimeout(time.Millisecond))
+		So(err, ShouldBeNil)
+		err = w.runFileContext(context.Background(), func() {
+			time.Sleep(50 * time.Millisecond)
+		})
+		So(errors.Is(err, ErrFileTimeout), ShouldBeTrue)
+		So(w.runFileContext(context.Background(), func() {}), ShouldBeNil)
+
+		_, err = New(WithTimeout(-1))
+		So(err, ShouldNotBeNil)
+		_, err = New(WithFileTimeout(-1))
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,248 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// cWordStyle is the letter case of the words of an identifier
+type cWordStyle uint8
+
+const (
+	cLowerWords cWordStyle = iota
+	cUpperWords
+	cTitleWords
+	cCamelWords
+	cLowerCamelWords
+)
+
+// cConvention is the naming convention of a multi-word identifier, ie:
+// snake_case is cLowerWords with an underscore separator
+type cConvention struct {
+	style cWordStyle
+	sep   string
+}
+
+// gConventionSeparators are the word separators of the naming conventions
+const gConventionSeparators = "-_."
+
+// splitWords splits the identifier given into its words, breaking on any
+// separators and case changes, ie: "HTTPServer_config" is split into
+// "HTTP", "Server" and "config"
+func splitWords(input string) (words []string) {
+	for _, span := range wordSpans(input) {
+		words = append(words, input[span[0]:span[1]])
+	}
+	return
+}
+
+// wordSpans returns the byte ranges of the words of the input, see splitWords
+func wordSpans(input string) (spans [][2]int) {
+	start := -1
+	var prev rune
+	for pos, r := range input {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start >= 0 {
+				spans = append(spans, [2]int{start, pos})
+				start = -1
+			}
+			prev = r
+			continue
+		}
+		if start >= 0 && unicode.IsUpper(r) {
+			next, _ := utf8.DecodeRuneInString(input[pos+utf8.RuneLen(r):])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				(unicode.IsUpper(prev) && unicode.IsLower(next)) {
+				spans = append(spans, [2]int{start, pos})
+				start = -1
+			}
+		}
+		if start < 0 {
+			start = pos
+		}
+		prev = r
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(input)})
+	}
+	return
+}
+
+// canPreserveWords returns true if the input is only letters, digits and
+// convention separators
+func canPreserveWords(input string) (can bool) {
+	for _, r := range input {
+		if can = unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune(gConventionSeparators, r); !can {
+			return
+		}
+	}
+	return
+}
+
+// isConventionSearch returns true if the preserve-case search is a
+// multi-word identifier, to be matched in all naming conventions
+func (w *Worker) isConventionSearch() (ok bool) {
+	ok = w.PreserveCase && w.Pattern == nil &&
+		canPreserveWords(w.Search+w.Replace) &&
+		len(splitWords(w.Search)) > 1
+	return
+}
+
+// styleOf returns the style of the word given
+func styleOf(word string) (style cWordStyle, ok bool) {
+	first, size := utf8.DecodeRuneInString(word)
+	rest := word[size:]
+	switch {
+	case word == strings.ToLower(word):
+		style, ok = cLowerWords, true
+	case word == strings.ToUpper(word):
+		style, ok = cUpperWords, true
+	case unicode.IsUpper(first) && rest == strings.ToLower(rest):
+		style, ok = cTitleWords, true
+	}
+	return
+}
+
+// detectConvention returns the naming convention of the multi-word
+// identifier given, ok is false for single words and for identifiers with
+// mixed separators or letter cases
+func detectConvention(text string) (c cConvention, ok bool) {
+	for _, r := range text {
+		if strings.ContainsRune(gConventionSeparators, r) {
+			if c.sep == "" {
+				c.sep = string(r)
+			} else if c.sep != string(r) {
+				return
+			}
+		} else if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return
+		}
+	}
+
+	var words []string
+	if c.sep != "" {
+		words = strings.Split(text, c.sep)
+	} else {
+		words = splitWords(text)
+	}
+	if len(words) < 2 {
+		return
+	}
+
+	var styles []cWordStyle
+	for _, word := range words {
+		style, valid := styleOf(word)
+		if !valid {
+			return
+		}
+		styles = append(styles, style)
+	}
+
+	// all words must agree, except for the first word of lowerCamelCase
+	c.style = styles[1]
+	for _, style := range styles[2:] {
+		if style != c.style {
+			return
+		}
+	}
+	if c.sep == "" {
+		// without separators only the camel cases are distinguishable
+		switch {
+		case c.style == cTitleWords && styles[0] == cTitleWords:
+			c.style = cCamelWords
+		case c.style == cTitleWords && styles[0] == cLowerWords:
+			c.style = cLowerCamelWords
+		default:
+			return
+		}
+	} else if styles[0] != c.style {
+		return
+	}
+	ok = true
+	return
+}
+
+// apply returns the words given joined in the convention
+func (c cConvention) apply(words []string) (text string) {
+	title := func(word string) string {
+		first, size := utf8.DecodeRuneInString(word)
+		return string(unicode.ToUpper(first)) + strings.ToLower(word[size:])
+	}
+	converted := make([]string, len(words))
+	for idx, word := range words {
+		switch {
+		case c.style == cUpperWords:
+			converted[idx] = strings.ToUpper(word)
+		case c.style == cTitleWords || c.style == cCamelWords:
+			converted[idx] = title(word)
+		case c.style == cLowerCamelWords && idx > 0:
+			converted[idx] = title(word)
+		default:
+			converted[idx] = strings.ToLower(word)
+		}
+	}
+	text = strings.Join(converted, c.sep)
+	return
+}
+
+// isWordBoundary returns true if the match of content[start:end] is not part
+// of a larger word, separators and camel case changes are boundaries
+func isWordBoundary(content string, start, end int) (ok bool) {
+	isWordRune := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	if start > 0 {
+		before, _ := utf8.DecodeLastRuneInString(content[:start])
+		first, _ := utf8.DecodeRuneInString(content[start:])
+		if isWordRune(before) && !(unicode.IsUpper(first) && !unicode.IsUpper(before)) {
+			return
+		}
+	}
+	if end < len(content) {
+		after, _ := utf8.DecodeRuneInString(content[end:])
+		last, _ := utf8.DecodeLastRuneInString(content[:end])
+		if isWordRune(after) && !(unicode.IsUpper(after) && !unicode.IsUpper(last)) {
+			return
+		}
+	}
+	ok = true
+	return
+}
+
+// findConventionEdits finds the multi-word search identifier in any of the
+// naming conventions, replacing each instance with the replacement words in
+// the same convention, ie: searching for "user_account" with a replacement
+// of "member_profile" changes "UserAccount" to "MemberProfile" and
+// "user-account" to "member-profile"
+func findConventionEdits(casing cCasing, search, replace, content string) (edits []Edit) {
+	searchWords, replaceWords := splitWords(search), splitWords(replace)
+	quoted := make([]string, len(searchWords))
+	for idx, word := range searchWords {
+		quoted[idx] = regexp.QuoteMeta(word)
+	}
+	rx := regexp.MustCompile(`(?i)` + strings.Join(quoted, `[-_.]?`))
+	for _, m := range rx.FindAllStringIndex(content, -1) {
+		if !isWordBoundary(content, m[0], m[1]) {
+			continue
+		} else if c, ok := detectConvention(content[m[0]:m[1]]); ok {
+			edits = append(edits, Edit{Start: m[0], End: m[1], Text: casing.applyConvention(c, replaceWords)})
+		}
+	}
+	return
+}
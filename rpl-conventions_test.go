@@ -0,0 +1,93 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"regexp"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestConventions(t *testing.T) {
+	t.Parallel()
+
+	Convey("Words", t, func() {
+		So(splitWords("user_account"), ShouldEqual, []string{"user", "account"})
+		So(splitWords("HTTPServer-config"), ShouldEqual, []string{"HTTP", "Server", "config"})
+		So(splitWords("userAccount2Id"), ShouldEqual, []string{"user", "Account2", "Id"})
+		So(splitWords("hello"), ShouldEqual, []string{"hello"})
+	})
+
+	Convey("Detection", t, func() {
+		words := []string{"member", "profile"}
+		for input, expected := range map[string]string{
+			"user_account": "member_profile",
+			"USER_ACCOUNT": "MEMBER_PROFILE",
+			"user-account": "member-profile",
+			"USER-ACCOUNT": "MEMBER-PROFILE",
+			"user.account": "member.profile",
+			"User.Account": "Member.Profile",
+			"UserAccount":  "MemberProfile",
+			"userAccount":  "memberProfile",
+		} {
+			c, ok := detectConvention(input)
+			So(ok, ShouldBeTrue)
+			So(c.apply(words), ShouldEqual, expected)
+		}
+		for _, input := range []string{"useraccount", "USERACCOUNT", "user_Account", "user-account_id", "user account"} {
+			_, ok := detectConvention(input)
+			So(ok, ShouldBeFalse)
+		}
+	})
+
+	Convey("Replacements", t, func() {
+		content := "UserAccount user-account USER_ACCOUNT user.account\n" +
+			"getUserAccount my_user_account UserAccountID\n" +
+			"superuser_account user_accounts useraccount\n"
+		w := &Worker{Search: "user_account", Replace: "member_profile", PreserveCase: true}
+		So(w.findEdits("test", content).Modified(), ShouldEqual,
+			"MemberProfile member-profile MEMBER_PROFILE member.profile\n"+
+				"getMemberProfile my_member_profile MemberProfileID\n"+
+				"superuser_account user_accounts useraccount\n")
+
+		// single words are unchanged
+		w = &Worker{Search: "hello", Replace: "bye", PreserveCase: true}
+		So(w.isConventionSearch(), ShouldBeFalse)
+		So(w.findEdits("test", "Othello HELLO").Modified(), ShouldEqual, "Otbye BYE")
+
+		w = &Worker{Pattern: regexp.MustCompile(`(?i)user.account`), Replace: "member.profile", PreserveCase: true}
+		So(w.findEdits("test", "User.Account").Modified(), ShouldEqual, "Member.Profile")
+	})
+
+	Convey("Matching", t, func() {
+		m := NewMemFileSystem(map[string]string{
+			"/src/a.go": "type UserAccount struct{}\n",
+			"/src/b.go": "type Other struct{}\n",
+		})
+		w, err := New(
+			WithFileSystem(m),
+			WithQuiet(true),
+			WithSearch("user_account", "member_profile"),
+			WithPreserveCase(true),
+			WithPaths("/src"),
+			WithRecurse(true),
+		)
+		So(err, ShouldBeNil)
+		So(w.InitTargets(nil), ShouldBeNil)
+		So(w.FindMatching(nil), ShouldBeNil)
+		So(w.Matched, ShouldEqual, []string{"/src/a.go"})
+	})
+}
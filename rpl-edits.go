@@ -0,0 +1,420 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hexops/gotextdiff"
+
+	"github.com/go-corelibs/diff"
+	"github.com/go-corelibs/strcases"
+)
+
+// Edit is a single replacement of the original content between the Start and
+// End byte offsets with the Text given
+type Edit struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// Edits is a compact representation of all the replacements made to a file.
+// Only the match offsets and replacement text are stored, the modified
+// content and unified diff are rendered on demand
+type Edits struct {
+	path   string
+	source string
+	edits  []Edit
+}
+
+// NewEdits constructs a new Edits instance, the edits given must be sorted
+// and not overlap
+func NewEdits(path, source string, edits []Edit) (e *Edits) {
+	e = &Edits{
+		path:   path,
+		source: source,
+		edits:  edits,
+	}
+	return
+}
+
+// Path returns the file path the edits are for
+func (e *Edits) Path() (path string) {
+	path = e.path
+	return
+}
+
+// Source returns the original content
+func (e *Edits) Source() (source string) {
+	source = e.source
+	return
+}
+
+// Len returns the number of replacements
+func (e *Edits) Len() (count int) {
+	count = len(e.edits)
+	return
+}
+
+// Get returns the Edit at the index given
+func (e *Edits) Get(index int) (edit Edit, ok bool) {
+	if ok = index >= 0 && index < len(e.edits); ok {
+		edit = e.edits[index]
+	}
+	return
+}
+
+// Modified returns the original content with all the replacements applied
+func (e *Edits) Modified() (modified string) {
+	if len(e.edits) == 0 {
+		modified = e.source
+		return
+	}
+	var grow int
+	for _, edit := range e.edits {
+		grow += len(edit.Text) - (edit.End - edit.Start)
+	}
+	var buf strings.Builder
+	buf.Grow(len(e.source) + grow)
+	var last int
+	for _, edit := range e.edits {
+		buf.WriteString(e.source[last:edit.Start])
+		buf.WriteString(edit.Text)
+		last = edit.End
+	}
+	buf.WriteString(e.source[last:])
+	modified = buf.String()
+	return
+}
+
+// Diff computes the complete diff.Diff of the changes, which is necessary for
+// selectively applying groups of changes
+func (e *Edits) Diff() (delta *diff.Diff) {
+	delta = diff.New(e.path, e.source, e.Modified())
+	return
+}
+
+// Unified renders the unified diff of all replacements directly from the
+// match offsets, without computing a complete diff.Diff
+func (e *Edits) Unified() (unified string) {
+	if len(e.edits) == 0 {
+		return
+	} else if e.source == "" {
+		unified = e.Diff().UnifiedEdits()
+		return
+	}
+	a, b := "a", "b"
+	if e.path != "" && e.path[0] != '/' {
+		a += "/"
+		b += "/"
+	}
+	u := newLineIndex(e.source).unified(a+e.path, b+e.path, e.changes())
+	unified = fmt.Sprint(u)
+	return
+}
+
+// cLineChange is a range of whole lines replaced with new content
+type cLineChange struct {
+	from, to int // line indexes, to is exclusive
+	edits    []Edit
+}
+
+// changes groups the edits into whole-line changes, extending the changes so
+// that the new content always ends with a newline (or the end of the source)
+func (e *Edits) changes() (changes []cLineChange) {
+	idx := newLineIndex(e.source)
+	var cur *cLineChange
+	for _, edit := range e.edits {
+		first := idx.lineOf(edit.Start)
+		last := first
+		if edit.End > edit.Start {
+			last = idx.lineOf(edit.End - 1)
+		}
+		if cur != nil && first < cur.to {
+			if last+1 > cur.to {
+				cur.to = last + 1
+			}
+			cur.edits = append(cur.edits, edit)
+		} else {
+			if cur != nil {
+				changes = append(changes, *cur)
+			}
+			cur = &cLineChange{from: first, to: last + 1, edits: []Edit{edit}}
+		}
+		for cur.to < idx.count() && !idx.endsWithNewline(cur) {
+			cur.to += 1
+		}
+	}
+	if cur != nil {
+		changes = append(changes, *cur)
+	}
+	return
+}
+
+type cLineIndex struct {
+	source string
+	starts []int
+}
+
+func newLineIndex(source string) (idx *cLineIndex) {
+	idx = &cLineIndex{source: source, starts: []int{0}}
+	for i := 0; i < len(source); i++ {
+		if source[i] == '\n' && i+1 < len(source) {
+			idx.starts = append(idx.starts, i+1)
+		}
+	}
+	return
+}
+
+func (idx *cLineIndex) count() (count int) {
+	count = len(idx.starts)
+	return
+}
+
+func (idx *cLineIndex) lineOf(offset int) (line int) {
+	line = sort.Search(len(idx.starts), func(i int) bool {
+		return idx.starts[i] > offset
+	}) - 1
+	return
+}
+
+func (idx *cLineIndex) start(line int) (offset int) {
+	offset = idx.starts[line]
+	return
+}
+
+func (idx *cLineIndex) end(line int) (offset int) {
+	if line+1 < len(idx.starts) {
+		offset = idx.starts[line+1]
+	} else {
+		offset = len(idx.source)
+	}
+	return
+}
+
+func (idx *cLineIndex) line(line int) (text string) {
+	text = idx.source[idx.start(line):idx.end(line)]
+	return
+}
+
+// endsWithNewline reports if the new content of the change ends with a newline
+// character, or is empty
+func (idx *cLineIndex) endsWithNewline(c *cLineChange) (ok bool) {
+	pos, first := idx.end(c.to-1), idx.start(c.from)
+	for i := len(c.edits) - 1; i >= 0; i-- {
+		edit := c.edits[i]
+		if edit.End < pos {
+			break
+		} else if edit.Text != "" {
+			return strings.HasSuffix(edit.Text, "\n")
+		}
+		pos = edit.Start
+	}
+	ok = pos == first || idx.source[pos-1] == '\n'
+	return
+}
+
+// text returns the new content of the change
+func (idx *cLineIndex) text(c cLineChange) (text string) {
+	var buf strings.Builder
+	last := idx.start(c.from)
+	for _, edit := range c.edits {
+		buf.WriteString(idx.source[last:edit.Start])
+		buf.WriteString(edit.Text)
+		last = edit.End
+	}
+	buf.WriteString(idx.source[last:idx.end(c.to-1)])
+	text = buf.String()
+	return
+}
+
+// unified builds the hunks in the same manner as gotextdiff.ToUnified
+func (idx *cLineIndex) unified(from, to string, changes []cLineChange) (u gotextdiff.Unified) {
+	const edge, gap = 3, 6
+	u.From, u.To = from, to
+
+	addEqualLines := func(h *gotextdiff.Hunk, start, end int) (delta int) {
+		for i := start; i < end; i++ {
+			if i < 0 {
+				continue
+			} else if i >= idx.count() {
+				return
+			}
+			h.Lines = append(h.Lines, gotextdiff.Line{Kind: gotextdiff.Equal, Content: idx.line(i)})
+			delta += 1
+		}
+		return
+	}
+
+	var h *gotextdiff.Hunk
+	var last, toLine int
+	for _, c := range changes {
+		switch {
+		case h != nil && c.from == last:
+			// direct extension
+		case h != nil && c.from <= last+gap:
+			// within range of the previous lines, add the joiners
+			toLine += addEqualLines(h, last, c.from)
+		default:
+			// start a new hunk
+			if h != nil {
+				addEqualLines(h, last, last+edge)
+				u.Hunks = append(u.Hunks, h)
+			}
+			toLine += c.from - last
+			h = &gotextdiff.Hunk{FromLine: c.from + 1, ToLine: toLine + 1}
+			delta := addEqualLines(h, c.from-edge, c.from)
+			h.FromLine -= delta
+			h.ToLine -= delta
+		}
+		last = c.from
+		for i := c.from; i < c.to; i++ {
+			h.Lines = append(h.Lines, gotextdiff.Line{Kind: gotextdiff.Delete, Content: idx.line(i)})
+			last += 1
+		}
+		if text := idx.text(c); text != "" {
+			lines := strings.SplitAfter(text, "\n")
+			if lines[len(lines)-1] == "" {
+				lines = lines[:len(lines)-1]
+			}
+			for _, line := range lines {
+				h.Lines = append(h.Lines, gotextdiff.Line{Kind: gotextdiff.Insert, Content: line})
+				toLine += 1
+			}
+		}
+	}
+	if h != nil {
+		addEqualLines(h, last, last+edge)
+		u.Hunks = append(u.Hunks, h)
+	}
+	return
+}
+
+// findEdits computes the replacements for the content given, using the search
+// mode configured on the Worker
+func (w *Worker) findEdits(path, content string) (e *Edits) {
+	e = NewEdits(path, content, w.findContentEdits(content))
+	return
+}
+
+// findContentEdits computes the replacement edits for the content given
+func (w *Worker) findContentEdits(content string) (edits []Edit) {
+	if w.isFolding() {
+		edits = w.findFoldedEdits(content)
+	} else if w.Pattern != nil {
+		if w.PreserveCase {
+			edits = findRegexPreserveEdits(w.casing, w.Pattern, w.replacement(), content)
+		} else if w.MultiLine {
+			edits = findRegexEdits(w.Pattern, w.replacement(), content, 0)
+		} else {
+			edits = findRegexLinesEdits(w.Pattern, w.replacement(), content)
+		}
+	} else if w.Search != "" && w.Search != w.replacement() {
+		if w.LooseWhitespace {
+			edits = w.findLooseEdits(content)
+		} else if w.isConventionSearch() {
+			edits = findConventionEdits(w.casing, w.Search, w.replacement(), content)
+		} else if w.PreserveCase && strcases.CanPreserve(w.Search+w.Replace) {
+			rx := regexp.MustCompile(`(?i)` + regexp.QuoteMeta(w.Search))
+			edits = findPreserveEdits(w.casing, rx, w.replacement(), content)
+		} else if w.PreserveCase {
+			edits = findStringEdits(w.Search, w.replacement(), content)
+		} else if w.IgnoreCase {
+			rx := regexp.MustCompile(`(?i)` + regexp.QuoteMeta(w.Search))
+			edits = findLiteralEdits(rx, w.replacement(), content)
+		} else {
+			edits = findStringEdits(w.Search, w.replacement(), content)
+		}
+	}
+	return
+}
+
+func findStringEdits(search, replace, content string) (edits []Edit) {
+	for start := 0; start <= len(content); {
+		j := strings.Index(content[start:], search)
+		if j < 0 {
+			break
+		}
+		j += start
+		edits = append(edits, Edit{Start: j, End: j + len(search), Text: replace})
+		start = j + len(search)
+	}
+	return
+}
+
+func findLiteralEdits(search *regexp.Regexp, replace, content string) (edits []Edit) {
+	for _, m := range search.FindAllStringIndex(content, -1) {
+		edits = append(edits, Edit{Start: m[0], End: m[1], Text: replace})
+	}
+	return
+}
+
+func findPreserveEdits(casing cCasing, search *regexp.Regexp, replace, content string) (edits []Edit) {
+	d := strcases.NewCaseDetector()
+	for _, m := range search.FindAllStringIndex(content, -1) {
+		c := d.Detect(content[m[0]:m[1]])
+		edits = append(edits, Edit{Start: m[0], End: m[1], Text: casing.applyCase(c, replace, content, m[0], m[1])})
+	}
+	return
+}
+
+func findRegexEdits(search *regexp.Regexp, replace, content string, offset int) (edits []Edit) {
+	for _, m := range search.FindAllStringSubmatchIndex(content, -1) {
+		text := search.ExpandString(nil, replace, content, m)
+		edits = append(edits, Edit{Start: offset + m[0], End: offset + m[1], Text: string(text)})
+	}
+	return
+}
+
+func findRegexLinesEdits(search *regexp.Regexp, replace, content string) (edits []Edit) {
+	if content == "" {
+		edits = findRegexEdits(search, replace, content, 0)
+		return
+	}
+	for start := 0; start < len(content); {
+		end := strings.IndexByte(content[start:], '\n')
+		if end < 0 {
+			end = len(content)
+		} else {
+			end += start + 1
+		}
+		edits = append(edits, findRegexEdits(search, replace, content[start:end], start)...)
+		start = end
+	}
+	return
+}
+
+func findRegexPreserveEdits(casing cCasing, search *regexp.Regexp, replace, content string) (edits []Edit) {
+	d := strcases.NewCaseDetector()
+	for _, m := range search.FindAllStringIndex(content, -1) {
+		found := content[m[0]:m[1]]
+		c := d.Detect(found)
+		// the replacement may contain regex goodness, so must call
+		// ReplaceAllString on just the match to get the correct results
+		replaced := search.ReplaceAllString(found, replace)
+		if c == strcases.UnknownCase {
+			// such as dotted.case or Title_Snake_Case
+			if convention, ok := detectConvention(found); ok {
+				replaced = casing.applyConvention(convention, splitWords(replaced))
+			}
+		}
+		edits = append(edits, Edit{Start: m[0], End: m[1], Text: casing.applyCase(c, replaced, content, m[0], m[1])})
+	}
+	return
+}
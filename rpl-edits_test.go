@@ -0,0 +1,67 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"regexp"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	rpl "github.com/go-corelibs/replace"
+)
+
+func TestEdits(t *testing.T) {
+	Convey("Modified", t, func() {
+		contents := "one Hello\ntwo hello HELLO\nthree\n\nfour hello"
+		for _, test := range []struct {
+			w        *Worker
+			expected string
+		}{
+			{&Worker{Search: "hello", Replace: "olleh"}, func() string { m, _ := rpl.String("hello", "olleh", contents); return m }()},
+			{&Worker{Search: "hello", Replace: "olleh", IgnoreCase: true}, func() string { m, _ := rpl.StringInsensitive("hello", "olleh", contents); return m }()},
+			{&Worker{Search: "hello", Replace: "olleh", PreserveCase: true}, func() string { m, _ := rpl.StringPreserve("hello", "olleh", contents); return m }()},
+			{&Worker{Pattern: regexp.MustCompile(`(?m)^(\w+) `), Replace: "${1}_", MultiLine: true}, func() string { m, _ := rpl.Regex(regexp.MustCompile(`(?m)^(\w+) `), "${1}_", contents); return m }()},
+			{&Worker{Pattern: regexp.MustCompile(`o\n`), Replace: "0"}, func() string { m, _ := rpl.RegexLines(regexp.MustCompile(`o\n`), "0", contents); return m }()},
+			{&Worker{Pattern: regexp.MustCompile(`(?i)hel+o`), Replace: "bye", PreserveCase: true}, func() string { m, _ := rpl.RegexPreserve(regexp.MustCompile(`(?i)hel+o`), "bye", contents); return m }()},
+		} {
+			edits := test.w.findEdits("test.txt", contents)
+			So(edits.Modified(), ShouldEqual, test.expected)
+		}
+	})
+
+	Convey("Unified", t, func() {
+		for _, test := range []struct {
+			contents string
+			search   string
+			replace  string
+		}{
+			{"one hello\ntwo\n", "hello", "olleh"},
+			{"one hello\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten hello\n", "hello", "olleh"},
+			{"1\n2\n3\n4 hello\n5\n6\n7\n8\n9\n10\n11 hello\n12\n", "hello", "olleh"},
+			{"1\n2 hello\n3\n4\n5\n6\n7 hello\n8\n9\n10\n11\n12\n13\n14 hello\n", "hello", "olleh"},
+			{"no newline hello", "hello", "olleh"},
+			{"hello\nhello\nhello\n", "hello\n", ""},
+			{"a hello b\nc\n", "hello", "x\ny"},
+			{"a\nb hello\nc\n", " hello\n", " "},
+		} {
+			w := &Worker{Search: test.search, Replace: test.replace}
+			edits := w.findEdits("test.txt", test.contents)
+			delta := edits.Diff()
+			delta.KeepAll()
+			So(edits.Unified(), ShouldEqual, delta.UnifiedEdits())
+		}
+	})
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+// Event is the interface implemented by all the events emitted by a Worker,
+// use a type switch to handle specific events:
+//
+//	w.Subscribe(replace.ObserverFunc(func(event replace.Event) {
+//		switch e := event.(type) {
+//		case replace.FileWrittenEvent:
+//			fmt.Printf("wrote: %q\n", e.File)
+//		case replace.ErrorEvent:
+//			fmt.Printf("error: %q - %v\n", e.File, e.Err)
+//		}
+//	}))
+type Event interface {
+	// EventName returns the kebab-cased name of the event
+	EventName() string
+	// EventFile returns the file (or target) the event is about
+	EventFile() string
+}
+
+// TargetAddedEvent is emitted by InitTargets for each target added
+type TargetAddedEvent struct {
+	File string `json:"file"`
+}
+
+// ScanStartedEvent is emitted before searching a file
+type ScanStartedEvent struct {
+	File string `json:"file"`
+}
+
+// ScanFinishedEvent is emitted after searching a file, Err is the reason the
+// file was skipped, if it was
+type ScanFinishedEvent struct {
+	File    string `json:"file"`
+	Matched bool   `json:"matched"`
+	Err     error  `json:"-"`
+}
+
+// MatchFoundEvent is emitted when a file searched contains the search term
+type MatchFoundEvent struct {
+	File string `json:"file"`
+}
+
+// DiffComputedEvent is emitted when the changes to a matched file are
+// computed, Count is the number of replacements
+type DiffComputedEvent struct {
+	File  string `json:"file"`
+	Count int    `json:"count"`
+}
+
+// FileWrittenEvent is emitted when the changes to a file are written, Nop is
+// true when the file would otherwise have been written
+type FileWrittenEvent struct {
+	File string `json:"file"`
+	Nop  bool   `json:"nop,omitempty"`
+}
+
+// BackupCreatedEvent is emitted when a file is backed up before being
+// written, Nop is true when the backup would otherwise have been made
+type BackupCreatedEvent struct {
+	File   string `json:"file"`
+	Backup string `json:"backup"`
+	Nop    bool   `json:"nop,omitempty"`
+}
+
+// ErrorEvent is emitted for any error encountered with a specific file
+type ErrorEvent struct {
+	File string `json:"file"`
+	Err  error  `json:"-"`
+}
+
+func (e TargetAddedEvent) EventName() string   { return "target-added" }
+func (e ScanStartedEvent) EventName() string   { return "scan-started" }
+func (e ScanFinishedEvent) EventName() string  { return "scan-finished" }
+func (e MatchFoundEvent) EventName() string    { return "match-found" }
+func (e DiffComputedEvent) EventName() string  { return "diff-computed" }
+func (e FileWrittenEvent) EventName() string   { return "file-written" }
+func (e BackupCreatedEvent) EventName() string { return "backup-created" }
+func (e ErrorEvent) EventName() string         { return "error" }
+
+func (e TargetAddedEvent) EventFile() string   { return e.File }
+func (e ScanStartedEvent) EventFile() string   { return e.File }
+func (e ScanFinishedEvent) EventFile() string  { return e.File }
+func (e MatchFoundEvent) EventFile() string    { return e.File }
+func (e DiffComputedEvent) EventFile() string  { return e.File }
+func (e FileWrittenEvent) EventFile() string   { return e.File }
+func (e BackupCreatedEvent) EventFile() string { return e.File }
+func (e ErrorEvent) EventFile() string         { return e.File }
+
+// Observer receives the events emitted by a Worker, events are emitted
+// synchronously from whichever goroutine is doing the work
+type Observer interface {
+	Observe(event Event)
+}
+
+// ObserverFunc is a function implementing the Observer interface
+type ObserverFunc func(event Event)
+
+// Observe calls the ObserverFunc with the event given
+func (fn ObserverFunc) Observe(event Event) {
+	fn(event)
+}
+
+// Subscribe adds the Observer given to the list of observers notified of all
+// events, the returned function removes the Observer from the list
+func (w *Worker) Subscribe(observer Observer) (unsubscribe func()) {
+	w.observersLock.Lock()
+	defer w.observersLock.Unlock()
+	w.observerID += 1
+	id := w.observerID
+	w.observers = append(w.observers, cObserver{id: id, Observer: observer})
+	unsubscribe = func() {
+		w.observersLock.Lock()
+		defer w.observersLock.Unlock()
+		for idx, o := range w.observers {
+			if o.id == id {
+				w.observers = append(w.observers[:idx:idx], w.observers[idx+1:]...)
+				return
+			}
+		}
+	}
+	return
+}
+
+// emit notifies all observers of the event given, in the order they
+// subscribed
+func (w *Worker) emit(event Event) {
+	w.observersLock.RLock()
+	observers := w.observers
+	w.observersLock.RUnlock()
+	for _, o := range observers {
+		o.Observe(event)
+	}
+}
+
+type cObserver struct {
+	id int
+	Observer
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEvents(t *testing.T) {
+	m := &sync.Mutex{}
+
+	Convey("Observers", t, func() {
+		m.Lock()
+		defer m.Unlock()
+		outio, errio, _ := makeWorker()
+		defer outio.Restore()
+		defer errio.Restore()
+
+		dir := t.TempDir()
+		one := filepath.Join(dir, "one.txt")
+		two := filepath.Join(dir, "two.txt")
+		missing := filepath.Join(dir, "missing.txt")
+		_ = os.WriteFile(one, []byte("hello world\n"), 0644)
+		_ = os.WriteFile(two, []byte("goodbye world\n"), 0644)
+
+		var names []string
+		var events []Event
+		w, err := New(
+			WithSearch("hello", "bye"),
+			WithPaths(one, two, missing),
+			WithBackupExtension(".bak"),
+			WithObserver(ObserverFunc(func(event Event) {
+				names = append(names, event.EventName())
+				events = append(events, event)
+			})),
+		)
+		So(err, ShouldBeNil)
+
+		var count int
+		unsubscribe := w.Subscribe(ObserverFunc(func(event Event) {
+			count += 1
+		}))
+
+		So(w.InitTargets(nil), ShouldBeNil)
+		So(w.FindMatching(nil), ShouldBeNil)
+		unsubscribe()
+		for iter := w.StartIterating(); iter.Valid(); iter.Next() {
+			_, _, _, err = iter.ApplyAll()
+			So(err, ShouldBeNil)
+		}
+
+		So(names, ShouldEqual, []string{
+			"target-added",
+			"target-added",
+			"error",
+			"scan-started",
+			"scan-finished",
+			"match-found",
+			"scan-started",
+			"scan-finished",
+			"diff-computed",
+			"backup-created",
+			"file-written",
+		})
+		So(count, ShouldEqual, 8)
+
+		So(events[0], ShouldEqual, TargetAddedEvent{File: one})
+		So(events[2].EventFile(), ShouldEqual, missing)
+		So(errors.Is(events[2].(ErrorEvent).Err, ErrNotFound), ShouldBeTrue)
+		So(events[4], ShouldEqual, ScanFinishedEvent{File: one, Matched: true})
+		So(events[7], ShouldEqual, ScanFinishedEvent{File: two})
+		So(events[8], ShouldEqual, DiffComputedEvent{File: one, Count: 1})
+		So(events[9], ShouldEqual, BackupCreatedEvent{File: one, Backup: one + ".bak"})
+		So(events[10], ShouldEqual, FileWrittenEvent{File: one})
+	})
+}
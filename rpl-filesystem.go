@@ -0,0 +1,522 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	slashpath "path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing/fstest"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/maruel/natural"
+
+	"github.com/go-corelibs/path"
+	"github.com/go-corelibs/scanners"
+)
+
+// FileSystem is the interface used by a Worker for all file operations. The
+// fs.FS methods use slash-separated names relative to the root of the
+// FileSystem (the "/" directory), as with all fs.FS implementations, while the
+// Worker itself continues to use the paths given (absolute or relative to the
+// Getwd directory) for reporting
+type FileSystem interface {
+	fs.FS
+	// WriteFile creates or truncates the named file, with the permissions
+	// given, and writes the data to it
+	WriteFile(name string, data []byte, perm fs.FileMode) (err error)
+	// MkdirAll creates the named directory, along with any parents
+	MkdirAll(name string, perm fs.FileMode) (err error)
+	// Getwd returns the absolute path of the current working directory
+	Getwd() (dir string, err error)
+}
+
+// AppendFileSystem is a FileSystem which can append to files without reading
+// and writing them whole, both NewOSFileSystem and MemFileSystem implement it
+type AppendFileSystem interface {
+	FileSystem
+	// AppendFile creates the named file if it does not exist, with the
+	// permissions given, and appends the data to it
+	AppendFile(name string, data []byte, perm fs.FileMode) (err error)
+}
+
+// NewOSFileSystem returns the FileSystem of the actual operating system, which
+// is the default used by a Worker
+func NewOSFileSystem() (fsys FileSystem) {
+	fsys = cOSFileSystem{FS: os.DirFS("/")}
+	return
+}
+
+type cOSFileSystem struct {
+	fs.FS
+}
+
+func osName(name string) (path string) {
+	if name == "." {
+		path = "/"
+		return
+	}
+	path = "/" + name
+	return
+}
+
+func (c cOSFileSystem) WriteFile(name string, data []byte, perm fs.FileMode) (err error) {
+	if !fs.ValidPath(name) {
+		err = &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+		return
+	}
+	file := osName(name)
+	var stat os.FileInfo
+	if err = os.WriteFile(file, data, perm); err != nil {
+		return
+	} else if stat, err = os.Stat(file); err == nil && stat.Mode().Perm() != perm {
+		// os.WriteFile does not change the permissions of existing files
+		err = os.Chmod(file, perm)
+	}
+	return
+}
+
+func (c cOSFileSystem) AppendFile(name string, data []byte, perm fs.FileMode) (err error) {
+	if !fs.ValidPath(name) {
+		err = &fs.PathError{Op: "append", Path: name, Err: fs.ErrInvalid}
+		return
+	}
+	var fh *os.File
+	if fh, err = os.OpenFile(osName(name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm); err != nil {
+		return
+	}
+	if _, err = fh.Write(data); err != nil {
+		_ = fh.Close()
+		return
+	}
+	err = fh.Close()
+	return
+}
+
+func (c cOSFileSystem) MkdirAll(name string, perm fs.FileMode) (err error) {
+	if !fs.ValidPath(name) {
+		err = &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+		return
+	}
+	err = os.MkdirAll(osName(name), perm)
+	return
+}
+
+func (c cOSFileSystem) Getwd() (dir string, err error) {
+	dir, err = os.Getwd()
+	return
+}
+
+// MemFileSystem is an in-memory FileSystem, safe for concurrent use, for
+// running a Worker over virtual trees of files
+type MemFileSystem struct {
+	files fstest.MapFS
+	cwd   string
+
+	sync.RWMutex
+}
+
+// NewMemFileSystem returns a new MemFileSystem populated with the files given,
+// keyed by their slash-separated names relative to the root. Directories are
+// implied by the file names and the current working directory is the root
+func NewMemFileSystem(files map[string]string) (m *MemFileSystem) {
+	m = &MemFileSystem{
+		files: make(fstest.MapFS),
+		cwd:   "/",
+	}
+	now := time.Now()
+	for name, content := range files {
+		m.files[slashpath.Clean(strings.TrimPrefix(name, "/"))] = &fstest.MapFile{
+			Data:    []byte(content),
+			Mode:    0644,
+			ModTime: now,
+		}
+	}
+	return
+}
+
+func (m *MemFileSystem) Open(name string) (file fs.File, err error) {
+	m.RLock()
+	defer m.RUnlock()
+	file, err = m.files.Open(name)
+	return
+}
+
+func (m *MemFileSystem) Stat(name string) (info fs.FileInfo, err error) {
+	m.RLock()
+	defer m.RUnlock()
+	info, err = m.files.Stat(name)
+	return
+}
+
+func (m *MemFileSystem) ReadFile(name string) (data []byte, err error) {
+	m.RLock()
+	defer m.RUnlock()
+	data, err = m.files.ReadFile(name)
+	return
+}
+
+func (m *MemFileSystem) ReadDir(name string) (entries []fs.DirEntry, err error) {
+	m.RLock()
+	defer m.RUnlock()
+	entries, err = m.files.ReadDir(name)
+	return
+}
+
+func (m *MemFileSystem) WriteFile(name string, data []byte, perm fs.FileMode) (err error) {
+	if !fs.ValidPath(name) || name == "." {
+		err = &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	err = m.writeFile(name, data, perm)
+	return
+}
+
+func (m *MemFileSystem) AppendFile(name string, data []byte, perm fs.FileMode) (err error) {
+	if !fs.ValidPath(name) || name == "." {
+		err = &fs.PathError{Op: "append", Path: name, Err: fs.ErrInvalid}
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	if file, present := m.files[name]; present && file.Mode.IsRegular() {
+		// open files keep reading the previous MapFile
+		m.files[name] = &fstest.MapFile{
+			Data:    append(append([]byte{}, file.Data...), data...),
+			Mode:    file.Mode,
+			ModTime: time.Now(),
+		}
+		return
+	}
+	err = m.writeFile(name, data, perm)
+	return
+}
+
+// writeFile is WriteFile without locking
+func (m *MemFileSystem) writeFile(name string, data []byte, perm fs.FileMode) (err error) {
+	if dir := slashpath.Dir(name); dir != "." {
+		if info, ee := m.files.Stat(dir); ee != nil || !info.IsDir() {
+			err = &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+			return
+		}
+	}
+	if info, ee := m.files.Stat(name); ee == nil && info.IsDir() {
+		err = &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+		return
+	}
+	m.files[name] = &fstest.MapFile{
+		Data:    append([]byte{}, data...),
+		Mode:    perm.Perm(),
+		ModTime: time.Now(),
+	}
+	return
+}
+
+func (m *MemFileSystem) MkdirAll(name string, perm fs.FileMode) (err error) {
+	if !fs.ValidPath(name) {
+		err = &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	var current string
+	for _, part := range strings.Split(name, "/") {
+		if part == "." {
+			continue
+		}
+		current = slashpath.Join(current, part)
+		if info, ee := m.files.Stat(current); ee == nil {
+			if !info.IsDir() {
+				err = &fs.PathError{Op: "mkdir", Path: current, Err: errors.New("not a directory")}
+				return
+			}
+			continue
+		}
+		m.files[current] = &fstest.MapFile{
+			Mode:    fs.ModeDir | perm.Perm(),
+			ModTime: time.Now(),
+		}
+	}
+	return
+}
+
+func (m *MemFileSystem) Getwd() (dir string, err error) {
+	m.RLock()
+	defer m.RUnlock()
+	dir = m.cwd
+	return
+}
+
+// Chdir changes the current working directory, relative directories are
+// relative to the current working directory
+func (m *MemFileSystem) Chdir(dir string) (err error) {
+	m.Lock()
+	defer m.Unlock()
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(m.cwd, dir)
+	}
+	dir = filepath.Clean(dir)
+	name := strings.TrimPrefix(filepath.ToSlash(dir), "/")
+	if name == "" {
+		name = "."
+	}
+	if info, ee := m.files.Stat(name); ee != nil || !info.IsDir() {
+		err = &fs.PathError{Op: "chdir", Path: dir, Err: fs.ErrNotExist}
+		return
+	}
+	m.cwd = dir
+	return
+}
+
+// getFileSystem returns the Worker.FileSystem, or the OS FileSystem if not set
+func (w *Worker) getFileSystem() (fsys FileSystem) {
+	if fsys = w.FileSystem; fsys == nil {
+		fsys = gOSFileSystem
+	}
+	return
+}
+
+var gOSFileSystem = NewOSFileSystem()
+
+func (w *Worker) getwd() (dir string, err error) {
+	dir, err = w.getFileSystem().Getwd()
+	return
+}
+
+// abs returns the absolute path of the file given, relative paths are
+// relative to the FileSystem working directory
+func (w *Worker) abs(file string) (abs string, err error) {
+	if filepath.IsAbs(file) {
+		abs = filepath.Clean(file)
+		return
+	}
+	var cwd string
+	if cwd, err = w.getwd(); err == nil {
+		abs = filepath.Join(cwd, file)
+	}
+	return
+}
+
+// fsName returns the fs.FS name of the file given
+func (w *Worker) fsName(file string) (name string) {
+	abs, err := w.abs(file)
+	if err != nil {
+		abs = file
+	}
+	if name = strings.TrimPrefix(filepath.ToSlash(abs), "/"); name == "" {
+		name = "."
+	}
+	return
+}
+
+// fsError replaces the fs.FS name within any fs.PathError with the file given
+func fsError(err error, file string) error {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		return &fs.PathError{Op: pe.Op, Path: file, Err: pe.Err}
+	}
+	return err
+}
+
+func (w *Worker) stat(file string) (info fs.FileInfo, err error) {
+	if archive, member, ok := w.archiveMember(file); ok {
+		info, err = w.statArchiveMember(archive, member)
+		return
+	}
+	if info, err = fs.Stat(w.getFileSystem(), w.fsName(file)); err != nil {
+		err = fsError(err, file)
+	}
+	return
+}
+
+func (w *Worker) exists(file string) (exists bool) {
+	_, err := w.stat(file)
+	exists = err == nil
+	return
+}
+
+func (w *Worker) isFile(file string) (isFile bool) {
+	info, err := w.stat(file)
+	isFile = err == nil && !info.IsDir()
+	return
+}
+
+func (w *Worker) isDir(file string) (isDir bool) {
+	info, err := w.stat(file)
+	isDir = err == nil && info.IsDir()
+	return
+}
+
+// FileSize returns the size of the regular file given, zero for everything
+// else, including the size of archive members when the Worker.Archives option
+// is set
+func (w *Worker) FileSize(file string) (size int64) {
+	size = w.fileSize(file)
+	return
+}
+
+// fileSize returns the size of regular files, zero for everything else
+func (w *Worker) fileSize(file string) (size int64) {
+	if info, err := w.stat(file); err == nil && info.Mode().IsRegular() {
+		size = info.Size()
+	}
+	return
+}
+
+func (w *Worker) readFile(file string) (data []byte, err error) {
+	if archive, member, ok := w.archiveMember(file); ok {
+		data, err = w.readArchiveMember(archive, member)
+		return
+	}
+	if data, err = fs.ReadFile(w.getFileSystem(), w.fsName(file)); err != nil {
+		err = fsError(err, file)
+	}
+	return
+}
+
+// scanFileLines streams the lines of the file given to the fn given, see
+// scanners.ScanLines
+func (w *Worker) scanFileLines(file string, fn scanners.ScanLinesFn) (stopped bool, err error) {
+	var fh fs.File
+	if fh, err = w.getFileSystem().Open(w.fsName(file)); err != nil {
+		err = fsError(err, file)
+		return
+	}
+	defer func() { _ = fh.Close() }()
+	stopped = scanners.ScanLines(fh, fn)
+	return
+}
+
+// overwrite writes the content to the file given, keeping the permissions of
+// existing files
+func (w *Worker) overwrite(file, content string) (err error) {
+	perm := path.DefaultFilePerms
+	if info, ee := w.stat(file); ee == nil {
+		perm = info.Mode().Perm()
+	}
+	err = w.writeFile(file, []byte(content), perm)
+	return
+}
+
+func (w *Worker) writeFile(file string, data []byte, perm fs.FileMode) (err error) {
+	if archive, member, ok := w.archiveMember(file); ok {
+		// archive members keep their original permissions
+		err = w.writeArchiveMember(archive, member, data)
+		return
+	}
+	if err = w.getFileSystem().WriteFile(w.fsName(file), data, perm); err != nil {
+		err = fsError(err, file)
+	}
+	return
+}
+
+// copyFile copies the regular file src to dst, with the same permissions
+func (w *Worker) copyFile(src, dst string) (err error) {
+	var info fs.FileInfo
+	var data []byte
+	if info, err = w.stat(src); err != nil {
+		return
+	} else if !info.Mode().IsRegular() {
+		err = fmt.Errorf("not a regular file")
+		return
+	} else if data, err = w.readFile(src); err != nil {
+		return
+	}
+	err = w.writeFile(dst, data, info.Mode().Perm())
+	return
+}
+
+// appendFile appends the data to the file given, creating it with the
+// permissions given when it does not exist
+func (w *Worker) appendFile(file string, data []byte, perm fs.FileMode) (err error) {
+	fsys := w.getFileSystem()
+	if afs, ok := fsys.(AppendFileSystem); ok {
+		err = afs.AppendFile(w.fsName(file), data, perm)
+	} else {
+		// FileSystem writes replace the whole file
+		existing, _ := fs.ReadFile(fsys, w.fsName(file))
+		err = fsys.WriteFile(w.fsName(file), append(existing, data...), perm)
+	}
+	if err != nil {
+		err = fsError(err, file)
+	}
+	return
+}
+
+func (w *Worker) mkdirAll(dir string) (err error) {
+	if err = w.getFileSystem().MkdirAll(w.fsName(dir), path.DefaultPathPerms); err != nil {
+		err = fsError(err, dir)
+	}
+	return
+}
+
+// listFiles returns the files within the directory given, in the same order
+// as path.ListFiles
+func (w *Worker) listFiles(dir string) (files []string) {
+	files = w.listMatching(dir, false)
+	return
+}
+
+// listDirs returns the directories within the directory given, in the same
+// order as path.ListDirs
+func (w *Worker) listDirs(dir string) (dirs []string) {
+	dirs = w.listMatching(dir, true)
+	return
+}
+
+func (w *Worker) listMatching(dir string, dirs bool) (list []string) {
+	entries, err := fs.ReadDir(w.getFileSystem(), w.fsName(dir))
+	if err != nil {
+		return
+	}
+	var hidden, normal []string
+	for _, entry := range entries {
+		if entry.IsDir() != dirs {
+			continue
+		}
+		name := filepath.Clean(filepath.Join(dir, entry.Name()))
+		if path.IsHidden(entry.Name()) {
+			if w.All {
+				hidden = append(hidden, name)
+			}
+		} else {
+			normal = append(normal, name)
+		}
+	}
+	sort.Sort(natural.StringSlice(hidden))
+	sort.Sort(natural.StringSlice(normal))
+	list = append(hidden, normal...)
+	return
+}
+
+// isPlainText returns true if the file data given is detected as text
+func isPlainText(data []byte) (isPlain bool) {
+	kind := mimetype.Detect(data)
+	for ; kind != nil; kind = kind.Parent() {
+		if isPlain = kind.Is("text/plain"); isPlain {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	rpl "github.com/go-corelibs/replace"
+)
+
+func TestMemFileSystem(t *testing.T) {
+	t.Parallel()
+
+	Convey("Files and Directories", t, func() {
+		m := NewMemFileSystem(map[string]string{
+			"/src/one.txt": "one",
+		})
+
+		data, err := fs.ReadFile(m, "src/one.txt")
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "one")
+
+		err = m.WriteFile("missing/two.txt", []byte("two"), 0600)
+		So(errors.Is(err, fs.ErrNotExist), ShouldBeTrue)
+		So(m.WriteFile("src", []byte("two"), 0600), ShouldNotBeNil)
+		So(m.WriteFile("src/two.txt", []byte("two"), 0600), ShouldBeNil)
+		info, err := fs.Stat(m, "src/two.txt")
+		So(err, ShouldBeNil)
+		So(info.Mode().Perm(), ShouldEqual, fs.FileMode(0600))
+
+		So(m.MkdirAll("src/one.txt/nope", 0755), ShouldNotBeNil)
+		So(m.MkdirAll("src/a/b", 0755), ShouldBeNil)
+		info, err = fs.Stat(m, "src/a")
+		So(err, ShouldBeNil)
+		So(info.IsDir(), ShouldBeTrue)
+		So(m.WriteFile("src/a/b/three.txt", []byte("three"), 0644), ShouldBeNil)
+
+		So(m.AppendFile("src/a/b/three.txt", []byte("+"), 0600), ShouldBeNil)
+		So(m.AppendFile("src/a/b/four.txt", []byte("four"), 0600), ShouldBeNil)
+		So(m.AppendFile("src/a", []byte("four"), 0600), ShouldNotBeNil)
+		data, _ = fs.ReadFile(m, "src/a/b/three.txt")
+		So(string(data), ShouldEqual, "three+")
+		data, _ = fs.ReadFile(m, "src/a/b/four.txt")
+		So(string(data), ShouldEqual, "four")
+
+		So(m.Chdir("nope"), ShouldNotBeNil)
+		So(m.Chdir("src"), ShouldBeNil)
+		So(m.Chdir("a"), ShouldBeNil)
+		cwd, _ := m.Getwd()
+		So(cwd, ShouldEqual, "/src/a")
+		So(m.Chdir("/"), ShouldBeNil)
+		cwd, _ = m.Getwd()
+		So(cwd, ShouldEqual, "/")
+	})
+}
+
+func TestWorkerFileSystem(t *testing.T) {
+	t.Parallel()
+
+	Convey("Searching", t, func() {
+		m := NewMemFileSystem(map[string]string{
+			"/src/one.txt":       "hello world\n",
+			"/src/two.txt":       "goodbye world\n",
+			"/src/.hidden.txt":   "hello hidden\n",
+			"/src/sub/three.txt": "hello again\n",
+			"/src/binary.bin":    "hello\x00\x01\x02\x03",
+		})
+		So(m.Chdir("/src"), ShouldBeNil)
+
+		w, err := New(
+			WithFileSystem(m),
+			WithQuiet(true),
+			WithSearch("hello", "bye"),
+			WithPaths("."),
+			WithRecurse(true),
+		)
+		So(err, ShouldBeNil)
+		So(w.InitTargets(nil), ShouldBeNil)
+
+		var skipped []string
+		So(w.FindMatching(func(file string, matched bool, err error) {
+			if errors.Is(err, rpl.ErrBinaryFile) {
+				skipped = append(skipped, file)
+			}
+		}), ShouldBeNil)
+		So(w.Matched, ShouldEqual, []string{"/src/one.txt", "/src/sub/three.txt"})
+		So(skipped, ShouldEqual, []string{"/src/binary.bin"})
+	})
+
+	Convey("Replacing", t, func() {
+		m := NewMemFileSystem(map[string]string{
+			"/src/one.txt":     "hello world\n",
+			"/src/one.txt.bak": "older backup\n",
+			"/src/two.txt":     "hello there\n",
+		})
+
+		w, err := New(
+			WithFileSystem(m),
+			WithQuiet(true),
+			WithSearch("hello", "bye"),
+			WithPaths("/src/one.txt", "/src/two.txt"),
+			WithBackupExtension(".bak"),
+		)
+		So(err, ShouldBeNil)
+		So(w.InitTargets(nil), ShouldBeNil)
+		So(w.FindMatching(nil), ShouldBeNil)
+
+		var backups []string
+		for iter := w.StartIterating(); iter.Valid(); iter.Next() {
+			_, _, backup, ee := iter.ApplyAll()
+			So(ee, ShouldBeNil)
+			backups = append(backups, backup)
+		}
+		So(backups, ShouldEqual, []string{"/src/one.txt.1.bak", "/src/two.txt.bak"})
+
+		data, _ := fs.ReadFile(m, "src/one.txt")
+		So(string(data), ShouldEqual, "bye world\n")
+		data, _ = fs.ReadFile(m, "src/one.txt.1.bak")
+		So(string(data), ShouldEqual, "hello world\n")
+		data, _ = fs.ReadFile(m, "src/one.txt.bak")
+		So(string(data), ShouldEqual, "older backup\n")
+		data, _ = fs.ReadFile(m, "src/two.txt")
+		So(string(data), ShouldEqual, "bye there\n")
+	})
+
+	Convey("Backup Directories", t, func() {
+		m := NewMemFileSystem(map[string]string{
+			"/src/one.txt": "hello world\n",
+		})
+		So(m.Chdir("/src"), ShouldBeNil)
+
+		w, err := New(
+			WithFileSystem(m),
+			WithQuiet(true),
+			WithSearch("hello", "bye"),
+			WithPaths("one.txt"),
+			WithBackupDir("/backups"),
+		)
+		So(err, ShouldBeNil)
+		So(w.InitTargets(nil), ShouldBeNil)
+		So(w.FindMatching(nil), ShouldBeNil)
+
+		iter := w.StartIterating()
+		So(iter.Valid(), ShouldBeTrue)
+		_, _, backup, err := iter.ApplyAll()
+		So(err, ShouldBeNil)
+		So(backup, ShouldEqual, "/backups/"+w.RunID+"/one.txt")
+
+		data, _ := fs.ReadFile(m, "backups/"+w.RunID+"/one.txt")
+		So(string(data), ShouldEqual, "hello world\n")
+		data, _ = fs.ReadFile(m, "backups/"+w.RunID+BackupManifestExtension)
+		So(string(data), ShouldContainSubstring, `"original":"/src/one.txt"`)
+	})
+}
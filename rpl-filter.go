@@ -0,0 +1,88 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+const (
+	// FilterName is the name used for the content read in --filter mode, in
+	// events and within the unified diff
+	FilterName = "-"
+)
+
+// FilterContent reads all the content from the reader given, replaces the
+// search term in the same manner as with files and writes the result to the
+// writer given. The unified diff is only rendered when the Worker.ShowDiff
+// option is set. The content is passed through unchanged when the Worker.Nop
+// option is set and nothing is written when the Worker is cancelled or times
+// out
+func (w *Worker) FilterContent(r io.Reader, o io.Writer) (count int, unified string, err error) {
+	count, unified, err = w.FilterContentContext(w.Context(), r, o)
+	return
+}
+
+// FilterContentContext is FilterContent limited by the context given
+func (w *Worker) FilterContentContext(ctx context.Context, r io.Reader, o io.Writer) (count int, unified string, err error) {
+	var edits *Edits
+	var codec *cCompression
+	var raw []byte
+	var ee error
+	if err = w.runFileContext(ctx, func() {
+		var text []byte
+		if raw, ee = io.ReadAll(r); ee != nil {
+			return
+		} else if text, codec, ee = w.decodeText(raw); ee == nil {
+			if edits, ee = w.findScopedEdits(FilterName, string(text)); ee == nil {
+				w.expandVariables(edits)
+			}
+		}
+	}); err != nil {
+		w.emit(ErrorEvent{File: FilterName, Err: err})
+		return
+	} else if err = ee; err != nil {
+		err = fmt.Errorf("--%s %w", FilterFlag.Name, err)
+		w.emit(ErrorEvent{File: FilterName, Err: err})
+		return
+	}
+
+	count = edits.Len()
+	w.emit(DiffComputedEvent{File: FilterName, Count: count})
+	if w.ShowDiff {
+		unified = w.FormatPatch(FilterName, edits.Unified())
+	}
+
+	var output string
+	if w.Nop {
+		// pass the content through unchanged
+		output = string(raw)
+	} else if output, err = encodeText(codec, edits.Modified()); err != nil {
+		err = fmt.Errorf("--%s %w", FilterFlag.Name, err)
+		w.emit(ErrorEvent{File: FilterName, Err: err})
+		return
+	}
+	if err = ctx.Err(); err != nil {
+		return
+	} else if _, err = io.WriteString(o, output); err != nil {
+		err = fmt.Errorf("--%s %w", FilterFlag.Name, err)
+		w.emit(ErrorEvent{File: FilterName, Err: err})
+		return
+	}
+	w.emit(FileWrittenEvent{File: FilterName, Nop: w.Nop})
+	return
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+
+	filter := func(content string, options ...Option) (output string, count int, unified string, err error) {
+		var w *Worker
+		if w, err = New(append([]Option{WithQuiet(true), WithFilter(true)}, options...)...); err != nil {
+			return
+		}
+		var buf strings.Builder
+		count, unified, err = w.FilterContent(strings.NewReader(content), &buf)
+		output = buf.String()
+		return
+	}
+
+	Convey("Search Modes", t, func() {
+		output, count, unified, err := filter("hello world\nhello there\n", WithSearch("hello", "bye"))
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 2)
+		So(unified, ShouldEqual, "")
+		So(output, ShouldEqual, "bye world\nbye there\n")
+
+		output, count, _, err = filter("a1 a2\nb3\n", WithSearch(`a(.)`, `b${1}`), WithRegex(true))
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 2)
+		So(output, ShouldEqual, "b1 b2\nb3\n")
+
+		output, _, _, err = filter("Hello hello HELLO", WithSearch("hello", "bye"), WithPreserveCase(true))
+		So(err, ShouldBeNil)
+		So(output, ShouldEqual, "Bye bye BYE")
+
+		output, count, _, err = filter("no matches\n", WithSearch("hello", "bye"))
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 0)
+		So(output, ShouldEqual, "no matches\n")
+	})
+
+	Convey("Diffs and Nop", t, func() {
+		output, count, unified, err := filter("hello world\n", WithSearch("hello", "bye"), WithShowDiff(true), WithNop(true))
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 1)
+		So(output, ShouldEqual, "hello world\n")
+		So(unified, ShouldEqual, "--- a/-\n+++ b/-\n@@ -1 +1 @@\n-hello world\n+bye world\n")
+	})
+
+	Convey("Cancelled", t, func() {
+		w, err := New(WithQuiet(true), WithFilter(true), WithSearch("hello", "bye"))
+		So(err, ShouldBeNil)
+		w.Cancel()
+		var buf strings.Builder
+		_, _, err = w.FilterContent(strings.NewReader("hello"), &buf)
+		So(errors.Is(err, context.Canceled), ShouldBeTrue)
+		So(buf.String(), ShouldEqual, "")
+	})
+
+	Convey("Conflicts", t, func() {
+		_, err := New(WithFilter(true), WithSearch("a", "b"), WithPaths("."))
+		So(err, ShouldNotBeNil)
+		_, err = New(WithFilter(true), WithArgv("a", "b", "-"))
+		So(err, ShouldNotBeNil)
+		_, err = New(WithFilter(true), WithSearch("a", "b"), WithInteractive(true))
+		So(err, ShouldNotBeNil)
+		_, err = New(WithFilter(true), WithApplyPatch("changes.patch"))
+		So(err, ShouldNotBeNil)
+		w, err := New(WithFilter(true), WithArgv("a", "b"))
+		So(err, ShouldBeNil)
+		So(w.Paths, ShouldBeEmpty)
+	})
+}
@@ -15,9 +15,11 @@
 package replace
 
 import (
+	"github.com/dustin/go-humanize"
 	"github.com/urfave/cli/v2"
 
 	clcli "github.com/go-corelibs/cli"
+	rpl "github.com/go-corelibs/replace"
 	"github.com/go-curses/cdk"
 )
 
@@ -40,6 +42,47 @@ var (
 		Name: "backup-extension", Aliases: []string{"B"},
 		Usage: "specify the backup file suffix to use (implies -b)",
 	}
+	BackupTemplateFlag = &cli.StringFlag{Category: BackupsCategory,
+		Name:  "backup-template",
+		Usage: "specify the backup file name template to use (implies -b)",
+	}
+	BackupDirFlag = &cli.StringFlag{Category: BackupsCategory,
+		Name:  "backup-dir",
+		Usage: "copy original files into DIR/<run-id>/<relative path> (implies -b)",
+	}
+	BackupListFlag = &cli.BoolFlag{Category: BackupsCategory,
+		Name:  "list-backups",
+		Usage: "list the original files within the paths given with their chain of backups",
+	}
+	BackupDiffFlag = &cli.BoolFlag{Category: BackupsCategory,
+		Name:  "diff-backup",
+		Usage: "show the differences between a backup and each of the files given",
+	}
+	BackupRestoreFlag = &cli.BoolFlag{Category: BackupsCategory,
+		Name:  "restore-backup",
+		Usage: "restore each of the files given from a backup generation",
+	}
+	BackupPruneFlag = &cli.BoolFlag{Category: BackupsCategory,
+		Name:  "prune-backups",
+		Usage: "remove old backup files within the paths given",
+	}
+	BackupGenerationFlag = &cli.IntFlag{Category: BackupsCategory,
+		Name: "generation", Aliases: []string{"g"},
+		Usage: "select the backup generation, the first backup made is zero (default: latest)",
+		Value: -1,
+	}
+	BackupKeepFlag = &cli.IntFlag{Category: BackupsCategory,
+		Name:  "keep",
+		Usage: "keep at least the given number of the newest backups",
+	}
+	BackupOlderThanFlag = &cli.StringFlag{Category: BackupsCategory,
+		Name:  "older-than",
+		Usage: "only prune backups older than the given age (ie: 36h, 7d, 2w)",
+	}
+	BackupNoBackupFlag = &cli.BoolFlag{Category: BackupsCategory,
+		Name:  "no-backup",
+		Usage: "do not backup the current file before restoring",
+	}
 
 	IgnoreCaseFlag = &cli.BoolFlag{Category: CaseSensitivityCategory,
 		Name: "ignore-case", Aliases: []string{"i"},
@@ -49,10 +92,72 @@ var (
 		Name: "preserve-case", Aliases: []string{"P"},
 		Usage: "try to preserve replacement string cases",
 	}
+	FoldFlag = &cli.StringFlag{Category: CaseSensitivityCategory,
+		Name:  "fold",
+		Usage: "case folding used when ignoring or preserving case: simple, full (ß matches SS) or turkic (I matches ı)",
+		Value: FoldSimple,
+	}
+	NormalizeFlag = &cli.StringFlag{Category: CaseSensitivityCategory,
+		Name:  "normalize",
+		Usage: "unicode normalization applied before matching: nfc or nfd, replacements keep the file's normalization",
+	}
+	CasingFlag = &cli.StringSliceFlag{Category: CaseSensitivityCategory,
+		Name:  "casing",
+		Usage: "canonical spellings of acronyms and brand names to use with --preserve-case, ie: GraphQL",
+	}
 
 	NoLimitsFlag = &cli.BoolFlag{Category: GeneralCategory,
 		Name: "no-limits", Aliases: []string{"U"},
-		Usage: "ignore max file count and size limits",
+		Usage: "ignore max file size limit and search all files in one batch",
+	}
+	MaxFileSizeFlag = &cli.StringFlag{Category: GeneralCategory,
+		Name:  "max-file-size",
+		Usage: "skip files larger than the given size (default: " + MaxFileSizeLabel + ")",
+	}
+	MaxFilesFlag = &cli.IntFlag{Category: GeneralCategory,
+		Name:  "max-files",
+		Usage: "search files in batches of at most the given number (default: " + humanize.Comma(int64(rpl.MaxFileCount)) + ")",
+	}
+	TimeoutFlag = &cli.DurationFlag{Category: GeneralCategory,
+		Name:  "timeout",
+		Usage: "stop searching and replacing after the given duration (ie: 30s, 5m), not applied with --interactive",
+	}
+	FileTimeoutFlag = &cli.DurationFlag{Category: GeneralCategory,
+		Name:  "file-timeout",
+		Usage: "skip any file taking longer than the given duration to search or replace",
+	}
+	ApplyPatchFlag = &cli.StringFlag{Category: GeneralCategory,
+		Name:  "apply-patch",
+		Usage: "apply a patch saved from the --show-diff output, instead of searching",
+	}
+	FuzzFlag = &cli.IntFlag{Category: GeneralCategory,
+		Name:  "fuzz",
+		Usage: "number of context lines which may be ignored with --apply-patch",
+		Value: DefaultPatchFuzz,
+	}
+	SearchFileFlag = &cli.StringFlag{Category: GeneralCategory,
+		Name:  "search-file",
+		Usage: "read the search argument from the file given, less any one trailing newline",
+	}
+	ReplaceFileFlag = &cli.StringFlag{Category: GeneralCategory,
+		Name:  "replace-file",
+		Usage: "read the replace argument from the file given, less any one trailing newline",
+	}
+	LooseWhitespaceFlag = &cli.BoolFlag{Category: GeneralCategory,
+		Name:  "loose-whitespace",
+		Usage: "any run of whitespace in the (non-regex) search matches any run of whitespace, including newlines",
+	}
+	DefineFlag = &cli.StringSliceFlag{Category: GeneralCategory,
+		Name: "define", Aliases: []string{"D"},
+		Usage: "define KEY=VALUE for use in the replacement as ${KEY}, along with ${FILE}, ${LINE} and others",
+	}
+	DecompressFlag = &cli.BoolFlag{Category: GeneralCategory,
+		Name:  "decompress",
+		Usage: "search within gzip, bzip2 and xz compressed files, recompressing any changes in the same format",
+	}
+	FilterFlag = &cli.BoolFlag{Category: GeneralCategory,
+		Name: "filter", Aliases: []string{"stdin-content"},
+		Usage: "replace within the content read from stdin and write the result to stdout, --show-diff is written to stderr",
 	}
 	NopFlag = &cli.BoolFlag{Category: GeneralCategory,
 		Name: "nope", Aliases: []string{"nop", "n"},
@@ -63,6 +168,11 @@ var (
 		Name: "show-diff", Aliases: []string{"d"},
 		Usage: "output unified diffs for all changes",
 	}
+	PatchFormatFlag = &cli.StringFlag{Category: UserInterfaceCategory,
+		Name:  "patch-format",
+		Usage: "format of the --show-diff output: " + PatchFormatUnified + " or " + PatchFormatGit,
+		Value: PatchFormatUnified,
+	}
 	InteractiveFlag = &cli.BoolFlag{Category: UserInterfaceCategory,
 		Name: "interactive", Aliases: []string{"e"},
 		Usage: "selectively apply changes per-file",
@@ -80,6 +190,10 @@ var (
 		Name: "all", Aliases: []string{"a"},
 		Usage: "include backups and files that start with a dot",
 	}
+	ArchivesFlag = &cli.BoolFlag{Category: TargetSelectionCategory,
+		Name:  "archives",
+		Usage: "search the members of tar and zip archives, named like: archive.zip!/path/in/archive",
+	}
 	NullFlag = &cli.BoolFlag{Category: TargetSelectionCategory,
 		Name: "null", Aliases: []string{"0"},
 		Usage: "read null-terminated paths from os.Stdin",
@@ -88,10 +202,34 @@ var (
 		Name: "file", Aliases: []string{"f"},
 		Usage: "read paths listed in files",
 	}
+	JSONPathFlag = &cli.StringSliceFlag{Category: TargetSelectionCategory,
+		Name:  "json-path",
+		Usage: "only replace within the JSON values at the key path, ie: services.*.image",
+	}
+	YAMLPathFlag = &cli.StringSliceFlag{Category: TargetSelectionCategory,
+		Name:  "yaml-path",
+		Usage: "only replace within the YAML values at the key path, ie: services.*.image",
+	}
+	TOMLPathFlag = &cli.StringSliceFlag{Category: TargetSelectionCategory,
+		Name:  "toml-path",
+		Usage: "only replace within the TOML values at the key path, ie: servers.*.host",
+	}
+	MarkupFlag = &cli.StringFlag{Category: TargetSelectionCategory,
+		Name:  "markup",
+		Usage: "only replace within the text content (text) or attribute values (attr[:name,...]) of HTML and XML files",
+	}
+	MarkdownFlag = &cli.StringFlag{Category: TargetSelectionCategory,
+		Name:  "markdown",
+		Usage: "only replace within the prose (prose) or code blocks and spans (code[:lang,...]) of markdown files",
+	}
 	ExcludeFlag = &cli.StringSliceFlag{Category: TargetSelectionCategory,
 		Name: "exclude", Aliases: []string{"X"},
 		Usage: "exclude files matching glob pattern",
 	}
+	ExcludeSetFlag = &cli.StringSliceFlag{Category: TargetSelectionCategory,
+		Name:  "exclude-set",
+		Usage: "exclude files matching the named set of globs from the config files",
+	}
 	IncludeFlag = &cli.StringSliceFlag{Category: TargetSelectionCategory,
 		Name: "include", Aliases: []string{"I"},
 		Usage: "include on files matching glob pattern",
@@ -118,6 +256,10 @@ var (
 		Name: "verbose", Aliases: []string{"v"},
 		Usage: "verbose notices",
 	}
+	NoConfigFlag = &cli.BoolFlag{Category: GeneralCategory,
+		Name:  "no-config",
+		Usage: "do not read the user or project config files",
+	}
 
 	UsageFlag = &cli.BoolFlag{Category: GeneralCategory,
 		Name: "usage", Aliases: []string{"h"},
@@ -0,0 +1,246 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/go-corelibs/strcases"
+)
+
+const (
+	// FoldSimple is the simple case folding of Go regular expressions, where
+	// each character matches its other cases one for one
+	FoldSimple = "simple"
+	// FoldFull is full Unicode case folding, where characters may fold into
+	// multiple characters, ie: "ß" matches "SS"
+	FoldFull = "full"
+	// FoldTurkic is FoldFull with the Turkic dotted and dotless i mappings,
+	// ie: "I" matches "ı" and "İ" matches "i"
+	FoldTurkic = "turkic"
+
+	// NormalizeNFC composes characters before matching
+	NormalizeNFC = "nfc"
+	// NormalizeNFD decomposes characters before matching
+	NormalizeNFD = "nfd"
+)
+
+var (
+	gTurkicFolds = strings.NewReplacer("I", "ı", "İ", "i")
+)
+
+// isFolding returns true if matching is done on the folded and normalized
+// content instead of the original
+func (w *Worker) isFolding() (folding bool) {
+	folding = w.Normalize != "" || (w.Fold != "" && w.Fold != FoldSimple && (w.IgnoreCase || w.PreserveCase))
+	return
+}
+
+// foldString returns the input normalized and, when ignoring case, folded
+func (w *Worker) foldString(input string) (folded string) {
+	switch w.Normalize {
+	case NormalizeNFC:
+		input = norm.NFC.String(input)
+	case NormalizeNFD:
+		input = norm.NFD.String(input)
+	}
+	if w.IgnoreCase || w.PreserveCase {
+		switch w.Fold {
+		case FoldFull:
+			input = cases.Fold().String(input)
+		case FoldTurkic:
+			input = cases.Fold().String(gTurkicFolds.Replace(input))
+		}
+	}
+	folded = input
+	return
+}
+
+// foldContent returns the content folded with foldString along with the
+// original offset of each folded byte offset; offsets within a folded
+// character (or within a normalization segment) are -1 as these are not
+// valid edit boundaries
+func (w *Worker) foldContent(content string) (folded string, offsets []int) {
+	var iter norm.Iter
+	switch w.Normalize {
+	case NormalizeNFC:
+		iter.InitString(norm.NFC, content)
+	case NormalizeNFD:
+		iter.InitString(norm.NFD, content)
+	}
+	var buf strings.Builder
+	for pos := 0; pos < len(content); {
+		var segment string
+		next := pos
+		if w.Normalize != "" {
+			segment = string(iter.Next())
+			next = iter.Pos()
+		} else {
+			_, size := utf8.DecodeRuneInString(content[pos:])
+			segment = content[pos : pos+size]
+			next = pos + size
+		}
+		chunk := w.foldString(segment)
+		offsets = append(offsets, pos)
+		for i := 1; i < len(chunk); i++ {
+			offsets = append(offsets, -1)
+		}
+		buf.WriteString(chunk)
+		pos = next
+	}
+	offsets = append(offsets, len(content))
+	folded = buf.String()
+	return
+}
+
+// foldPattern returns the pattern with all literal characters folded with
+// foldString, character classes are not changed
+func (w *Worker) foldPattern(pattern *regexp.Regexp) (folded *regexp.Regexp, err error) {
+	var re *syntax.Regexp
+	if re, err = syntax.Parse(pattern.String(), syntax.Perl); err != nil {
+		return
+	}
+	var walk func(re *syntax.Regexp)
+	walk = func(re *syntax.Regexp) {
+		if re.Op == syntax.OpLiteral {
+			re.Rune = []rune(w.foldString(string(re.Rune)))
+		}
+		for _, sub := range re.Sub {
+			walk(sub)
+		}
+	}
+	walk(re)
+	folded, err = regexp.Compile(re.String())
+	return
+}
+
+// normalForm returns the normalization of the content, if it is in only one
+// of the forms, content in both forms (such as ASCII) has no normalization
+func normalForm(content string) (form norm.Form, ok bool) {
+	if isNFC := norm.NFC.IsNormalString(content); isNFC && !norm.NFD.IsNormalString(content) {
+		form, ok = norm.NFC, true
+	} else if !isNFC && norm.NFD.IsNormalString(content) {
+		form, ok = norm.NFD, true
+	}
+	return
+}
+
+// findFoldedEdits computes the replacement edits for the content given by
+// matching against the folded content, the replacements are written in the
+// normalization of the original content
+func (w *Worker) findFoldedEdits(content string) (edits []Edit) {
+	folded, offsets := w.foldContent(content)
+
+	var rx *regexp.Regexp
+	if w.Pattern != nil {
+		var err error
+		if rx, err = w.foldPattern(w.Pattern); err != nil {
+			// not possible with valid patterns
+			return
+		}
+	} else if w.Search != "" && w.Search != w.replacement() {
+		prefix := ""
+		if w.IgnoreCase || w.PreserveCase {
+			prefix = `(?i)`
+		}
+		rx = regexp.MustCompile(prefix + w.literalPattern(w.foldString(w.Search)))
+	} else {
+		return
+	}
+
+	var matches [][]int
+	if w.Pattern != nil && !w.MultiLine {
+		for start := 0; start < len(folded); {
+			end := strings.IndexByte(folded[start:], '\n')
+			if end < 0 {
+				end = len(folded)
+			} else {
+				end += start + 1
+			}
+			for _, m := range rx.FindAllStringSubmatchIndex(folded[start:end], -1) {
+				for idx := range m {
+					if m[idx] >= 0 {
+						m[idx] += start
+					}
+				}
+				matches = append(matches, m)
+			}
+			start = end
+		}
+	} else {
+		matches = rx.FindAllStringSubmatchIndex(folded, -1)
+	}
+
+	form, hasForm := normalForm(content)
+	d := strcases.NewCaseDetector()
+	for _, m := range matches {
+		// the match and any groups must start and end on original boundaries
+		original := make([]int, len(m))
+		valid := true
+		for idx, offset := range m {
+			if original[idx] = offset; offset >= 0 {
+				if original[idx] = offsets[offset]; original[idx] < 0 {
+					valid = false
+					break
+				}
+			}
+		}
+		if !valid {
+			continue
+		}
+
+		start, end := original[0], original[1]
+		var text string
+		if w.Pattern != nil {
+			text = string(rx.ExpandString(nil, w.replacement(), content, original))
+		} else {
+			text = w.replacement()
+		}
+		if w.PreserveCase {
+			text = w.casing.applyCase(d.Detect(content[start:end]), text, content, start, end)
+		}
+		if hasForm {
+			text = form.String(text)
+		} else if found, ok := normalForm(content[start:end]); ok {
+			// mixed content, use the form of the text replaced
+			text = found.String(text)
+		}
+		edits = append(edits, Edit{Start: start, End: end, Text: text})
+	}
+	return
+}
+
+// parseFold validates the --fold and --normalize settings
+func parseFold(fold, normalize string) (err error) {
+	switch fold {
+	case "", FoldSimple, FoldFull, FoldTurkic:
+	default:
+		err = fmt.Errorf("--%s unknown mode %q, expected %q, %q or %q", FoldFlag.Name, fold, FoldSimple, FoldFull, FoldTurkic)
+		return
+	}
+	switch normalize {
+	case "", NormalizeNFC, NormalizeNFD:
+	default:
+		err = fmt.Errorf("--%s unknown form %q, expected %q or %q", NormalizeFlag.Name, normalize, NormalizeNFC, NormalizeNFD)
+	}
+	return
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestFold(t *testing.T) {
+	t.Parallel()
+
+	modified := func(content string, options ...Option) (output string) {
+		w, err := New(append([]Option{WithQuiet(true)}, options...)...)
+		So(err, ShouldBeNil)
+		output = w.findEdits("test", content).Modified()
+		return
+	}
+
+	Convey("Settings", t, func() {
+		_, err := New(WithFold("complex"))
+		So(err, ShouldNotBeNil)
+		_, err = New(WithNormalize("nfkc"))
+		So(err, ShouldNotBeNil)
+		w, err := New(WithFold(" Full "), WithNormalize("NFD"))
+		So(err, ShouldBeNil)
+		So(w.Fold, ShouldEqual, FoldFull)
+		So(w.Normalize, ShouldEqual, NormalizeNFD)
+		So(w.isFolding(), ShouldBeTrue)
+		w, err = New(WithFold(FoldFull))
+		So(err, ShouldBeNil)
+		So(w.isFolding(), ShouldBeFalse)
+	})
+
+	Convey("Full Folding", t, func() {
+		content := "STRASSE, Straße and strasse; ΣΟΦΟΣ σοφος; ﬁle"
+		So(modified(content, WithSearch("straße", "road"), WithIgnoreCase(true)), ShouldEqual,
+			"STRASSE, road and strasse; ΣΟΦΟΣ σοφος; ﬁle")
+		So(modified(content, WithSearch("straße", "road"), WithIgnoreCase(true), WithFold(FoldFull)), ShouldEqual,
+			"road, road and road; ΣΟΦΟΣ σοφος; ﬁle")
+		So(modified(content, WithSearch("σοφοσ", "wise"), WithIgnoreCase(true), WithFold(FoldFull)), ShouldEqual,
+			"STRASSE, Straße and strasse; wise wise; ﬁle")
+		So(modified(content, WithSearch("file", "doc"), WithIgnoreCase(true), WithFold(FoldFull)), ShouldEqual,
+			"STRASSE, Straße and strasse; ΣΟΦΟΣ σοφος; doc")
+		// partial matches of folded characters are not replaced
+		So(modified("Straße", WithSearch("stras", "x"), WithIgnoreCase(true), WithFold(FoldFull)), ShouldEqual,
+			"Straße")
+		So(modified("STRASSE straße", WithSearch("Strasse", "road"), WithPreserveCase(true), WithFold(FoldFull)), ShouldEqual,
+			"ROAD road")
+		So(modified("Straße", WithRegex(true), WithSearch(`(s)tra(ss)e`, "${1}${2}"), WithIgnoreCase(true), WithFold(FoldFull)), ShouldEqual,
+			"Sß")
+	})
+
+	Convey("Turkic Folding", t, func() {
+		content := "ISTANBUL İstanbul ıstanbul istanbul"
+		So(modified(content, WithSearch("istanbul", "x"), WithIgnoreCase(true), WithFold(FoldTurkic)), ShouldEqual,
+			"ISTANBUL x ıstanbul x")
+		So(modified(content, WithSearch("ıstanbul", "x"), WithIgnoreCase(true), WithFold(FoldTurkic)), ShouldEqual,
+			"x İstanbul x istanbul")
+	})
+
+	Convey("Normalization", t, func() {
+		composed := norm.NFC.String("café")
+		decomposed := norm.NFD.String("café")
+		So(composed, ShouldNotEqual, decomposed)
+
+		output := modified(decomposed+" bar", WithSearch(composed, "naïve "+composed), WithNormalize(NormalizeNFC))
+		So(output, ShouldEqual, norm.NFD.String("naïve café")+" bar")
+		So(norm.NFD.IsNormalString(output), ShouldBeTrue)
+
+		output = modified(composed+" bar", WithSearch(decomposed, "naïve"), WithNormalize(NormalizeNFD))
+		So(output, ShouldEqual, norm.NFC.String("naïve")+" bar")
+
+		So(modified(decomposed, WithSearch(composed, "x")), ShouldEqual, decomposed)
+	})
+}
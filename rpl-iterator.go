@@ -15,26 +15,48 @@
 package replace
 
 import (
+	"context"
 	"io"
+	"path/filepath"
 
 	"github.com/go-corelibs/diff"
 	"github.com/go-corelibs/path"
-	"github.com/go-corelibs/replace"
 )
 
 type Iterator struct {
-	w   *Worker
-	pos int
+	w      *Worker
+	pos    int
+	offset int
+	snap   *Snapshot
+	codec  *cCompression
 }
 
+// Pos returns the position of the current file within all the files matched
+// so far, across all batches
 func (i *Iterator) Pos() (pos int) {
-	pos = i.pos
+	pos = i.offset + i.pos
 	return
 }
 
+// Next moves to the next matched file, searching the next batch of files when
+// the current batch is done
 func (i *Iterator) Next() {
 	if i.Valid() {
 		i.pos += 1
+		i.snap, i.codec = nil, nil
+		for i.pos >= len(i.w.Matched) && i.w.HasMoreBatches() {
+			count := len(i.w.Matched)
+			if _, err := i.w.NextBatch(); err != nil {
+				if !isContextErr(err) {
+					i.w.Notifier.Error("# error: %v\n", err)
+				}
+				// nothing more to iterate over
+				i.pos = len(i.w.Matched)
+				return
+			}
+			i.offset += count
+			i.pos = 0
+		}
 	}
 	return
 }
@@ -51,41 +73,115 @@ func (i *Iterator) Name() (path string) {
 	return
 }
 
+// Replace computes the changes for the current file, returning the complete
+// diff.Diff needed for selectively applying groups of changes
 func (i *Iterator) Replace() (original, modified string, count int, delta *diff.Diff, err error) {
+	original, modified, count, delta, err = i.ReplaceContext(i.context())
+	return
+}
+
+// ReplaceContext is Replace limited by the context given and the
+// Worker.FileTimeout
+func (i *Iterator) ReplaceContext(ctx context.Context) (original, modified string, count int, delta *diff.Diff, err error) {
+	var edits *Edits
+	if edits, err = i.EditsContext(ctx); err == nil {
+		original, modified, count = edits.Source(), edits.Modified(), edits.Len()
+		delta = diff.New(edits.Path(), original, modified)
+	}
+	return
+}
+
+// Edits computes the compact list of replacements for the current file,
+// without the cost of computing a complete diff.Diff
+func (i *Iterator) Edits() (edits *Edits, err error) {
+	edits, err = i.EditsContext(i.context())
+	return
+}
+
+// EditsContext is Edits limited by the context given and the
+// Worker.FileTimeout, a file exceeding the timeout is recorded as unfinished
+func (i *Iterator) EditsContext(ctx context.Context) (edits *Edits, err error) {
 	if !i.Valid() {
 		err = io.EOF
-	} else if i.w.Pattern != nil {
-		if i.w.PreserveCase {
-			original, modified, count, delta, err = replace.RegexPreserveFile(i.w.Pattern, i.w.Replace, i.w.Matched[i.pos])
-		} else if i.w.MultiLine {
-			original, modified, count, delta, err = replace.RegexFile(i.w.Pattern, i.w.Replace, i.w.Matched[i.pos])
-		} else {
-			original, modified, count, delta, err = replace.RegexLinesFile(i.w.Pattern, i.w.Replace, i.w.Matched[i.pos])
+		return
+	}
+	name := i.w.Matched[i.pos]
+	var found *Edits
+	var codec *cCompression
+	var raw []byte
+	var ee error
+	if err = i.w.runFileContext(ctx, func() {
+		var text []byte
+		if raw, ee = i.w.readFile(name); ee != nil {
+			return
+		} else if text, codec, ee = i.w.decodeText(raw); ee == nil {
+			if found, ee = i.w.findScopedEdits(name, string(text)); ee == nil {
+				i.w.expandVariables(found)
+			}
 		}
-	} else if i.w.PreserveCase {
-		original, modified, count, delta, err = replace.StringPreserveFile(i.w.Search, i.w.Replace, i.w.Matched[i.pos])
-	} else if i.w.IgnoreCase {
-		original, modified, count, delta, err = replace.StringInsensitiveFile(i.w.Search, i.w.Replace, i.w.Matched[i.pos])
-	} else {
-		original, modified, count, delta, err = replace.StringFile(i.w.Search, i.w.Replace, i.w.Matched[i.pos])
+	}); err != nil {
+		if !isContextErr(err) {
+			i.w.unfinished = append(i.w.unfinished, name)
+			i.w.emit(ErrorEvent{File: name, Err: err})
+		}
+		return
+	} else if err = ee; err != nil {
+		i.w.emit(ErrorEvent{File: name, Err: err})
+		return
 	}
+	edits, i.codec = found, codec
+	i.w.emit(DiffComputedEvent{File: name, Count: edits.Len()})
+	// record the state of the file the edits were computed from
+	i.snap, err = i.w.newSnapshot(name, string(raw))
 	return
 }
 
+// Snapshot returns the state of the current file recorded by the last call to
+// Replace, or nil if Replace has not been called for the current file
+func (i *Iterator) Snapshot() (snap *Snapshot) {
+	if i.Valid() {
+		snap = i.snap
+	}
+	return
+}
+
+// ApplyAll writes all the changes to the current file. The unified diff is
+// only rendered when the Worker.ShowDiff option is set
 func (i *Iterator) ApplyAll() (count int, unified, backup string, err error) {
-	if !i.Valid() {
-		err = io.EOF
+	count, unified, backup, err = i.ApplyAllContext(i.context())
+	return
+}
+
+// ApplyAllContext is ApplyAll limited by the context given, nothing is written
+// once the context is done
+func (i *Iterator) ApplyAllContext(ctx context.Context) (count int, unified, backup string, err error) {
+	var edits *Edits
+	if edits, err = i.EditsContext(ctx); err != nil {
+		return
+	} else if count = edits.Len(); count == 0 {
+		// nop
+		i.w.finish(i.Name())
 		return
 	}
-	var delta *diff.Diff
-	if _, _, count, delta, err = i.Replace(); err == nil {
-		delta.KeepAll()
-		_, unified, backup, err = i.ApplySpecific(delta)
+	if i.w.ShowDiff {
+		unified = i.w.FormatPatch(i.Name(), edits.Unified())
+	}
+	if err = ctx.Err(); err == nil {
+		backup, err = i.write(edits.Modified())
 	}
 	return
 }
 
+// ApplySpecific writes only the changes kept in the delta given to the
+// current file
 func (i *Iterator) ApplySpecific(delta *diff.Diff) (count int, unified, backup string, err error) {
+	count, unified, backup, err = i.ApplySpecificContext(i.context(), delta)
+	return
+}
+
+// ApplySpecificContext is ApplySpecific limited by the context given, nothing
+// is written once the context is done
+func (i *Iterator) ApplySpecificContext(ctx context.Context, delta *diff.Diff) (count int, unified, backup string, err error) {
 	if !i.Valid() {
 		err = io.EOF
 		return
@@ -93,40 +189,115 @@ func (i *Iterator) ApplySpecific(delta *diff.Diff) (count int, unified, backup s
 
 	if count = delta.KeepLen(); count == 0 {
 		// nop
+		i.w.finish(i.Name())
 		return
 	}
 
 	var modified string
 	if modified, err = delta.ModifiedEdits(); err == nil {
+		unified = i.w.FormatPatch(i.Name(), delta.UnifiedEdits())
+		if err = ctx.Err(); err == nil {
+			backup, err = i.write(modified)
+		}
+	}
 
-		unified = delta.UnifiedEdits()
-
-		var backupExtension, backupSeparator string
-		if i.w.Backup {
-			// TODO: figure out a template pattern for backup extension
-			//       that isn't as cumbersome as text/template and also
-			//       not as terse as fmt.Sprintf
-			backupExtension = i.w.getBackupExtension()
-			if i.w.BackupExtension != "" {
-				backupSeparator = "."
-			} else {
-				backupSeparator = DefaultBackupSeparator
-			}
+	return
+}
+
+// context returns the Worker context, or the background context when the
+// Iterator is nil
+func (i *Iterator) context() (ctx context.Context) {
+	if i != nil && i.w != nil {
+		ctx = i.w.Context()
+		return
+	}
+	ctx = context.Background()
+	return
+}
+
+func (i *Iterator) write(modified string) (backup string, err error) {
+	var backupExtension, backupSeparator string
+	if i.w.Backup && i.w.template == nil && i.w.backupDir == nil {
+		backupExtension, backupSeparator = BackupNaming(i.w.BackupExtension)
+	}
+
+	if i.snap != nil {
+		// make sure nothing else changed the file since the delta was
+		// computed, the caller is expected to call Replace again
+		if err = i.snap.Verify(); err != nil {
+			return
 		}
+	}
+
+	// recompress in the same manner as the original file
+	if modified, err = encodeText(i.codec, modified); err != nil {
+		i.w.emit(ErrorEvent{File: i.w.Matched[i.pos], Err: err})
+		return
+	}
 
+	target := i.w.Matched[i.pos]
+	// archive members are backed up by copying the entire archive, once
+	original := target
+	if archive, _, ok := i.w.archiveMember(target); ok {
+		original = archive
+	}
+	recorded, present := i.w.archiveBackups[original]
+
+	if i.w.Backup && present {
+		backup = recorded
+		if !i.w.Nop {
+			err = i.w.overwrite(target, modified)
+		}
+	} else if i.w.Backup && i.w.backupDir != nil {
 		if i.w.Nop {
-			if i.w.Backup { // simulate backup filename
-				for backup = path.BackupName(i.w.Matched[i.pos], backupExtension, backupSeparator); path.Exists(backup); {
-					backup = path.BackupName(backup, backupExtension, backupSeparator)
+			backup = i.w.backupDir.Path(original)
+		} else if backup, err = i.w.backupDir.Backup(original); err == nil {
+			err = i.w.overwrite(target, modified)
+		}
+	} else if i.w.Backup && i.w.template != nil {
+		backup = i.w.template.next(original, i.w.exists)
+		if !i.w.Nop {
+			err = i.w.backupAndOverwrite(original, target, backup, modified)
+		}
+	} else if i.w.Backup {
+		for backup = path.BackupName(original, backupExtension, backupSeparator); i.w.exists(backup); {
+			backup = path.BackupName(backup, backupExtension, backupSeparator)
+		}
+		if !i.w.Nop {
+			err = i.w.backupAndOverwrite(original, target, backup, modified)
+		}
+	} else if !i.w.Nop {
+		err = i.w.overwrite(target, modified)
+	}
+	if err == nil {
+		i.w.finish(target)
+		if backup != "" {
+			if original != target {
+				if i.w.archiveBackups == nil {
+					i.w.archiveBackups = make(map[string]string)
 				}
+				i.w.archiveBackups[original] = backup
 			}
-		} else if i.w.Backup {
-			backup, err = path.BackupAndOverwrite(i.w.Matched[i.pos], modified, backupExtension, backupSeparator)
-		} else {
-			err = path.Overwrite(i.w.Matched[i.pos], modified)
+			i.w.emit(BackupCreatedEvent{File: target, Backup: backup, Nop: i.w.Nop})
 		}
-
+		i.w.emit(FileWrittenEvent{File: target, Nop: i.w.Nop})
+	} else {
+		i.w.emit(ErrorEvent{File: target, Err: err})
 	}
+	return
+}
 
+// backupAndOverwrite copies the original to the backup given, creating any
+// missing directories, and then overwrites the target with the modified
+// content. The original is the target itself, or the archive of a member
+func (w *Worker) backupAndOverwrite(original, target, backup, modified string) (err error) {
+	if dir := filepath.Dir(backup); !w.isDir(dir) {
+		if err = w.mkdirAll(dir); err != nil {
+			return
+		}
+	}
+	if err = w.copyFile(original, backup); err == nil {
+		err = w.overwrite(target, modified)
+	}
 	return
 }
@@ -15,12 +15,17 @@
 package replace
 
 import (
+	"errors"
 	"io"
+	"os"
+	"path/filepath"
 	"regexp"
 	"sync"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
+
+	rpl "github.com/go-corelibs/replace"
 )
 
 func TestIterator(t *testing.T) {
@@ -100,4 +105,105 @@ func TestIterator(t *testing.T) {
 		_, _, _, _, err = iter.Replace()
 		So(err, ShouldEqual, io.EOF)
 	})
+
+	Convey("Modified Since Replace", t, func() {
+		m.Lock()
+		defer m.Unlock()
+		outio, errio, w := makeWorker()
+		defer outio.Restore()
+		defer errio.Restore()
+		target := filepath.Join(t.TempDir(), "modified.txt")
+		So(os.WriteFile(target, []byte("hello world\n"), 0644), ShouldEqual, nil)
+		w.Search = "hello"
+		w.Replace = "olleh"
+		w.Matched = []string{target}
+		So(w.Init(), ShouldEqual, nil)
+
+		iter := w.StartIterating()
+		So(iter, ShouldNotEqual, nil)
+		_, _, count, delta, err := iter.Replace()
+		So(err, ShouldEqual, nil)
+		So(count, ShouldEqual, 1)
+		So(iter.Snapshot(), ShouldNotEqual, nil)
+
+		// another process changes the file
+		So(os.WriteFile(target, []byte("hello there world\n"), 0644), ShouldEqual, nil)
+		delta.KeepAll()
+		_, _, _, err = iter.ApplySpecific(delta)
+		So(errors.Is(err, ErrFileModified), ShouldEqual, true)
+		data, _ := os.ReadFile(target)
+		So(string(data), ShouldEqual, "hello there world\n")
+
+		// recompute and apply
+		_, _, count, delta, err = iter.Replace()
+		So(err, ShouldEqual, nil)
+		So(count, ShouldEqual, 1)
+		delta.KeepAll()
+		_, _, _, err = iter.ApplySpecific(delta)
+		So(err, ShouldEqual, nil)
+		data, _ = os.ReadFile(target)
+		So(string(data), ShouldEqual, "olleh there world\n")
+	})
+
+	Convey("Batches", t, func() {
+		m.Lock()
+		defer m.Unlock()
+		outio, errio, w := makeWorker()
+		defer outio.Restore()
+		defer errio.Restore()
+		w.Paths = []string{"_testing"}
+		w.Search = "hello"
+		w.Replace = "olleh"
+		w.Recurse = true
+		w.IgnoreCase = true
+		w.NoLimits = true
+		So(w.Init(), ShouldEqual, nil)
+		So(w.InitTargets(nil), ShouldEqual, nil)
+		So(w.FindMatching(nil), ShouldEqual, nil)
+		So(w.HasMoreBatches(), ShouldEqual, false)
+		expected := w.Matched[:]
+
+		oldMaxFiles := rpl.MaxFileCount
+		defer func() { rpl.MaxFileCount = oldMaxFiles }()
+		rpl.MaxFileCount = 1
+		w.NoLimits = false
+		So(w.FindMatching(nil), ShouldEqual, nil)
+		So(len(w.Files), ShouldEqual, 1)
+
+		var names []string
+		for iter := w.StartIterating(); iter.Valid(); iter.Next() {
+			So(iter.Pos(), ShouldEqual, len(names))
+			names = append(names, iter.Name())
+		}
+		So(names, ShouldEqual, expected)
+		So(w.MatchedCount(), ShouldEqual, len(expected))
+		So(w.BatchCount(), ShouldBeGreaterThan, len(expected))
+		So(w.HasMoreBatches(), ShouldEqual, false)
+	})
+	Convey("Batches (File Before Directory)", t, func() {
+		m.Lock()
+		defer m.Unlock()
+		outio, errio, w := makeWorker()
+		defer outio.Restore()
+		defer errio.Restore()
+		dir := t.TempDir()
+		for _, name := range []string{"a.txt", "b.txt"} {
+			So(os.WriteFile(filepath.Join(dir, name), []byte("hello\n"), 0644), ShouldEqual, nil)
+		}
+		w.Paths = []string{filepath.Join(dir, "a.txt"), dir}
+		w.Search = "hello"
+		w.Replace = "hello world"
+		w.Recurse = true
+		So(w.Init(), ShouldEqual, nil)
+		So(w.InitTargets(nil), ShouldEqual, nil)
+		So(w.FindMatching(nil), ShouldEqual, nil)
+		So(w.Matched, ShouldEqual, []string{filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")})
+
+		for iter := w.StartIterating(); iter.Valid(); iter.Next() {
+			_, _, _, err := iter.ApplyAll()
+			So(err, ShouldEqual, nil)
+		}
+		data, _ := os.ReadFile(filepath.Join(dir, "a.txt"))
+		So(string(data), ShouldEqual, "hello world\n")
+	})
 }
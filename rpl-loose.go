@@ -0,0 +1,80 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// readSearchFiles reads the search and replace values from the files given
+// with --search-file and --replace-file
+func (w *Worker) readSearchFiles() (err error) {
+	read := func(file string) (value string, err error) {
+		var data []byte
+		if data, err = w.readFile(file); err == nil {
+			value = strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+		}
+		return
+	}
+	if w.SearchFile != "" {
+		if w.Search, err = read(w.SearchFile); err != nil {
+			err = fmt.Errorf("--%s %w", SearchFileFlag.Name, err)
+			return
+		}
+	}
+	if w.ReplaceFile != "" {
+		if w.Replace, err = read(w.ReplaceFile); err != nil {
+			err = fmt.Errorf("--%s %w", ReplaceFileFlag.Name, err)
+			return
+		}
+	}
+	if w.LooseWhitespace && strings.TrimSpace(w.Search) == "" {
+		err = fmt.Errorf("--%s requires a search with more than whitespace", LooseWhitespaceFlag.Name)
+	}
+	return
+}
+
+// literalPattern returns the regular expression source matching the plain
+// search given, with any runs of whitespace matching any runs of whitespace
+// when LooseWhitespace is enabled, leading and trailing whitespace is ignored
+func (w *Worker) literalPattern(search string) (pattern string) {
+	if !w.LooseWhitespace {
+		return regexp.QuoteMeta(search)
+	}
+	words := strings.Fields(search)
+	for idx, word := range words {
+		words[idx] = regexp.QuoteMeta(word)
+	}
+	pattern = strings.Join(words, `\s+`)
+	return
+}
+
+// findLooseEdits computes the replacements of the plain search with loose
+// whitespace matching
+func (w *Worker) findLooseEdits(content string) (edits []Edit) {
+	if w.PreserveCase || w.IgnoreCase {
+		rx := regexp.MustCompile(`(?i)` + w.literalPattern(w.Search))
+		if w.PreserveCase {
+			edits = findPreserveEdits(w.casing, rx, w.replacement(), content)
+		} else {
+			edits = findLiteralEdits(rx, w.replacement(), content)
+		}
+		return
+	}
+	edits = findLiteralEdits(regexp.MustCompile(w.literalPattern(w.Search)), w.replacement(), content)
+	return
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"io/fs"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLooseWhitespace(t *testing.T) {
+	t.Parallel()
+
+	Convey("Patterns", t, func() {
+		w := &Worker{}
+		So(w.literalPattern("a  b.c"), ShouldEqual, `a  b\.c`)
+		w.LooseWhitespace = true
+		So(w.literalPattern("\n  a  b.c\n\td "), ShouldEqual, `a\s+b\.c\s+d`)
+
+		_, err := New(WithLooseWhitespace(true), WithRegex(true), WithSearch("a", "b"))
+		So(err, ShouldNotBeNil)
+		_, err = New(WithLooseWhitespace(true), WithSearch(" \n ", "b"))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Argv", t, func() {
+		m := NewMemFileSystem(map[string]string{"/search.txt": "a\n"})
+		w, err := New(WithFileSystem(m), WithQuiet(true), WithSearchFile("/search.txt"), WithArgv("b", "one", "two"))
+		So(err, ShouldBeNil)
+		So(w.Search, ShouldEqual, "a")
+		So(w.Replace, ShouldEqual, "b")
+		So(w.Paths, ShouldEqual, []string{"one", "two"})
+
+		// the order of the options does not matter
+		w, err = New(WithFileSystem(m), WithQuiet(true), WithArgv("b", "one", "two"), WithSearchFile("/search.txt"))
+		So(err, ShouldBeNil)
+		So(w.Search, ShouldEqual, "a")
+		So(w.Replace, ShouldEqual, "b")
+		So(w.Paths, ShouldEqual, []string{"one", "two"})
+
+		_, err = New(WithFileSystem(m), WithQuiet(true), WithSearchFile("/missing.txt"), WithArgv("b"))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Snippets", t, func() {
+		m := NewMemFileSystem(map[string]string{
+			"/search.txt":  "if err != nil {\n    return err\n}\n",
+			"/replace.txt": "if err != nil {\n\treturn fmt.Errorf(\"wrapped: %w\", err)\n}\n",
+			"/src/a.go":    "func a() error {\n\terr := b()\n\tif err != nil {\n\t\treturn err\n\t}\n\treturn nil\n}\n",
+			"/src/b.go":    "func b() error {\n\tif err != nil { return err }\n\treturn nil\n}\n",
+			"/src/c.go":    "func c() error {\n\tif err != nil {\n\t\treturn errors.New(\"c\")\n\t}\n}\n",
+		})
+		w, err := New(
+			WithFileSystem(m),
+			WithQuiet(true),
+			WithSearchFile("/search.txt"),
+			WithReplaceFile("/replace.txt"),
+			WithLooseWhitespace(true),
+			WithShowDiff(true),
+			WithPaths("/src"),
+			WithRecurse(true),
+		)
+		So(err, ShouldBeNil)
+		So(w.InitTargets(nil), ShouldBeNil)
+		So(w.FindMatching(nil), ShouldBeNil)
+		So(w.Matched, ShouldEqual, []string{"/src/a.go", "/src/b.go"})
+
+		iter := w.StartIterating()
+		count, unified, _, err := iter.ApplyAll()
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 1)
+		So(unified, ShouldContainSubstring, "-\t\treturn err\n-\t}\n+\tif err != nil {\n+\treturn fmt.Errorf(\"wrapped: %w\", err)\n+}\n")
+		data, _ := fs.ReadFile(m, "src/a.go")
+		So(string(data), ShouldEqual, "func a() error {\n\terr := b()\n\tif err != nil {\n\treturn fmt.Errorf(\"wrapped: %w\", err)\n}\n\treturn nil\n}\n")
+
+		iter.Next()
+		_, _, _, err = iter.ApplyAll()
+		So(err, ShouldBeNil)
+		data, _ = fs.ReadFile(m, "src/b.go")
+		So(string(data), ShouldEqual, "func b() error {\n\tif err != nil {\n\treturn fmt.Errorf(\"wrapped: %w\", err)\n}\n\treturn nil\n}\n")
+	})
+}
@@ -15,65 +15,131 @@
 package replace
 
 import (
+	"fmt"
+	"strings"
+
+	"github.com/dustin/go-humanize"
 	"github.com/urfave/cli/v2"
 
 	clcli "github.com/go-corelibs/cli"
 	"github.com/go-corelibs/notify"
-	"github.com/go-corelibs/slices"
 	"github.com/go-curses/cdk/lib/enums"
 )
 
+// ApplyConfig loads the user and project config files and applies them to the
+// cli.Context given, unless --no-config is set. The args returned are the
+// command-line arguments less any leading @preset argument
+func ApplyConfig(ctx *cli.Context) (config *Config, preset string, args []string, err error) {
+	args = ctx.Args().Slice()
+	if ctx.Bool(NoConfigFlag.Name) {
+		return
+	}
+	if config, err = LoadConfig("."); err != nil {
+		return
+	}
+	if len(args) > 0 && strings.HasPrefix(args[0], "@") && config.HasPreset(args[0][1:]) {
+		preset, args = args[0][1:], args[1:]
+	}
+	err = config.Apply(ctx, preset)
+	return
+}
+
 func MakeWorker(ctx *cli.Context, notifier notify.Notifier) (w *Worker, eventFlag enums.EventFlag, err error) {
-	w = &Worker{
-		Regex:           ctx.Bool(RegexFlag.Name) || ctx.Bool(DotMatchNlFlag.Name) || ctx.Bool(MultiLineFlag.Name),
-		MultiLine:       ctx.Bool(MultiLineFlag.Name),
-		DotMatchNl:      ctx.Bool(DotMatchNlFlag.Name),
-		Recurse:         ctx.Bool(RecurseFlag.Name),
-		Nop:             ctx.Bool(NopFlag.Name),
-		All:             ctx.Bool(AllFlag.Name),
-		IgnoreCase:      ctx.Bool(IgnoreCaseFlag.Name),
-		PreserveCase:    ctx.Bool(PreserveCaseFlag.Name),
-		NoLimits:        ctx.Bool(NoLimitsFlag.Name),
-		Backup:          ctx.Bool(BackupFlag.Name) || ctx.String(BackupExtensionFlag.Name) != "",
-		BackupExtension: ctx.String(BackupExtensionFlag.Name),
-		ShowDiff:        ctx.Bool(ShowDiffFlag.Name),
-		Interactive:     ctx.Bool(InteractiveFlag.Name) || ctx.Bool(PauseFlag.Name),
-		Pause:           ctx.Bool(PauseFlag.Name),
-		Quiet:           ctx.Bool(QuietFlag.Name),
-		Verbose:         ctx.Bool(VerboseFlag.Name),
-		Null:            ctx.Bool(NullFlag.Name),
-		AddFile:         ctx.StringSlice(FileFlag.Name),
-		ExcludeArgs:     ctx.StringSlice(ExcludeFlag.Name),
-		IncludeArgs:     ctx.StringSlice(IncludeFlag.Name),
-		RelativePath:    ".",
-		Argv:            ctx.Args().Slice(),
-		Argc:            ctx.NArg(),
-		Notifier:        notifier,
+	var config *Config
+	var preset string
+	var args []string
+	if config, preset, args, err = ApplyConfig(ctx); err != nil {
+		return
 	}
 
-	if w.Argc >= 2 {
-		w.Search, w.Replace = w.Argv[0], w.Argv[1]
-		if w.Argc > 2 {
-			w.Argv = w.Argv[2:]
-			if w.Stdin = slices.Within("-", w.Argv); w.Stdin {
-				w.Argv = slices.Prune(w.Argv, "-")
-			}
-			w.Argc = len(w.Argv)
-			w.Paths = w.Argv[:]
+	options := []Option{
+		WithRegex(ctx.Bool(RegexFlag.Name)),
+		WithMultiLine(ctx.Bool(MultiLineFlag.Name)),
+		WithDotMatchNl(ctx.Bool(DotMatchNlFlag.Name)),
+		WithRecurse(ctx.Bool(RecurseFlag.Name)),
+		WithNop(ctx.Bool(NopFlag.Name)),
+		WithAll(ctx.Bool(AllFlag.Name)),
+		WithArchives(ctx.Bool(ArchivesFlag.Name)),
+		WithIgnoreCase(ctx.Bool(IgnoreCaseFlag.Name)),
+		WithPreserveCase(ctx.Bool(PreserveCaseFlag.Name)),
+		WithCasing(ctx.StringSlice(CasingFlag.Name)...),
+		WithFold(ctx.String(FoldFlag.Name)),
+		WithNormalize(ctx.String(NormalizeFlag.Name)),
+		WithNoLimits(ctx.Bool(NoLimitsFlag.Name)),
+		WithMaxFiles(ctx.Int(MaxFilesFlag.Name)),
+		WithTimeout(ctx.Duration(TimeoutFlag.Name)),
+		WithFileTimeout(ctx.Duration(FileTimeoutFlag.Name)),
+		WithBackup(ctx.Bool(BackupFlag.Name)),
+		WithBackupExtension(ctx.String(BackupExtensionFlag.Name)),
+		WithBackupTemplate(ctx.String(BackupTemplateFlag.Name)),
+		WithBackupDir(ctx.String(BackupDirFlag.Name)),
+		WithShowDiff(ctx.Bool(ShowDiffFlag.Name)),
+		WithPatchFormat(ctx.String(PatchFormatFlag.Name)),
+		WithApplyPatch(ctx.String(ApplyPatchFlag.Name)),
+		WithFuzz(ctx.Int(FuzzFlag.Name)),
+		WithDecompress(ctx.Bool(DecompressFlag.Name)),
+		WithFilter(ctx.Bool(FilterFlag.Name)),
+		WithInteractive(ctx.Bool(InteractiveFlag.Name)),
+		WithPause(ctx.Bool(PauseFlag.Name)),
+		WithQuiet(ctx.Bool(QuietFlag.Name)),
+		WithVerbose(ctx.Bool(VerboseFlag.Name)),
+		WithNull(ctx.Bool(NullFlag.Name)),
+		WithFiles(ctx.StringSlice(FileFlag.Name)...),
+		WithExclude(ctx.StringSlice(ExcludeFlag.Name)...),
+		WithInclude(ctx.StringSlice(IncludeFlag.Name)...),
+		WithJSONPaths(ctx.StringSlice(JSONPathFlag.Name)...),
+		WithYAMLPaths(ctx.StringSlice(YAMLPathFlag.Name)...),
+		WithTOMLPaths(ctx.StringSlice(TOMLPathFlag.Name)...),
+		WithMarkup(ctx.String(MarkupFlag.Name)),
+		WithMarkdown(ctx.String(MarkdownFlag.Name)),
+		WithRelativePath("."),
+		WithSearchFile(ctx.String(SearchFileFlag.Name)),
+		WithReplaceFile(ctx.String(ReplaceFileFlag.Name)),
+		WithLooseWhitespace(ctx.Bool(LooseWhitespaceFlag.Name)),
+		WithDefines(ctx.StringSlice(DefineFlag.Name)...),
+		WithArgv(args...),
+		WithNotifier(notifier),
+	}
+
+	for _, name := range ctx.StringSlice(ExcludeSetFlag.Name) {
+		if globs, ok := config.GetExcludeSet(name); ok {
+			options = append(options, WithExclude(globs...))
+		} else {
+			err = fmt.Errorf("--%s %w: %q", ExcludeSetFlag.Name, ErrNotFound, name)
+			return
 		}
 	}
-	w.Stdin = w.Stdin || w.Null
 
-	if len(w.Paths) == 0 && !ctx.IsSet(FileFlag.Name) && !w.Stdin {
-		// add CWD if no arguments and --file not present
-		w.Paths = []string{"."}
+	if value := ctx.String(MaxFileSizeFlag.Name); value != "" {
+		var size uint64
+		if size, err = humanize.ParseBytes(value); err != nil {
+			err = fmt.Errorf("--%s %w", MaxFileSizeFlag.Name, err)
+			return
+		}
+		options = append(options, WithMaxFileSize(int64(size)))
 	}
 
-	if err = w.Init(); err != nil {
+	if w, err = New(options...); err != nil {
 		return
 	}
 
-	if ctx.NArg() < 2 {
+	if w.Verbose && config != nil {
+		for _, file := range config.Files {
+			w.Notifier.Error("# loaded config: %q\n", file)
+		}
+		if preset != "" {
+			w.Notifier.Error("# using preset: %q\n", preset)
+		}
+	}
+
+	required := 2
+	if w.SearchFile != "" {
+		required -= 1
+	}
+	if w.ReplaceFile != "" {
+		required -= 1
+	}
+	if len(args) < required && w.ApplyPatchFile == "" {
 		if w.Verbose {
 			clcli.ShowUsageOptionsAndExit(ctx, 1)
 			return
@@ -0,0 +1,266 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"fmt"
+	slashpath "path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// MarkdownProse restricts replacements to the prose of markdown files
+	MarkdownProse = "prose"
+	// MarkdownCode restricts replacements to the code blocks and code spans
+	// of markdown files, optionally limited to the fenced code blocks of the
+	// languages given after a colon, ie: "code:go,sh"
+	MarkdownCode = "code"
+)
+
+var (
+	gMarkdownListItem  = regexp.MustCompile(`^ {0,3}([-*+]|\d{1,9}[.)])([ \t]|$)`)
+	gMarkdownBlankLine = regexp.MustCompile(`\n[ \t]*\n`)
+)
+
+// cMarkdown is the parsed --markdown setting
+type cMarkdown struct {
+	code      bool
+	languages []string
+}
+
+// parseMarkdown parses the --markdown value given, languages are case
+// insensitive and may use the path.Match syntax
+func parseMarkdown(spec string) (m *cMarkdown, err error) {
+	mode, languages, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	switch strings.ToLower(mode) {
+	case MarkdownProse:
+		if languages != "" {
+			err = fmt.Errorf("%q does not accept languages", MarkdownProse)
+			return
+		}
+		m = &cMarkdown{}
+	case MarkdownCode:
+		m = &cMarkdown{code: true}
+		for _, lang := range strings.Split(languages, ",") {
+			if lang = strings.ToLower(strings.TrimSpace(lang)); lang == "" {
+				continue
+			} else if _, err = slashpath.Match(lang, ""); err != nil {
+				err = fmt.Errorf("language %q: %w", lang, err)
+				return
+			}
+			m.languages = append(m.languages, lang)
+		}
+	default:
+		err = fmt.Errorf("unknown mode %q, expected %q or %q", spec, MarkdownProse, MarkdownCode+"[:lang,...]")
+	}
+	return
+}
+
+// matchLanguage returns true if the fenced code block language is selected
+func (m *cMarkdown) matchLanguage(lang string) (matched bool) {
+	if matched = len(m.languages) == 0; matched {
+		return
+	}
+	lang = strings.ToLower(lang)
+	for _, pattern := range m.languages {
+		if matched, _ = slashpath.Match(pattern, lang); matched {
+			return
+		}
+	}
+	return
+}
+
+// cRegion is a byte range of content
+type cRegion struct {
+	start, end int
+}
+
+// cMarkdownFence is an open fenced code block
+type cMarkdownFence struct {
+	char   byte
+	length int
+	lang   string
+}
+
+// parseFence returns the fence of the line given, if it is one
+func parseFence(line string) (fence *cMarkdownFence) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || len(trimmed) < 3 || (trimmed[0] != '`' && trimmed[0] != '~') {
+		return
+	}
+	char := trimmed[0]
+	length := len(trimmed) - len(strings.TrimLeft(trimmed, string(char)))
+	if length < 3 {
+		return
+	}
+	info := strings.TrimSpace(trimmed[length:])
+	if char == '`' && strings.Contains(info, "`") {
+		return
+	}
+	lang, _, _ := strings.Cut(info, " ")
+	fence = &cMarkdownFence{char: char, length: length, lang: strings.Trim(lang, "{}.")}
+	return
+}
+
+// closes returns true if the line closes the fence
+func (f *cMarkdownFence) closes(line string) (closed bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return
+	}
+	length := len(trimmed) - len(strings.TrimLeft(trimmed, string(f.char)))
+	closed = length >= f.length && strings.TrimSpace(trimmed[length:]) == ""
+	return
+}
+
+// markdownRegions scans the markdown content for the prose or code regions
+// selected. Fenced code blocks are code, as are indented code blocks which
+// are not part of a list and inline code spans; fence lines are neither
+// prose nor code, nor are the backticks of code spans
+func (m *cMarkdown) markdownRegions(content string) (regions []cRegion) {
+	idx := newLineIndex(content)
+	add := func(start, end int) {
+		if start == end {
+			return
+		} else if last := len(regions) - 1; last >= 0 && regions[last].end == start {
+			regions[last].end = end
+			return
+		}
+		regions = append(regions, cRegion{start: start, end: end})
+	}
+
+	var prose []cRegion
+	addProse := func(start, end int) {
+		if last := len(prose) - 1; last >= 0 && prose[last].end == start {
+			prose[last].end = end
+			return
+		}
+		prose = append(prose, cRegion{start: start, end: end})
+	}
+
+	var fence *cMarkdownFence
+	var inList, inCode bool
+	blank := true
+	for line := 0; line < idx.count() && idx.start(line) < len(content); line++ {
+		start, end := idx.start(line), idx.end(line)
+		text := strings.TrimRight(content[start:end], "\r\n")
+		isBlank := strings.TrimSpace(text) == ""
+
+		switch {
+		case fence != nil:
+			if fence.closes(text) {
+				fence = nil
+			} else if m.code && m.matchLanguage(fence.lang) {
+				add(start, end)
+			}
+		case parseFence(text) != nil:
+			fence = parseFence(text)
+			inCode = false
+		case !isBlank && !inList && (blank || inCode) && (strings.HasPrefix(text, "    ") || strings.HasPrefix(text, "\t")):
+			inCode = true
+			if m.code && len(m.languages) == 0 {
+				add(start, end)
+			}
+		case inCode && isBlank:
+			// blank lines within indented code blocks
+			if m.code && len(m.languages) == 0 {
+				add(start, end)
+			}
+		default:
+			inCode = false
+			if gMarkdownListItem.MatchString(text) {
+				inList = true
+			} else if !isBlank && text[0] != ' ' && text[0] != '\t' {
+				inList = false
+			}
+			addProse(start, end)
+		}
+		blank = isBlank
+	}
+
+	// split out the inline code spans
+	for _, region := range prose {
+		last := region.start
+		for pos := region.start; pos < region.end; {
+			if content[pos] == '\\' {
+				pos += 2
+				continue
+			} else if content[pos] != '`' {
+				pos += 1
+				continue
+			}
+			length := len(content[pos:region.end]) - len(strings.TrimLeft(content[pos:region.end], "`"))
+			if closing := findCodeSpanEnd(content[pos+length:region.end], length); closing >= 0 {
+				codeStart, codeEnd := pos+length, pos+length+closing
+				if m.code {
+					if len(m.languages) == 0 {
+						add(codeStart, codeEnd)
+					}
+				} else {
+					add(last, pos)
+				}
+				pos = codeEnd + length
+				last = pos
+				continue
+			}
+			pos += length
+		}
+		if !m.code {
+			add(last, region.end)
+		}
+	}
+	if m.code {
+		// the inline code spans were appended after the blocks
+		sort.Slice(regions, func(i, j int) bool {
+			return regions[i].start < regions[j].start
+		})
+	}
+	return
+}
+
+// findCodeSpanEnd returns the offset of the backtick run of exactly the
+// length given, code spans do not continue past blank lines
+func findCodeSpanEnd(text string, length int) (offset int) {
+	if para := gMarkdownBlankLine.FindStringIndex(text); para != nil {
+		text = text[:para[0]]
+	}
+	for pos := 0; pos < len(text); {
+		if text[pos] != '`' {
+			pos += 1
+			continue
+		}
+		run := len(text[pos:]) - len(strings.TrimLeft(text[pos:], "`"))
+		if run == length {
+			return pos
+		}
+		pos += run
+	}
+	return -1
+}
+
+// findMarkdownEdits computes the replacements within the markdown regions
+// selected
+func (w *Worker) findMarkdownEdits(content string) (edits []Edit) {
+	for _, region := range w.markdown.markdownRegions(content) {
+		for _, edit := range w.findContentEdits(content[region.start:region.end]) {
+			edit.Start += region.start
+			edit.End += region.start
+			edits = append(edits, edit)
+		}
+	}
+	return
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMarkdown(t *testing.T) {
+	t.Parallel()
+
+	source := "# The foo guide\n" +
+		"\n" +
+		"Call `foo()` to foo, or ``foo` ``.\n" +
+		"\n" +
+		"```go\n" +
+		"foo()\n" +
+		"```\n" +
+		"\n" +
+		"~~~~ sh\n" +
+		"foo --help\n" +
+		"~~~~\n" +
+		"\n" +
+		"    foo indented\n" +
+		"\n" +
+		"- a foo list\n" +
+		"\n" +
+		"    foo continued\n" +
+		"\n" +
+		"Done with \\`foo\\`.\n"
+
+	modified := func(spec string) (output string) {
+		w, err := New(WithQuiet(true), WithMarkdown(spec), WithSearch("foo", "bar"))
+		So(err, ShouldBeNil)
+		e, err := w.findScopedEdits("README.md", source)
+		So(err, ShouldBeNil)
+		output = e.Modified()
+		return
+	}
+
+	Convey("Settings", t, func() {
+		m, err := parseMarkdown("code:Go, shell*")
+		So(err, ShouldBeNil)
+		So(m.code, ShouldBeTrue)
+		So(m.matchLanguage("go"), ShouldBeTrue)
+		So(m.matchLanguage("shell-session"), ShouldBeTrue)
+		So(m.matchLanguage("sh"), ShouldBeFalse)
+
+		_, err = parseMarkdown("prose:go")
+		So(err, ShouldNotBeNil)
+		_, err = New(WithMarkdown("text"))
+		So(err, ShouldNotBeNil)
+		_, err = New(WithMarkdown("prose"), WithMarkup("text"))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Prose", t, func() {
+		So(modified("prose"), ShouldEqual, "# The bar guide\n"+
+			"\n"+
+			"Call `foo()` to bar, or ``foo` ``.\n"+
+			"\n"+
+			"```go\n"+
+			"foo()\n"+
+			"```\n"+
+			"\n"+
+			"~~~~ sh\n"+
+			"foo --help\n"+
+			"~~~~\n"+
+			"\n"+
+			"    foo indented\n"+
+			"\n"+
+			"- a bar list\n"+
+			"\n"+
+			"    bar continued\n"+
+			"\n"+
+			"Done with \\`bar\\`.\n")
+	})
+
+	Convey("Code", t, func() {
+		So(modified("code"), ShouldEqual, "# The foo guide\n"+
+			"\n"+
+			"Call `bar()` to foo, or ``bar` ``.\n"+
+			"\n"+
+			"```go\n"+
+			"bar()\n"+
+			"```\n"+
+			"\n"+
+			"~~~~ sh\n"+
+			"bar --help\n"+
+			"~~~~\n"+
+			"\n"+
+			"    bar indented\n"+
+			"\n"+
+			"- a foo list\n"+
+			"\n"+
+			"    foo continued\n"+
+			"\n"+
+			"Done with \\`foo\\`.\n")
+
+		output := modified("code:go")
+		So(output, ShouldContainSubstring, "```go\nbar()\n```")
+		So(output, ShouldContainSubstring, "~~~~ sh\nfoo --help\n~~~~")
+		So(output, ShouldContainSubstring, "Call `foo()`")
+		So(output, ShouldContainSubstring, "    foo indented")
+	})
+}
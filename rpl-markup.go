@@ -0,0 +1,313 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"fmt"
+	"html"
+	slashpath "path"
+	"strings"
+)
+
+const (
+	// MarkupText restricts replacements to the text content of markup files
+	MarkupText = "text"
+	// MarkupAttr restricts replacements to the attribute values of markup
+	// files, optionally limited to the attribute names given after a colon,
+	// ie: "attr:href,src"
+	MarkupAttr = "attr"
+)
+
+// cMarkup is the parsed --markup setting
+type cMarkup struct {
+	attr  bool
+	names []string
+}
+
+// parseMarkup parses the --markup value given, attribute names are case
+// insensitive and may use the path.Match syntax, ie: "attr:data-*"
+func parseMarkup(spec string) (m *cMarkup, err error) {
+	mode, names, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	switch strings.ToLower(mode) {
+	case MarkupText:
+		if names != "" {
+			err = fmt.Errorf("%q does not accept attribute names", MarkupText)
+			return
+		}
+		m = &cMarkup{}
+	case MarkupAttr:
+		m = &cMarkup{attr: true}
+		for _, name := range strings.Split(names, ",") {
+			if name = strings.ToLower(strings.TrimSpace(name)); name == "" {
+				continue
+			} else if _, err = slashpath.Match(name, ""); err != nil {
+				err = fmt.Errorf("attribute %q: %w", name, err)
+				return
+			}
+			m.names = append(m.names, name)
+		}
+	default:
+		err = fmt.Errorf("unknown mode %q, expected %q or %q", spec, MarkupText, MarkupAttr+"[:name,...]")
+	}
+	return
+}
+
+// matchAttr returns true if the attribute name is selected
+func (m *cMarkup) matchAttr(name string) (matched bool) {
+	if matched = len(m.names) == 0; matched {
+		return
+	}
+	name = strings.ToLower(name)
+	for _, pattern := range m.names {
+		if matched, _ = slashpath.Match(pattern, name); matched {
+			return
+		}
+	}
+	return
+}
+
+// cMarkupSpan is the range of text content or of an attribute value within
+// the markup source, quote is the attribute value quote character, or zero
+// for unquoted attribute values and text content
+type cMarkupSpan struct {
+	start, end int
+	attr       bool
+	quote      byte
+}
+
+// markupSpans scans the markup content for the text or attribute value spans
+// selected. Comments, CDATA sections, declarations, processing instructions
+// and the content of script and style elements are never included. The scan
+// is tolerant of malformed markup, anything unterminated is skipped
+func (m *cMarkup) markupSpans(content string) (spans []cMarkupSpan) {
+	isNameChar := func(c byte) bool {
+		return c != ' ' && c != '\t' && c != '\r' && c != '\n' && c != '/' && c != '>' && c != '='
+	}
+	skipPast := func(pos int, token string) int {
+		if idx := strings.Index(content[pos:], token); idx >= 0 {
+			return pos + idx + len(token)
+		}
+		return len(content)
+	}
+	skipSpace := func(pos int) int {
+		for pos < len(content) && strings.IndexByte(" \t\r\n", content[pos]) >= 0 {
+			pos += 1
+		}
+		return pos
+	}
+
+	addText := func(start, end int) {
+		if m.attr || start == end {
+			return
+		} else if last := len(spans) - 1; last >= 0 && spans[last].end == start {
+			// a literal less-than joins the text around it
+			spans[last].end = end
+			return
+		}
+		spans = append(spans, cMarkupSpan{start: start, end: end})
+	}
+
+	for pos := 0; pos < len(content); {
+		lt := strings.IndexByte(content[pos:], '<')
+		if lt < 0 {
+			lt = len(content)
+		} else {
+			lt += pos
+		}
+		addText(pos, lt)
+		if pos = lt; pos >= len(content) {
+			break
+		}
+
+		rest := content[pos:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			pos = skipPast(pos+4, "-->")
+			continue
+		case strings.HasPrefix(rest, "<![CDATA["):
+			pos = skipPast(pos+9, "]]>")
+			continue
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?") || strings.HasPrefix(rest, "</"):
+			pos = skipPast(pos+2, ">")
+			continue
+		case len(rest) < 2 || !isTagStart(rest[1]):
+			// a literal less-than, part of the text content
+			addText(pos, pos+1)
+			pos += 1
+			continue
+		}
+
+		// start tag
+		pos += 1
+		nameStart := pos
+		for pos < len(content) && isNameChar(content[pos]) {
+			pos += 1
+		}
+		tag := strings.ToLower(content[nameStart:pos])
+		closed := false
+		for pos < len(content) {
+			if pos = skipSpace(pos); pos >= len(content) {
+				break
+			} else if content[pos] == '>' {
+				pos += 1
+				break
+			} else if strings.HasPrefix(content[pos:], "/>") {
+				pos += 2
+				closed = true
+				break
+			} else if content[pos] == '/' {
+				pos += 1
+				continue
+			}
+			attrStart := pos
+			for pos < len(content) && isNameChar(content[pos]) {
+				pos += 1
+			}
+			if pos == attrStart {
+				// a stray equals sign
+				pos += 1
+				continue
+			}
+			name := content[attrStart:pos]
+			if next := skipSpace(pos); next < len(content) && content[next] == '=' {
+				pos = skipSpace(next + 1)
+				if pos >= len(content) {
+					break
+				}
+				span := cMarkupSpan{attr: true}
+				if quote := content[pos]; quote == '"' || quote == '\'' {
+					span.quote = quote
+					span.start = pos + 1
+					end := strings.IndexByte(content[span.start:], quote)
+					if end < 0 {
+						pos = len(content)
+						break
+					}
+					span.end = span.start + end
+					pos = span.end + 1
+				} else {
+					span.start = pos
+					for pos < len(content) && strings.IndexByte(" \t\r\n>", content[pos]) < 0 {
+						pos += 1
+					}
+					span.end = pos
+				}
+				if m.attr && m.matchAttr(name) {
+					spans = append(spans, span)
+				}
+			}
+		}
+		if !closed && (tag == "script" || tag == "style") {
+			// raw text elements, skip to the end tag
+			if idx := strings.Index(strings.ToLower(content[pos:]), "</"+tag); idx >= 0 {
+				pos += idx
+			} else {
+				pos = len(content)
+			}
+		}
+	}
+	return
+}
+
+// isReference returns true if the ref is an ampersand, followed by letters,
+// digits or a number sign, and ends with a semicolon
+func isReference(ref string) bool {
+	for _, c := range []byte(ref[1 : len(ref)-1]) {
+		if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && c != '#' {
+			return false
+		}
+	}
+	return true
+}
+
+func isTagStart(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_' || c == ':'
+}
+
+// decodeEntities returns the source with all character references decoded,
+// along with the source offset of each decoded byte offset, offsets within
+// a decoded reference are -1 as these are not valid edit boundaries
+func decodeEntities(source string) (decoded string, offsets []int) {
+	var buf strings.Builder
+	for pos := 0; pos < len(source); {
+		if source[pos] == '&' {
+			if end := strings.IndexByte(source[pos:], ';'); end > 1 && end <= 32 {
+				ref := source[pos : pos+end+1]
+				if text := html.UnescapeString(ref); isReference(ref) && text != ref {
+					offsets = append(offsets, pos)
+					for i := 1; i < len(text); i++ {
+						offsets = append(offsets, -1)
+					}
+					buf.WriteString(text)
+					pos += len(ref)
+					continue
+				}
+			}
+		}
+		offsets = append(offsets, pos)
+		buf.WriteByte(source[pos])
+		pos += 1
+	}
+	offsets = append(offsets, len(source))
+	decoded = buf.String()
+	return
+}
+
+// escape encodes the replacement text for the span, such that the structure
+// of the markup cannot be changed
+func (s cMarkupSpan) escape(text string) (escaped string) {
+	var buf strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '&':
+			buf.WriteString("&amp;")
+		case r == '<':
+			buf.WriteString("&lt;")
+		case r == '>':
+			buf.WriteString("&gt;")
+		case s.attr && r == '"' && s.quote != '\'':
+			buf.WriteString("&quot;")
+		case s.attr && r == '\'' && s.quote != '"':
+			buf.WriteString("&#39;")
+		case s.attr && s.quote == 0 && strings.ContainsRune(" \t\r\n=`", r):
+			buf.WriteString(fmt.Sprintf("&#%d;", r))
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	escaped = buf.String()
+	return
+}
+
+// findMarkupEdits computes the replacements within the markup spans selected,
+// matching against the decoded text and writing escaped replacements
+func (w *Worker) findMarkupEdits(content string) (edits []Edit) {
+	for _, span := range w.markup.markupSpans(content) {
+		decoded, offsets := decodeEntities(content[span.start:span.end])
+		for _, edit := range w.findContentEdits(decoded) {
+			start, end := offsets[edit.Start], offsets[edit.End]
+			if start < 0 || end < 0 {
+				// partial character reference
+				continue
+			}
+			edits = append(edits, Edit{
+				Start: span.start + start,
+				End:   span.start + end,
+				Text:  span.escape(edit.Text),
+			})
+		}
+	}
+	return
+}
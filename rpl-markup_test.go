@@ -0,0 +1,88 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMarkup(t *testing.T) {
+	t.Parallel()
+
+	source := `<!DOCTYPE html>
+<!-- Hello World -->
+<p class="hello" title='Hello &amp; World' data-x=Hello>Hello &amp; World &lt;3 a < b</p>
+<script>var s = "Hello";</script>
+<img alt="Hello"/>Hello
+`
+
+	modified := func(spec, search, replace string) (output string) {
+		w, err := New(WithQuiet(true), WithMarkup(spec), WithSearch(search, replace))
+		So(err, ShouldBeNil)
+		e, err := w.findScopedEdits("test.html", source)
+		So(err, ShouldBeNil)
+		output = e.Modified()
+		return
+	}
+
+	Convey("Settings", t, func() {
+		m, err := parseMarkup("attr:HREF, data-*")
+		So(err, ShouldBeNil)
+		So(m.attr, ShouldBeTrue)
+		So(m.matchAttr("href"), ShouldBeTrue)
+		So(m.matchAttr("Data-Id"), ShouldBeTrue)
+		So(m.matchAttr("src"), ShouldBeFalse)
+
+		_, err = parseMarkup("text:href")
+		So(err, ShouldNotBeNil)
+		_, err = New(WithMarkup("nodes"))
+		So(err, ShouldNotBeNil)
+		_, err = New(WithMarkup("text"), WithJSONPaths("a"))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Character References", t, func() {
+		decoded, offsets := decodeEntities("a&amp;b&bogus c&#65;")
+		So(decoded, ShouldEqual, "a&b&bogus cA")
+		So(offsets, ShouldEqual, []int{0, 1, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 20})
+	})
+
+	Convey("Text Content", t, func() {
+		So(modified("text", "Hello", "Bye"), ShouldEqual, `<!DOCTYPE html>
+<!-- Hello World -->
+<p class="hello" title='Hello &amp; World' data-x=Hello>Bye &amp; World &lt;3 a < b</p>
+<script>var s = "Hello";</script>
+<img alt="Hello"/>Bye
+`)
+		So(modified("text", " & World <3", " <and> more"), ShouldContainSubstring,
+			`>Hello &lt;and&gt; more a < b</p>`)
+		So(modified("text", "a < b", "a&b"), ShouldContainSubstring, `&lt;3 a&amp;b</p>`)
+	})
+
+	Convey("Attribute Values", t, func() {
+		So(modified("attr", "Hello", `"Bye"`), ShouldEqual, `<!DOCTYPE html>
+<!-- Hello World -->
+<p class="hello" title='"Bye" &amp; World' data-x=&quot;Bye&quot;>Hello &amp; World &lt;3 a < b</p>
+<script>var s = "Hello";</script>
+<img alt="&quot;Bye&quot;"/>Hello
+`)
+		So(modified("attr:title", "Hello & World", "Tom's"), ShouldContainSubstring,
+			`title='Tom&#39;s'`)
+		So(modified("attr:title", "Hello", "Bye"), ShouldContainSubstring,
+			`title='Bye &amp; World' data-x=Hello>Hello`)
+	})
+}
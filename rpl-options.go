@@ -0,0 +1,623 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-corelibs/notify"
+	"github.com/go-corelibs/slices"
+)
+
+// Option is a functional option for configuring a Worker with New
+type Option func(w *Worker) (err error)
+
+// New constructs a new Worker with the options given. The same flags implied
+// by the command-line are implied here, for example: WithMultiLine implies
+// WithRegex and WithBackupExtension implies WithBackup. Conflicting options
+// are reported as errors and the Worker returned is already initialized
+func New(options ...Option) (w *Worker, err error) {
+	w = &Worker{}
+	for _, option := range options {
+		if err = option(w); err != nil {
+			w = nil
+			return
+		}
+	}
+
+	w.parseArgv()
+	w.Regex = w.Regex || w.DotMatchNl || w.MultiLine
+	w.Interactive = w.Interactive || w.Pause
+	w.Backup = w.Backup || w.BackupExtension != "" || w.BackupTemplate != "" || w.BackupDir != ""
+	w.Stdin = w.Stdin || w.Null
+
+	if len(w.Paths) == 0 && len(w.AddFile) == 0 && !w.Stdin && w.ApplyPatchFile == "" && !w.Filter {
+		// add CWD if no paths and no files to read paths from
+		w.Paths = []string{"."}
+	}
+
+	if err = w.validate(); err != nil {
+		w = nil
+		return
+	} else if err = w.Init(); err != nil {
+		w = nil
+	}
+	return
+}
+
+// validate checks for conflicting settings
+func (w *Worker) validate() (err error) {
+	switch {
+	case w.BackupExtension != "" && w.BackupTemplate != "":
+		err = fmt.Errorf("--backup-extension cannot be used with --backup-template")
+	case w.BackupExtension != "" && w.BackupDir != "":
+		err = fmt.Errorf("--backup-extension cannot be used with --backup-dir")
+	case w.MaxFileSize < 0:
+		err = fmt.Errorf("--max-file-size cannot be negative")
+	case w.MaxFiles < 0:
+		err = fmt.Errorf("--max-files cannot be negative")
+	case w.Fuzz < 0:
+		err = fmt.Errorf("--fuzz cannot be negative")
+	case w.Filter && w.Interactive:
+		err = fmt.Errorf("--filter cannot be used with --interactive")
+	case w.Filter && w.ApplyPatchFile != "":
+		err = fmt.Errorf("--filter cannot be used with --apply-patch")
+	case w.Filter && (w.Stdin || len(w.Paths) > 0 || len(w.AddFile) > 0):
+		err = fmt.Errorf("--filter cannot be used with any paths or --file")
+	case w.markup != nil && w.hasKeyPaths():
+		err = fmt.Errorf("--markup cannot be used with --json-path, --yaml-path or --toml-path")
+	case w.markdown != nil && w.markup != nil:
+		err = fmt.Errorf("--markdown cannot be used with --markup")
+	case w.markdown != nil && w.hasKeyPaths():
+		err = fmt.Errorf("--markdown cannot be used with --json-path, --yaml-path or --toml-path")
+	case w.LooseWhitespace && w.Regex:
+		err = fmt.Errorf("--loose-whitespace cannot be used with --regex")
+	case w.Timeout < 0:
+		err = fmt.Errorf("--timeout cannot be negative")
+	case w.FileTimeout < 0:
+		err = fmt.Errorf("--file-timeout cannot be negative")
+	}
+	return
+}
+
+// WithSearch sets the search term (or regular expression) and the
+// replacement text
+func WithSearch(search, replace string) Option {
+	return func(w *Worker) (err error) {
+		w.Search, w.Replace = search, replace
+		return
+	}
+}
+
+// WithSearchFile reads the search value from the file given, less any one
+// trailing newline
+func WithSearchFile(file string) Option {
+	return func(w *Worker) (err error) {
+		w.SearchFile = file
+		return
+	}
+}
+
+// WithReplaceFile reads the replace value from the file given, less any one
+// trailing newline
+func WithReplaceFile(file string) Option {
+	return func(w *Worker) (err error) {
+		w.ReplaceFile = file
+		return
+	}
+}
+
+// WithLooseWhitespace matches any run of whitespace in the plain search value
+// with any run of whitespace in the content, including newlines
+func WithLooseWhitespace(enabled bool) Option {
+	return func(w *Worker) (err error) {
+		w.LooseWhitespace = enabled
+		return
+	}
+}
+
+// WithArgv parses the command-line arguments given, the first two are the
+// search and replace values, the remainder are paths with a single dash
+// meaning to read paths from os.Stdin. The search and replace values are not
+// included when read from files, see WithSearchFile and WithReplaceFile
+func WithArgv(argv ...string) Option {
+	return func(w *Worker) (err error) {
+		w.argv = argv
+		return
+	}
+}
+
+// parseArgv parses the arguments given to WithArgv, once all the options are
+// known
+func (w *Worker) parseArgv() {
+	if len(w.argv) == 0 {
+		return
+	}
+	w.Argv, w.Argc = w.argv, len(w.argv)
+	// the search and replace values not read from files
+	var values []*string
+	if w.SearchFile == "" {
+		values = append(values, &w.Search)
+	}
+	if w.ReplaceFile == "" {
+		values = append(values, &w.Replace)
+	}
+	if n := len(values); w.Argc >= n {
+		for idx, value := range values {
+			*value = w.Argv[idx]
+		}
+		if w.Argc > n {
+			w.Argv = w.Argv[n:]
+			if slices.Within("-", w.Argv) {
+				w.Stdin = true
+				w.Argv = slices.Prune(w.Argv, "-")
+			}
+			w.Argc = len(w.Argv)
+			w.Paths = append(w.Paths, w.Argv...)
+		}
+	}
+}
+
+// WithPaths adds to the list of paths to search
+func WithPaths(paths ...string) Option {
+	return func(w *Worker) (err error) {
+		w.Paths = append(w.Paths, paths...)
+		return
+	}
+}
+
+// WithFiles adds to the list of files to read paths from
+func WithFiles(files ...string) Option {
+	return func(w *Worker) (err error) {
+		w.AddFile = append(w.AddFile, files...)
+		return
+	}
+}
+
+// WithStdin reads paths from os.Stdin
+func WithStdin(enabled bool) Option {
+	return func(w *Worker) (err error) {
+		w.Stdin = enabled
+		return
+	}
+}
+
+// WithNull reads null-separated paths from os.Stdin, implies WithStdin
+func WithNull(enabled bool) Option {
+	return func(w *Worker) (err error) {
+		w.Null = enabled
+		return
+	}
+}
+
+// WithRelativePath reports paths relative to the path given, "." is the
+// current working directory
+func WithRelativePath(path string) Option {
+	return func(w *Worker) (err error) {
+		w.RelativePath = path
+		return
+	}
+}
+
+// WithInclude adds to the list of include globs
+func WithInclude(globs ...string) Option {
+	return func(w *Worker) (err error) {
+		w.IncludeArgs = append(w.IncludeArgs, globs...)
+		return
+	}
+}
+
+// WithExclude adds to the list of exclude globs
+func WithExclude(globs ...string) Option {
+	return func(w *Worker) (err error) {
+		w.ExcludeArgs = append(w.ExcludeArgs, globs...)
+		return
+	}
+}
+
+// WithRecurse travels directory paths
+func WithRecurse(enabled bool) Option {
+	return func(w *Worker) (err error) {
+		w.Recurse = enabled
+		return
+	}
+}
+
+// WithAll includes backups and files that start with a dot
+func WithAll(enabled bool) Option {
+	return func(w *Worker) (err error) {
+		w.All = enabled
+		return
+	}
+}
+
+// WithArchives searches the members of tar and zip archives as if the
+// archives were directories, see ArchiveExtensions
+func WithArchives(enabled bool) Option {
+	return func(w *Worker) (err error) {
+		w.Archives = enabled
+		return
+	}
+}
+
+// WithBinAsText processes binary files as if they were text
+func WithBinAsText(enabled bool) Option {
+	return func(w *Worker) (err error) {
+		w.BinAsText = enabled
+		return
+	}
+}
+
+// WithRegex searches with a regular expression
+func WithRegex(enabled bool) Option {
+	return func(w *Worker) (err error) {
+		w.Regex = enabled
+		return
+	}
+}
+
+// WithMultiLine applies the regular expression to the entire content of
+// each file instead of line by line, implies WithRegex
+func WithMultiLine(enabled bool) Option {
+	return func(w *Worker) (err error) {
+		w.MultiLine = enabled
+		return
+	}
+}
+
+// WithDotMatchNl sets the (?s) regular expression flag, implies WithRegex
+func WithDotMatchNl(enabled bool) Option {
+	return func(w *Worker) (err error) {
+		w.DotMatchNl = enabled
+		return
+	}
+}
+
+// WithIgnoreCase performs a case-insensitive search
+func WithIgnoreCase(enabled bool) Option {
+	return func(w *Worker) (err error) {
+		w.IgnoreCase = enabled
+		return
+	}
+}
+
+// WithPreserveCase tries to preserve the case of each replacement
+func WithPreserveCase(enabled bool) Option {
+	return func(w *Worker) (err error) {
+		w.PreserveCase = enabled
+		return
+	}
+}
+
+// WithFold sets the case folding used when ignoring or preserving case, one
+// of FoldSimple, FoldFull or FoldTurkic
+func WithFold(mode string) Option {
+	return func(w *Worker) (err error) {
+		mode = strings.ToLower(strings.TrimSpace(mode))
+		if err = parseFold(mode, ""); err == nil {
+			w.Fold = mode
+		}
+		return
+	}
+}
+
+// WithNormalize sets the unicode normalization applied to the content and
+// search terms before matching, one of NormalizeNFC or NormalizeNFD
+func WithNormalize(form string) Option {
+	return func(w *Worker) (err error) {
+		form = strings.ToLower(strings.TrimSpace(form))
+		if err = parseFold("", form); err == nil {
+			w.Normalize = form
+		}
+		return
+	}
+}
+
+// WithCasing adds to the casing dictionary of canonical spellings, used by
+// --preserve-case when spelling acronyms and brand names, ie: "GraphQL"
+func WithCasing(spellings ...string) Option {
+	return func(w *Worker) (err error) {
+		if w.casing == nil {
+			w.casing = make(cCasing)
+		}
+		if err = w.casing.add(spellings...); err != nil {
+			err = fmt.Errorf("--%s %w", CasingFlag.Name, err)
+			return
+		}
+		w.Casing = append(w.Casing, spellings...)
+		return
+	}
+}
+
+// WithDefines adds KEY=VALUE variables for use within the replacement text,
+// as ${KEY}
+func WithDefines(definitions ...string) Option {
+	return func(w *Worker) (err error) {
+		if w.defines == nil {
+			w.defines = make(map[string]string)
+		}
+		if err = parseDefines(w.defines, definitions...); err != nil {
+			err = fmt.Errorf("--%s %w", DefineFlag.Name, err)
+			return
+		}
+		w.Defines = append(w.Defines, definitions...)
+		return
+	}
+}
+
+// WithNop reports what would otherwise have been done
+func WithNop(enabled bool) Option {
+	return func(w *Worker) (err error) {
+		w.Nop = enabled
+		return
+	}
+}
+
+// WithNoLimits ignores the file size limit and searches all files in one
+// batch
+func WithNoLimits(enabled bool) Option {
+	return func(w *Worker) (err error) {
+		w.NoLimits = enabled
+		return
+	}
+}
+
+// WithMaxFileSize skips files larger than the size given, zero is the
+// rpl.MaxFileSize default
+func WithMaxFileSize(size int64) Option {
+	return func(w *Worker) (err error) {
+		w.MaxFileSize = size
+		return
+	}
+}
+
+// WithMaxFiles searches files in batches of the count given, zero is the
+// rpl.MaxFileCount default
+func WithMaxFiles(count int) Option {
+	return func(w *Worker) (err error) {
+		w.MaxFiles = count
+		return
+	}
+}
+
+// WithTimeout cancels the run when the duration given is exceeded, zero is no
+// timeout and the timeout is not applied when interactive
+func WithTimeout(timeout time.Duration) Option {
+	return func(w *Worker) (err error) {
+		w.Timeout = timeout
+		return
+	}
+}
+
+// WithFileTimeout abandons searching or replacing any one file when the
+// duration given is exceeded, zero is no timeout
+func WithFileTimeout(timeout time.Duration) Option {
+	return func(w *Worker) (err error) {
+		w.FileTimeout = timeout
+		return
+	}
+}
+
+// WithBackup makes backups before replacing content
+func WithBackup(enabled bool) Option {
+	return func(w *Worker) (err error) {
+		w.Backup = enabled
+		return
+	}
+}
+
+// WithBackupExtension sets the backup file suffix, implies WithBackup
+func WithBackupExtension(extension string) Option {
+	return func(w *Worker) (err error) {
+		w.BackupExtension = extension
+		return
+	}
+}
+
+// WithBackupTemplate sets the backup file name template, implies WithBackup
+func WithBackupTemplate(template string) Option {
+	return func(w *Worker) (err error) {
+		w.BackupTemplate = template
+		return
+	}
+}
+
+// WithBackupDir copies original files into the directory given, implies
+// WithBackup
+func WithBackupDir(dir string) Option {
+	return func(w *Worker) (err error) {
+		w.BackupDir = dir
+		return
+	}
+}
+
+// WithRunID sets the identifier of the run, used with backup names
+func WithRunID(id string) Option {
+	return func(w *Worker) (err error) {
+		w.RunID = id
+		return
+	}
+}
+
+// WithShowDiff outputs unified diffs for all changes
+func WithShowDiff(enabled bool) Option {
+	return func(w *Worker) (err error) {
+		w.ShowDiff = enabled
+		return
+	}
+}
+
+// WithPatchFormat sets the format of the unified diff output
+func WithPatchFormat(format string) Option {
+	return func(w *Worker) (err error) {
+		if err = patchFormatValid(format); err != nil {
+			err = fmt.Errorf("--patch-format %w", err)
+			return
+		}
+		w.PatchFormat = format
+		return
+	}
+}
+
+// WithApplyPatch applies the patch file given instead of searching
+func WithApplyPatch(file string) Option {
+	return func(w *Worker) (err error) {
+		w.ApplyPatchFile = file
+		return
+	}
+}
+
+// WithFuzz sets the number of context lines which may be ignored when
+// applying patches
+func WithFuzz(fuzz int) Option {
+	return func(w *Worker) (err error) {
+		w.Fuzz = fuzz
+		return
+	}
+}
+
+// WithInteractive uses the curses user interface for selecting changes
+func WithInteractive(enabled bool) Option {
+	return func(w *Worker) (err error) {
+		w.Interactive = enabled
+		return
+	}
+}
+
+// WithPause pauses the user interface before searching, implies
+// WithInteractive
+func WithPause(enabled bool) Option {
+	return func(w *Worker) (err error) {
+		w.Pause = enabled
+		return
+	}
+}
+
+// WithQuiet silences notices, takes precedence over WithVerbose
+func WithQuiet(enabled bool) Option {
+	return func(w *Worker) (err error) {
+		w.Quiet = enabled
+		return
+	}
+}
+
+// WithVerbose outputs additional notices
+func WithVerbose(enabled bool) Option {
+	return func(w *Worker) (err error) {
+		w.Verbose = enabled
+		return
+	}
+}
+
+// WithObserver subscribes the Observer given to all events, see
+// Worker.Subscribe
+func WithObserver(observer Observer) Option {
+	return func(w *Worker) (err error) {
+		w.Subscribe(observer)
+		return
+	}
+}
+
+// WithFileSystem sets the FileSystem used for all file operations, see
+// NewMemFileSystem for running over virtual trees of files
+func WithFileSystem(fsys FileSystem) Option {
+	return func(w *Worker) (err error) {
+		w.FileSystem = fsys
+		return
+	}
+}
+
+// WithDecompress searches within gzip, bzip2 and xz compressed files, any
+// changes are recompressed with the same format and level
+func WithDecompress(enabled bool) Option {
+	return func(w *Worker) (err error) {
+		w.Decompress = enabled
+		return
+	}
+}
+
+// WithJSONPaths limits replacements to the JSON values at the key paths given,
+// see ParseKeyPath for the selector syntax
+func WithJSONPaths(selectors ...string) Option {
+	return func(w *Worker) (err error) {
+		w.JSONPaths, err = parseKeyPaths(JSONPathFlag.Name, w.JSONPaths, selectors)
+		return
+	}
+}
+
+// WithYAMLPaths limits replacements to the YAML values at the key paths given,
+// see ParseKeyPath for the selector syntax
+func WithYAMLPaths(selectors ...string) Option {
+	return func(w *Worker) (err error) {
+		w.YAMLPaths, err = parseKeyPaths(YAMLPathFlag.Name, w.YAMLPaths, selectors)
+		return
+	}
+}
+
+// WithTOMLPaths limits replacements to the TOML values at the key paths given,
+// see ParseKeyPath for the selector syntax
+func WithTOMLPaths(selectors ...string) Option {
+	return func(w *Worker) (err error) {
+		w.TOMLPaths, err = parseKeyPaths(TOMLPathFlag.Name, w.TOMLPaths, selectors)
+		return
+	}
+}
+
+// WithMarkup restricts replacements to the text content ("text") or to the
+// attribute values ("attr", or "attr:name,...") of markup files, matching
+// against the text with any character references decoded
+func WithMarkup(spec string) Option {
+	return func(w *Worker) (err error) {
+		if w.Markup = spec; spec == "" {
+			w.markup = nil
+		} else if w.markup, err = parseMarkup(spec); err != nil {
+			err = fmt.Errorf("--%s %w", MarkupFlag.Name, err)
+		}
+		return
+	}
+}
+
+// WithMarkdown restricts replacements to the prose ("prose") or to the code
+// blocks and code spans ("code", or "code:lang,...") of markdown files
+func WithMarkdown(spec string) Option {
+	return func(w *Worker) (err error) {
+		if w.Markdown = spec; spec == "" {
+			w.markdown = nil
+		} else if w.markdown, err = parseMarkdown(spec); err != nil {
+			err = fmt.Errorf("--%s %w", MarkdownFlag.Name, err)
+		}
+		return
+	}
+}
+
+// WithFilter replaces within the content given to Worker.FilterContent
+// instead of searching for files, cannot be used with any paths
+func WithFilter(enabled bool) Option {
+	return func(w *Worker) (err error) {
+		w.Filter = enabled
+		return
+	}
+}
+
+// WithNotifier sets the notify.Notifier to use, the levels and outputs are
+// still configured by the Worker
+func WithNotifier(notifier notify.Notifier) Option {
+	return func(w *Worker) (err error) {
+		w.Notifier = notifier
+		return
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/go-corelibs/notify"
+)
+
+func TestNew(t *testing.T) {
+	m := &sync.Mutex{}
+
+	Convey("Implied", t, func() {
+		m.Lock()
+		defer m.Unlock()
+		outio, errio, _ := makeWorker()
+		defer outio.Restore()
+		defer errio.Restore()
+
+		w, err := New(
+			WithArgv("search", "replace", "one", "-", "two"),
+			WithMultiLine(true),
+			WithPause(true),
+			WithBackupExtension(".bak"),
+			WithQuiet(true),
+		)
+		So(err, ShouldEqual, nil)
+		So(w, ShouldNotEqual, nil)
+		So(w.Search, ShouldEqual, "search")
+		So(w.Replace, ShouldEqual, "replace")
+		So(w.Paths, ShouldEqual, []string{"one", "two"})
+		So(w.Stdin, ShouldEqual, true)
+		So(w.Regex, ShouldEqual, true)
+		So(w.Pattern, ShouldNotEqual, nil)
+		So(w.Interactive, ShouldEqual, true)
+		So(w.Backup, ShouldEqual, true)
+		So(w.Notifier, ShouldNotEqual, nil)
+
+		// the argv is parsed after all other options
+		w, err = New(WithArgv("search", "replace", "-"), WithStdin(false))
+		So(err, ShouldEqual, nil)
+		So(w.Stdin, ShouldEqual, true)
+		So(w.Paths, ShouldHaveLength, 0)
+
+		w, err = New(WithSearch("search", "replace"), WithNull(true))
+		So(err, ShouldEqual, nil)
+		So(w.Stdin, ShouldEqual, true)
+		So(w.Paths, ShouldHaveLength, 0)
+
+		w, err = New(WithSearch("search", "replace"), WithNotifier(notify.New(notify.Quiet).Make()))
+		So(err, ShouldEqual, nil)
+		So(w.Paths, ShouldEqual, []string{"."})
+		So(w.Exclude, ShouldHaveLength, 1)
+	})
+
+	Convey("Conflicts", t, func() {
+		m.Lock()
+		defer m.Unlock()
+		outio, errio, _ := makeWorker()
+		defer outio.Restore()
+		defer errio.Restore()
+
+		for _, options := range [][]Option{
+			{WithBackupExtension(".bak"), WithBackupTemplate("{name}.orig")},
+			{WithBackupExtension(".bak"), WithBackupDir(t.TempDir())},
+			{WithBackupTemplate("{name}.orig"), WithBackupDir(t.TempDir())},
+			{WithApplyPatch("changes.patch"), WithInteractive(true)},
+			{WithMaxFileSize(-1)},
+			{WithMaxFiles(-1)},
+			{WithFuzz(-1)},
+			{WithPatchFormat("nope")},
+			{WithRegex(true), WithSearch("(", "")},
+		} {
+			w, err := New(options...)
+			So(err, ShouldNotEqual, nil)
+			So(w, ShouldBeNil)
+		}
+	})
+}
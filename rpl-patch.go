@@ -0,0 +1,451 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-corelibs/path"
+)
+
+const (
+	// PatchFormatUnified is the plain unified diff format, with "a/" and "b/"
+	// prefixed paths relative to the current working directory
+	PatchFormatUnified = "unified"
+	// PatchFormatGit is the unified diff format with a leading "diff --git"
+	// line for each file, suitable for use with "git apply"
+	PatchFormatGit = "git"
+
+	// DefaultPatchFuzz is the default number of context lines which may be
+	// ignored when applying a hunk that does not otherwise match
+	DefaultPatchFuzz = 2
+)
+
+var (
+	ErrPatchFormat = errors.New("invalid patch")
+)
+
+// PatchHunk is a single hunk of a unified diff, Lines include the leading
+// " ", "-" or "+" character and the trailing newline, if any
+type PatchHunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []string
+}
+
+// String returns the hunk in unified diff format
+func (h *PatchHunk) String() (hunk string) {
+	var buf strings.Builder
+	buf.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", h.OldStart, h.OldLines, h.NewStart, h.NewLines))
+	for _, line := range h.Lines {
+		buf.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			buf.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+	hunk = buf.String()
+	return
+}
+
+// lines returns the old and new content lines of the hunk, with fuzz number
+// of context lines removed from the start and end of the hunk
+func (h *PatchHunk) lines(fuzz int) (old, changed []string, leading int) {
+	first, last := 0, len(h.Lines)
+	for ; leading < fuzz && first < last && h.Lines[first][0] == ' '; first, leading = first+1, leading+1 {
+	}
+	for trailing := 0; trailing < fuzz && last > first && h.Lines[last-1][0] == ' '; last, trailing = last-1, trailing+1 {
+	}
+	for _, line := range h.Lines[first:last] {
+		switch line[0] {
+		case ' ':
+			old = append(old, line[1:])
+			changed = append(changed, line[1:])
+		case '-':
+			old = append(old, line[1:])
+		case '+':
+			changed = append(changed, line[1:])
+		}
+	}
+	return
+}
+
+// FilePatch is the list of hunks to apply to a single file
+type FilePatch struct {
+	OldPath string
+	NewPath string
+	Hunks   []*PatchHunk
+}
+
+// Target returns the path of the file to patch, relative to the current
+// working directory with the leading "a/" or "b/" removed
+func (f *FilePatch) Target() (target string) {
+	target = f.target(path.Exists)
+	return
+}
+
+// target is Target using the exists func given to check for absolute paths
+func (f *FilePatch) target(exists func(file string) bool) (target string) {
+	target = f.NewPath
+	if target == "" || target == "/dev/null" {
+		target = f.OldPath
+	}
+	if strings.HasPrefix(target, "a/") || strings.HasPrefix(target, "b/") {
+		target = target[2:]
+		if !exists(target) && exists("/"+target) {
+			// absolute paths are rendered as "a/path/to/file"
+			target = "/" + target
+		}
+	}
+	return
+}
+
+// PatchHunkResult describes how a PatchHunk was applied
+type PatchHunkResult struct {
+	Hunk     *PatchHunk
+	Line     int // line number the hunk was applied at, starting at 1
+	Offset   int // number of lines from the expected line
+	Fuzz     int // number of context lines ignored
+	Rejected bool
+}
+
+// Apply applies the hunks to the content given, searching for the best
+// position of each hunk and ignoring up to fuzz context lines when a hunk
+// otherwise does not match
+func (f *FilePatch) Apply(content string, fuzz int) (modified string, results []PatchHunkResult) {
+	src := strings.SplitAfter(content, "\n")
+	if src[len(src)-1] == "" {
+		src = src[:len(src)-1]
+	}
+
+	var floor, shift int
+	for _, hunk := range f.Hunks {
+		result := PatchHunkResult{Hunk: hunk, Rejected: true}
+		for result.Fuzz = 0; result.Fuzz <= fuzz && result.Rejected; result.Fuzz++ {
+			old, changed, leading := hunk.lines(result.Fuzz)
+			expected := hunk.OldStart - 1 + shift + leading
+			if hunk.OldLines == 0 {
+				expected += 1
+			}
+			if pos, ok := findLines(src, old, expected, floor); ok {
+				updated := make([]string, 0, len(src)+len(changed)-len(old))
+				updated = append(updated, src[:pos]...)
+				updated = append(updated, changed...)
+				updated = append(updated, src[pos+len(old):]...)
+				src = updated
+				result.Rejected = false
+				result.Line = pos - leading + 1
+				result.Offset = pos - expected
+				floor = pos + len(changed)
+				shift += result.Offset + len(changed) - len(old)
+				break
+			}
+		}
+		if result.Rejected {
+			result.Fuzz = 0
+		}
+		results = append(results, result)
+	}
+
+	modified = strings.Join(src, "")
+	return
+}
+
+// findLines looks for the lines given within src, starting at the expected
+// position and searching outwards, without going before the floor
+func findLines(src, lines []string, expected, floor int) (pos int, ok bool) {
+	match := func(at int) bool {
+		if at < floor || at+len(lines) > len(src) {
+			return false
+		}
+		for idx, line := range lines {
+			if src[at+idx] != line {
+				return false
+			}
+		}
+		return true
+	}
+	for delta := 0; expected-delta >= floor || expected+delta <= len(src); delta++ {
+		if match(expected - delta) {
+			return expected - delta, true
+		} else if delta > 0 && match(expected+delta) {
+			return expected + delta, true
+		}
+	}
+	return
+}
+
+// ParsePatch parses the unified diff content given, which may include the
+// "diff --git" lines and any other text between files
+func ParsePatch(content string) (files []*FilePatch, err error) {
+	lines := strings.SplitAfter(content, "\n")
+	var current *FilePatch
+	var hunk *PatchHunk
+	var remOld, remNew int
+
+	for idx := 0; idx < len(lines); idx++ {
+		line := lines[idx]
+		if hunk != nil && (remOld > 0 || remNew > 0) {
+			if line == "" && idx == len(lines)-1 {
+				break
+			} else if line == "" || line == "\n" {
+				// some editors strip the trailing space of empty context lines
+				line = " \n"
+			}
+			switch line[0] {
+			case ' ':
+				remOld, remNew = remOld-1, remNew-1
+			case '-':
+				remOld -= 1
+			case '+':
+				remNew -= 1
+			case '\\':
+				trimLastHunkLine(hunk)
+				continue
+			default:
+				err = fmt.Errorf("%w: line %d: unexpected %q within hunk", ErrPatchFormat, idx+1, strings.TrimSpace(line))
+				return
+			}
+			hunk.Lines = append(hunk.Lines, line)
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, `\`):
+			// no newline at end of file, applies to the last hunk line
+			trimLastHunkLine(hunk)
+		case strings.HasPrefix(line, "--- ") && idx+1 < len(lines) && strings.HasPrefix(lines[idx+1], "+++ "):
+			current = &FilePatch{
+				OldPath: parsePatchPath(line[4:]),
+				NewPath: parsePatchPath(lines[idx+1][4:]),
+			}
+			files = append(files, current)
+			hunk = nil
+			idx += 1
+		case strings.HasPrefix(line, "@@ "):
+			if current == nil {
+				err = fmt.Errorf("%w: line %d: hunk without file headers", ErrPatchFormat, idx+1)
+				return
+			}
+			hunk = &PatchHunk{}
+			if hunk.OldStart, hunk.OldLines, hunk.NewStart, hunk.NewLines, err = parseHunkHeader(line); err != nil {
+				err = fmt.Errorf("%w: line %d: %v", ErrPatchFormat, idx+1, err)
+				return
+			}
+			remOld, remNew = hunk.OldLines, hunk.NewLines
+			current.Hunks = append(current.Hunks, hunk)
+		}
+	}
+
+	if hunk != nil && (remOld > 0 || remNew > 0) {
+		err = fmt.Errorf("%w: truncated hunk", ErrPatchFormat)
+	} else if len(files) == 0 {
+		err = fmt.Errorf("%w: no file headers found", ErrPatchFormat)
+	}
+	return
+}
+
+func trimLastHunkLine(hunk *PatchHunk) {
+	if hunk != nil && len(hunk.Lines) > 0 {
+		last := len(hunk.Lines) - 1
+		hunk.Lines[last] = strings.TrimSuffix(hunk.Lines[last], "\n")
+	}
+}
+
+func parsePatchPath(header string) (name string) {
+	name = strings.TrimRight(header, "\r\n")
+	if idx := strings.IndexByte(name, '\t'); idx >= 0 {
+		name = name[:idx]
+	}
+	return
+}
+
+func parseHunkHeader(line string) (oldStart, oldLines, newStart, newLines int, err error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[3] != "@@" || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		err = fmt.Errorf("invalid hunk header %q", strings.TrimSpace(line))
+		return
+	}
+	parse := func(value string) (start, count int, err error) {
+		count = 1
+		if before, after, found := strings.Cut(value, ","); found {
+			if count, err = strconv.Atoi(after); err != nil {
+				return
+			}
+			value = before
+		}
+		start, err = strconv.Atoi(value)
+		return
+	}
+	if oldStart, oldLines, err = parse(fields[1][1:]); err == nil {
+		newStart, newLines, err = parse(fields[2][1:])
+	}
+	return
+}
+
+// FormatPatch returns the unified diff given in the Worker.PatchFormat
+func (w *Worker) FormatPatch(name, unified string) (patch string) {
+	if patch = unified; patch != "" && w.PatchFormat == PatchFormatGit {
+		a, b := "a", "b"
+		if name != "" && name[0] != '/' {
+			a += "/"
+			b += "/"
+		}
+		patch = "diff --git " + a + name + " " + b + name + "\n" + unified
+	}
+	return
+}
+
+// PatchResult describes how a FilePatch was applied to its target file
+type PatchResult struct {
+	Target  string
+	Backup  string
+	Rejects string
+	Hunks   []PatchHunkResult
+}
+
+// Rejected returns the number of hunks which could not be applied
+func (r PatchResult) Rejected() (count int) {
+	for _, hunk := range r.Hunks {
+		if hunk.Rejected {
+			count += 1
+		}
+	}
+	return
+}
+
+// PatchResultFn is the callback used by Worker.ApplyPatch to report the
+// result of each file patched, or the error encountered
+type PatchResultFn func(result PatchResult, err error)
+
+// ApplyPatch applies the unified diff read from the file given. Backups are
+// made in the same manner as replacements and any rejected hunks are written
+// to a file named after the target with a ".rej" extension
+func (w *Worker) ApplyPatch(name string, fn PatchResultFn) (err error) {
+	var data []byte
+	if data, err = w.readFile(name); err != nil {
+		err = fmt.Errorf("--%s %w", ApplyPatchFlag.Name, err)
+		return
+	}
+	var files []*FilePatch
+	if files, err = ParsePatch(string(data)); err != nil {
+		err = fmt.Errorf("--%s %q %w", ApplyPatchFlag.Name, name, err)
+		return
+	}
+
+	fuzz := w.Fuzz
+	if fuzz < 0 {
+		fuzz = 0
+	}
+
+	lookup := make(map[string]*FilePatch)
+	w.Files, w.Matched = nil, nil
+	for _, file := range files {
+		target := file.target(w.exists)
+		if existing, present := lookup[target]; present {
+			// patches concatenated from separate runs, the hunks of each
+			// refer to the same original content
+			existing.Hunks = append(existing.Hunks, file.Hunks...)
+			sort.SliceStable(existing.Hunks, func(i, j int) bool {
+				return existing.Hunks[i].OldStart < existing.Hunks[j].OldStart
+			})
+			continue
+		}
+		lookup[target] = file
+		w.Files = append(w.Files, target)
+		if w.isFile(target) {
+			w.Matched = append(w.Matched, target)
+		} else {
+			ee := fmt.Errorf("%w: %q", ErrNotFound, target)
+			w.emit(ErrorEvent{File: target, Err: ee})
+			if fn != nil {
+				fn(PatchResult{Target: target}, ee)
+			}
+		}
+	}
+
+	for iter := w.StartIterating(); iter.Valid(); iter.Next() {
+		if err = w.Cancelled(); err != nil {
+			// no further files are patched
+			break
+		}
+		result, ee := iter.applyFilePatch(lookup[iter.Name()], fuzz)
+		if fn != nil {
+			fn(result, ee)
+		}
+	}
+	return
+}
+
+func (i *Iterator) applyFilePatch(file *FilePatch, fuzz int) (result PatchResult, err error) {
+	result.Target = i.Name()
+
+	var data, text []byte
+	if data, err = i.w.readFile(result.Target); err != nil {
+		i.w.emit(ErrorEvent{File: result.Target, Err: err})
+		return
+	} else if i.snap, err = i.w.newSnapshot(result.Target, string(data)); err != nil {
+		i.w.emit(ErrorEvent{File: result.Target, Err: err})
+		return
+	} else if text, i.codec, err = i.w.decodeText(data); err != nil {
+		i.w.emit(ErrorEvent{File: result.Target, Err: err})
+		return
+	}
+
+	var modified string
+	modified, result.Hunks = file.Apply(string(text), fuzz)
+	i.w.emit(DiffComputedEvent{File: result.Target, Count: len(result.Hunks) - result.Rejected()})
+
+	if rejected := result.Rejected(); rejected > 0 {
+		result.Rejects = result.Target + ".rej"
+		if !i.w.Nop {
+			var buf strings.Builder
+			buf.WriteString("--- " + file.OldPath + "\n")
+			buf.WriteString("+++ " + file.NewPath + "\n")
+			for _, hunk := range result.Hunks {
+				if hunk.Rejected {
+					buf.WriteString(hunk.Hunk.String())
+				}
+			}
+			if err = i.w.writeFile(result.Rejects, []byte(buf.String()), 0644); err != nil {
+				i.w.emit(ErrorEvent{File: result.Rejects, Err: err})
+				return
+			}
+		}
+		if rejected == len(result.Hunks) {
+			return
+		}
+	}
+
+	if modified != string(text) {
+		result.Backup, err = i.write(modified)
+	}
+	return
+}
+
+// patchFormatValid returns an error if the format given is not supported
+func patchFormatValid(format string) (err error) {
+	switch format {
+	case "", PatchFormatUnified, PatchFormatGit:
+	default:
+		err = fmt.Errorf("%q is not one of: %s, %s", format, PatchFormatUnified, PatchFormatGit)
+	}
+	return
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func makePatchSource(from, to int) (source string) {
+	for i := from; i <= to; i++ {
+		source += fmt.Sprintf("line %d\n", i)
+	}
+	return
+}
+
+func TestPatch(t *testing.T) {
+	m := &sync.Mutex{}
+
+	source := makePatchSource(0, 29)
+	modified := strings.Replace(source, "line 9\n", "LINE 9\n", 1)
+	modified = strings.Replace(modified, "line 20\n", "LINE 20\n", 1)
+	unified := NewEdits("file.txt", source, []Edit{
+		{Start: strings.Index(source, "line 9\n"), End: strings.Index(source, "line 9\n") + 6, Text: "LINE 9"},
+	}).Unified()
+
+	Convey("Format", t, func() {
+		w := &Worker{}
+		So(w.FormatPatch("file.txt", unified), ShouldEqual, unified)
+		w.PatchFormat = PatchFormatGit
+		So(w.FormatPatch("file.txt", unified), ShouldEqual, "diff --git a/file.txt b/file.txt\n"+unified)
+		So(w.FormatPatch("/tmp/file.txt", unified), ShouldStartWith, "diff --git a/tmp/file.txt b/tmp/file.txt\n")
+		So(w.FormatPatch("file.txt", ""), ShouldEqual, "")
+		So(patchFormatValid("nope"), ShouldNotEqual, nil)
+	})
+
+	Convey("Parse", t, func() {
+		files, err := ParsePatch("diff --git a/file.txt b/file.txt\n" + unified)
+		So(err, ShouldEqual, nil)
+		So(files, ShouldHaveLength, 1)
+		So(files[0].Target(), ShouldEqual, "file.txt")
+		So(files[0].Hunks, ShouldHaveLength, 1)
+		So(files[0].Hunks[0].String(), ShouldEqual, unified[strings.Index(unified, "@@"):])
+
+		files, err = ParsePatch("--- a/x\n+++ b/x\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+b\n\\ No newline at end of file\n")
+		So(err, ShouldEqual, nil)
+		So(files[0].Hunks[0].Lines, ShouldEqual, []string{"-a", "+b"})
+
+		_, err = ParsePatch("nothing to see here\n")
+		So(errors.Is(err, ErrPatchFormat), ShouldEqual, true)
+		_, err = ParsePatch("--- a/x\n+++ b/x\n@@ -1,2 +1,2 @@\n-a\n")
+		So(errors.Is(err, ErrPatchFormat), ShouldEqual, true)
+		_, err = ParsePatch("--- a/x\n+++ b/x\n@@ -1 +1 @@\n?a\n")
+		So(errors.Is(err, ErrPatchFormat), ShouldEqual, true)
+	})
+
+	Convey("Apply", t, func() {
+		files, _ := ParsePatch(unified)
+
+		// exact
+		result, hunks := files[0].Apply(source, 0)
+		So(hunks, ShouldHaveLength, 1)
+		So(hunks[0].Rejected, ShouldEqual, false)
+		So(result, ShouldEqual, strings.Replace(source, "line 9\n", "LINE 9\n", 1))
+
+		// offset
+		result, hunks = files[0].Apply("one\ntwo\n"+source, 0)
+		So(hunks[0].Rejected, ShouldEqual, false)
+		So(hunks[0].Offset, ShouldEqual, 2)
+		So(result, ShouldEqual, "one\ntwo\n"+strings.Replace(source, "line 9\n", "LINE 9\n", 1))
+
+		// fuzz
+		changed := strings.Replace(source, "line 6\n", "line SIX\n", 1)
+		_, hunks = files[0].Apply(changed, 0)
+		So(hunks[0].Rejected, ShouldEqual, true)
+		result, hunks = files[0].Apply(changed, 2)
+		So(hunks[0].Rejected, ShouldEqual, false)
+		So(hunks[0].Fuzz, ShouldEqual, 1)
+		So(result, ShouldEqual, strings.Replace(changed, "line 9\n", "LINE 9\n", 1))
+
+		// rejected
+		result, hunks = files[0].Apply(modified, 2)
+		So(hunks[0].Rejected, ShouldEqual, true)
+		So(result, ShouldEqual, modified)
+	})
+
+	Convey("Worker", t, func() {
+		m.Lock()
+		defer m.Unlock()
+		outio, errio, w := makeWorker()
+		defer outio.Restore()
+		defer errio.Restore()
+		dir := t.TempDir()
+		target := filepath.Join(dir, "file.txt")
+		So(os.WriteFile(target, []byte(source), 0644), ShouldEqual, nil)
+		other := filepath.Join(dir, "other.txt")
+		So(os.WriteFile(other, []byte(modified), 0644), ShouldEqual, nil)
+
+		patch := NewEdits(target, source, []Edit{{Start: 0, End: 6, Text: "LINE 0"}}).Unified()
+		patch += strings.ReplaceAll(unified, "file.txt", target)
+		patch += strings.ReplaceAll(unified, "file.txt", other)
+		patch += strings.ReplaceAll(unified, "file.txt", filepath.Join(dir, "missing.txt"))
+		patchFile := filepath.Join(dir, "changes.patch")
+		So(os.WriteFile(patchFile, []byte(patch), 0644), ShouldEqual, nil)
+
+		w.Backup = true
+		w.Fuzz = DefaultPatchFuzz
+		So(w.Init(), ShouldEqual, nil)
+
+		var results []PatchResult
+		var errs []error
+		err := w.ApplyPatch(patchFile, func(result PatchResult, err error) {
+			results = append(results, result)
+			errs = append(errs, err)
+		})
+		So(err, ShouldEqual, nil)
+		So(results, ShouldHaveLength, 3)
+		So(errors.Is(errs[0], ErrNotFound), ShouldEqual, true)
+
+		So(errs[1], ShouldEqual, nil)
+		So(results[1].Target, ShouldEqual, target)
+		So(results[1].Rejected(), ShouldEqual, 0)
+		So(results[1].Backup, ShouldEqual, target+"~")
+		data, _ := os.ReadFile(target)
+		So(string(data), ShouldEqual, strings.Replace(strings.Replace(source, "line 9\n", "LINE 9\n", 1), "line 0", "LINE 0", 1))
+
+		So(errs[2], ShouldEqual, nil)
+		So(results[2].Target, ShouldEqual, other)
+		So(results[2].Rejected(), ShouldEqual, 1)
+		So(results[2].Rejects, ShouldEqual, other+".rej")
+		data, _ = os.ReadFile(other)
+		So(string(data), ShouldEqual, modified)
+		data, _ = os.ReadFile(other + ".rej")
+		So(string(data), ShouldContainSubstring, "+LINE 9\n")
+
+		err = w.ApplyPatch(filepath.Join(dir, "nope.patch"), nil)
+		So(err, ShouldNotEqual, nil)
+	})
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// Snapshot is a record of a file's state at the time a Diff was computed and
+// is used to detect changes made to the file by other processes before the
+// modified content is written
+type Snapshot struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+	Hash    string
+
+	stat func(file string) (fs.FileInfo, error)
+	read func(file string) ([]byte, error)
+}
+
+// NewSnapshot records the current size and modification time of the file at
+// the given path, along with the hash of the content given, which is expected
+// to be the content read from the file
+func NewSnapshot(path, content string) (s *Snapshot, err error) {
+	s, err = newSnapshot(path, content, os.Stat, os.ReadFile)
+	return
+}
+
+// newSnapshot is NewSnapshot using the Worker.FileSystem
+func (w *Worker) newSnapshot(path, content string) (s *Snapshot, err error) {
+	s, err = newSnapshot(path, content, w.stat, w.readFile)
+	return
+}
+
+func newSnapshot(path, content string, stat func(file string) (fs.FileInfo, error), read func(file string) ([]byte, error)) (s *Snapshot, err error) {
+	var info fs.FileInfo
+	if info, err = stat(path); err != nil {
+		return
+	}
+	s = &Snapshot{
+		Path:    path,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Hash:    hashContent([]byte(content)),
+		stat:    stat,
+		read:    read,
+	}
+	return
+}
+
+// Verify checks the file again, returning ErrFileModified if the size or the
+// content has changed since the Snapshot was made. When only the modification
+// time differs, the content is hashed to confirm the change
+func (s *Snapshot) Verify() (err error) {
+	statFn, readFn := s.stat, s.read
+	if statFn == nil || readFn == nil {
+		statFn, readFn = os.Stat, os.ReadFile
+	}
+
+	var stat fs.FileInfo
+	if stat, err = statFn(s.Path); err != nil {
+		return
+	}
+
+	if stat.Size() != s.Size {
+		err = fmt.Errorf("%w: %q (size changed)", ErrFileModified, s.Path)
+		return
+	} else if stat.ModTime().Equal(s.ModTime) {
+		// same size and same modification time
+		return
+	}
+
+	var data []byte
+	if data, err = readFn(s.Path); err != nil {
+		return
+	} else if hashContent(data) != s.Hash {
+		err = fmt.Errorf("%w: %q (content changed)", ErrFileModified, s.Path)
+	}
+	return
+}
+
+func hashContent(data []byte) (hash string) {
+	sum := sha256.Sum256(data)
+	hash = hex.EncodeToString(sum[:])
+	return
+}
@@ -0,0 +1,861 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	slashpath "path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// StructuredJSON is the name of the JSON key path format
+	StructuredJSON = "json"
+	// StructuredYAML is the name of the YAML key path format
+	StructuredYAML = "yaml"
+	// StructuredTOML is the name of the TOML key path format
+	StructuredTOML = "toml"
+)
+
+// KeyPath is a parsed key path selector, a list of segments each matched
+// against one key (or array index) of the path to a scalar value. Segments
+// may use the path.Match syntax, ie: "*" matches any one key, and a segment of
+// "**" matches any number of keys
+type KeyPath []string
+
+// ParseKeyPath parses the dot-separated selector given, array indexes may be
+// given as segments or with brackets, ie: "services.*.ports.0" is the same as
+// "services.*.ports[0]"
+func ParseKeyPath(selector string) (kp KeyPath, err error) {
+	selector = strings.NewReplacer("[", ".", "]", "").Replace(strings.TrimSpace(selector))
+	if selector == "" {
+		err = fmt.Errorf("empty key path")
+		return
+	}
+	for _, segment := range strings.Split(selector, ".") {
+		if segment == "" {
+			err = fmt.Errorf("empty segment in key path %q", selector)
+			return
+		} else if _, err = slashpath.Match(segment, ""); err != nil {
+			err = fmt.Errorf("key path %q segment %q: %w", selector, segment, err)
+			return
+		}
+		kp = append(kp, segment)
+	}
+	return
+}
+
+// Match returns true if the key path selector matches the path given
+func (kp KeyPath) Match(path []string) (matched bool) {
+	if len(kp) == 0 {
+		return len(path) == 0
+	} else if kp[0] == "**" {
+		for idx := 0; idx <= len(path); idx++ {
+			if kp[1:].Match(path[idx:]) {
+				return true
+			}
+		}
+		return
+	} else if len(path) == 0 {
+		return
+	} else if ok, _ := slashpath.Match(kp[0], path[0]); !ok {
+		return
+	}
+	matched = kp[1:].Match(path[1:])
+	return
+}
+
+// String returns the selector of the KeyPath
+func (kp KeyPath) String() string {
+	return strings.Join(kp, ".")
+}
+
+// parseKeyPaths appends the parsed selectors to the list given
+func parseKeyPaths(flag string, list []KeyPath, selectors []string) (parsed []KeyPath, err error) {
+	parsed = list
+	for _, selector := range selectors {
+		var kp KeyPath
+		if kp, err = ParseKeyPath(selector); err != nil {
+			err = fmt.Errorf("--%s %w", flag, err)
+			return
+		}
+		parsed = append(parsed, kp)
+	}
+	return
+}
+
+// cQuoting is the quoting style of a scalar value, which determines how the
+// value is decoded for matching and how replacements are written
+type cQuoting uint8
+
+const (
+	// quotingNone is for bare values, which are written as-is
+	quotingNone cQuoting = iota
+	// quotingBackslash is for JSON strings, TOML basic strings and YAML
+	// double-quoted strings, with backslash escapes
+	quotingBackslash
+	// quotingMultiLine is for TOML multi-line basic strings, with backslash
+	// escapes and literal newlines
+	quotingMultiLine
+	// quotingLiteral is for TOML literal strings, without any escapes
+	quotingLiteral
+	// quotingMultiLiteral is for TOML multi-line literal strings, without any
+	// escapes and with literal newlines
+	quotingMultiLiteral
+	// quotingDoubled is for YAML single-quoted strings, where a quote is
+	// escaped by doubling it
+	quotingDoubled
+)
+
+// cValueSpan is the byte range of a scalar value within the content, for
+// quoted strings the range excludes the quotes
+type cValueSpan struct {
+	path       []string
+	start, end int
+	quoting    cQuoting
+}
+
+// decode returns the source with all escape sequences decoded, along with
+// the source offset of each decoded byte offset, offsets within a decoded
+// escape sequence are -1 as these are not valid edit boundaries
+func (s cValueSpan) decode(source string) (decoded string, offsets []int) {
+	var buf strings.Builder
+	for pos := 0; pos < len(source); {
+		var size int
+		var text string
+		switch {
+		case source[pos] == '\\' && (s.quoting == quotingBackslash || s.quoting == quotingMultiLine):
+			size, text = unescapeBackslash(source[pos:])
+		case s.quoting == quotingDoubled && strings.HasPrefix(source[pos:], "''"):
+			size, text = 2, "'"
+		}
+		if size > 0 {
+			for i := 0; i < len(text); i++ {
+				if i == 0 {
+					offsets = append(offsets, pos)
+				} else {
+					offsets = append(offsets, -1)
+				}
+			}
+			buf.WriteString(text)
+			pos += size
+			continue
+		}
+		offsets = append(offsets, pos)
+		buf.WriteByte(source[pos])
+		pos += 1
+	}
+	offsets = append(offsets, len(source))
+	decoded = buf.String()
+	return
+}
+
+// unescapeBackslash decodes the backslash escape sequence at the start of the
+// source given, returning the size of the sequence and the text it stands
+// for. Line-ending backslashes stand for nothing, and unknown sequences stand
+// for themselves
+func unescapeBackslash(source string) (size int, text string) {
+	if len(source) < 2 {
+		size, text = len(source), source
+		return
+	}
+	size, text = 2, source[:2]
+	switch c := source[1]; c {
+	case '"', '\\', '/':
+		text = string(c)
+	case 'b':
+		text = "\b"
+	case 'f':
+		text = "\f"
+	case 'n':
+		text = "\n"
+	case 'r':
+		text = "\r"
+	case 't':
+		text = "\t"
+	case 'e':
+		text = "\x1b"
+	case '0':
+		text = "\x00"
+	case 'x', 'u', 'U':
+		n := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+		if len(source) < 2+n {
+			return
+		}
+		v, err := strconv.ParseUint(source[2:2+n], 16, 32)
+		if err != nil {
+			return
+		}
+		r := rune(v)
+		size = 2 + n
+		if utf16.IsSurrogate(r) && len(source) >= size+6 && source[size:size+2] == `\u` {
+			// JSON encodes runes beyond the BMP as surrogate pairs
+			if low, ee := strconv.ParseUint(source[size+2:size+6], 16, 32); ee == nil {
+				if pair := utf16.DecodeRune(r, rune(low)); pair != utf8.RuneError {
+					r, size = pair, size+6
+				}
+			}
+		}
+		text = string(r)
+	case ' ', '\t', '\r', '\n':
+		// a line-ending backslash trims all whitespace up to the next
+		// non-whitespace character
+		if rest := strings.TrimLeft(source[1:], " \t\r"); strings.HasPrefix(rest, "\n") {
+			rest = strings.TrimLeft(rest, " \t\r\n")
+			size, text = len(source)-len(rest), ""
+		}
+	}
+	return
+}
+
+// escape encodes the replacement text for the span, such that the structure
+// of the content cannot be changed, ok is false when the text cannot be
+// written within the span at all, as with literal strings
+func (s cValueSpan) escape(text string) (escaped string, ok bool) {
+	var buf strings.Builder
+	for _, r := range text {
+		switch s.quoting {
+		case quotingBackslash, quotingMultiLine:
+			switch {
+			case r == '"' || r == '\\':
+				buf.WriteByte('\\')
+				buf.WriteRune(r)
+			case r == '\t', r == '\n' && s.quoting == quotingMultiLine:
+				buf.WriteRune(r)
+			case r == '\n':
+				buf.WriteString(`\n`)
+			case r == '\r':
+				buf.WriteString(`\r`)
+			case r < 0x20 || r == 0x7f:
+				buf.WriteString(fmt.Sprintf(`\u%04x`, r))
+			default:
+				buf.WriteRune(r)
+			}
+		case quotingLiteral, quotingMultiLiteral:
+			if r != '\t' && (r < 0x20 || r == 0x7f) && (s.quoting == quotingLiteral || r != '\n') {
+				return
+			} else if r == '\'' && s.quoting == quotingLiteral {
+				return
+			}
+			buf.WriteRune(r)
+		case quotingDoubled:
+			if r == '\n' {
+				return
+			} else if r == '\'' {
+				buf.WriteRune(r)
+			}
+			buf.WriteRune(r)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	escaped = buf.String()
+	if ok = s.quoting != quotingMultiLiteral || !strings.Contains(escaped, "'''"); !ok {
+		escaped = ""
+	}
+	return
+}
+
+// isScoped returns true if replacements are limited to parts of the files,
+// either by structured key paths, markup or markdown
+func (w *Worker) isScoped() (scoped bool) {
+	scoped = w.markup != nil || w.markdown != nil || w.hasKeyPaths()
+	return
+}
+
+// hasKeyPaths returns true if any structured key paths are configured
+func (w *Worker) hasKeyPaths() (present bool) {
+	present = len(w.JSONPaths)+len(w.YAMLPaths)+len(w.TOMLPaths) > 0
+	return
+}
+
+// keyPaths returns the format and selectors to use for the file given. The
+// file extension selects the format when it has selectors, otherwise the only
+// format with selectors is used. The format is empty if the file has no
+// applicable selectors
+func (w *Worker) keyPaths(file string) (format string, selectors []KeyPath) {
+	lookup := map[string][]KeyPath{
+		StructuredJSON: w.JSONPaths,
+		StructuredYAML: w.YAMLPaths,
+		StructuredTOML: w.TOMLPaths,
+	}
+	ext := strings.ToLower(filepath.Ext(file))
+	switch ext {
+	case ".json":
+		format = StructuredJSON
+	case ".yaml", ".yml":
+		format = StructuredYAML
+	case ".toml":
+		format = StructuredTOML
+	}
+	if selectors = lookup[format]; len(selectors) > 0 {
+		return
+	}
+	format = ""
+	for _, name := range []string{StructuredJSON, StructuredYAML, StructuredTOML} {
+		if len(lookup[name]) > 0 {
+			if format != "" {
+				// ambiguous
+				format, selectors = "", nil
+				return
+			}
+			format, selectors = name, lookup[name]
+		}
+	}
+	return
+}
+
+// findScopedEdits is findEdits limited to the scalar values at the key paths
+// or to the markup or markdown configured, when any are configured
+func (w *Worker) findScopedEdits(file, content string) (e *Edits, err error) {
+	if !w.isScoped() {
+		e = w.findEdits(file, content)
+		return
+	} else if w.markup != nil {
+		e = NewEdits(file, content, w.findMarkupEdits(content))
+		return
+	} else if w.markdown != nil {
+		e = NewEdits(file, content, w.findMarkdownEdits(content))
+		return
+	}
+
+	var edits []Edit
+	format, selectors := w.keyPaths(file)
+	if format != "" {
+		var spans []cValueSpan
+		switch format {
+		case StructuredJSON:
+			spans, err = jsonValueSpans(content)
+		case StructuredYAML:
+			spans, err = yamlValueSpans(content)
+		case StructuredTOML:
+			spans, err = tomlValueSpans(content)
+		}
+		if err != nil {
+			err = fmt.Errorf("--%s-path %w", format, err)
+			return
+		}
+		for _, span := range spans {
+			for _, kp := range selectors {
+				if !kp.Match(span.path) {
+					continue
+				}
+				decoded, offsets := span.decode(content[span.start:span.end])
+				for _, edit := range w.findContentEdits(decoded) {
+					start, end := offsets[edit.Start], offsets[edit.End]
+					if start < 0 || end < 0 {
+						// partial escape sequence
+						continue
+					}
+					text, ok := span.escape(edit.Text)
+					if !ok {
+						line := strings.Count(content[:span.start], "\n") + 1
+						err = fmt.Errorf("--%s-path line %d: %q cannot be written within the quoted value", format, line, edit.Text)
+						return
+					}
+					edits = append(edits, Edit{
+						Start: span.start + start,
+						End:   span.start + end,
+						Text:  text,
+					})
+				}
+				break
+			}
+		}
+	}
+	e = NewEdits(file, content, edits)
+	return
+}
+
+// appendPath returns a new path with the key appended
+func appendPath(path []string, key string) (next []string) {
+	next = append(append(make([]string, 0, len(path)+1), path...), key)
+	return
+}
+
+// jsonValueSpans returns the spans of all the scalar values in the JSON
+// content given
+func jsonValueSpans(content string) (spans []cValueSpan, err error) {
+	s := &cStructuredScanner{src: content}
+	s.space(false)
+	if err = s.jsonValue(nil); err == nil {
+		if s.space(false); s.pos < len(s.src) {
+			err = s.errorf("unexpected content after the value")
+		}
+	}
+	spans = s.spans
+	return
+}
+
+// cStructuredScanner is a minimal scanner for locating the scalar values of
+// JSON and TOML content without altering any of it
+type cStructuredScanner struct {
+	src   string
+	pos   int
+	spans []cValueSpan
+}
+
+func (s *cStructuredScanner) errorf(format string, argv ...interface{}) (err error) {
+	line := strings.Count(s.src[:s.pos], "\n") + 1
+	err = fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, argv...))
+	return
+}
+
+// space skips whitespace, and comments when hashed is true, newlines are
+// always skipped
+func (s *cStructuredScanner) space(hashed bool) {
+	for s.pos < len(s.src) {
+		switch c := s.src[s.pos]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			s.pos += 1
+		case hashed && c == '#':
+			for s.pos < len(s.src) && s.src[s.pos] != '\n' {
+				s.pos += 1
+			}
+		default:
+			return
+		}
+	}
+}
+
+// quoted scans the string starting at the current quote character, returning
+// the span of the string content, escapes are only recognized when escapes is
+// true. When the quote is repeated three times, as with TOML multi-line
+// strings, the string ends with the same three quotes
+func (s *cStructuredScanner) quoted(escapes bool) (start, end int, err error) {
+	quote := s.src[s.pos : s.pos+1]
+	if strings.HasPrefix(s.src[s.pos:], quote+quote+quote) {
+		quote = quote + quote + quote
+	}
+	s.pos += len(quote)
+	start = s.pos
+	for s.pos < len(s.src) {
+		if escapes && s.src[s.pos] == '\\' {
+			s.pos += 2
+			continue
+		} else if strings.HasPrefix(s.src[s.pos:], quote) {
+			end = s.pos
+			s.pos += len(quote)
+			// TOML allows up to two quotes before the closing quotes
+			for len(quote) == 3 && s.pos < len(s.src) && s.src[s.pos] == quote[0] {
+				end, s.pos = end+1, s.pos+1
+			}
+			return
+		} else if len(quote) == 1 && s.src[s.pos] == '\n' {
+			break
+		}
+		s.pos += 1
+	}
+	err = s.errorf("unterminated string")
+	return
+}
+
+// bare scans a literal value up to any of the delimiters given
+func (s *cStructuredScanner) bare(delimiters string) (start, end int, err error) {
+	start = s.pos
+	for s.pos < len(s.src) && !strings.ContainsRune(delimiters, rune(s.src[s.pos])) {
+		s.pos += 1
+	}
+	end = s.pos
+	for end > start && (s.src[end-1] == ' ' || s.src[end-1] == '\t') {
+		end -= 1
+	}
+	if end == start {
+		err = s.errorf("missing value")
+	}
+	return
+}
+
+func (s *cStructuredScanner) expect(c byte) (err error) {
+	if s.pos >= len(s.src) {
+		err = s.errorf("expected %q, found end of content", c)
+	} else if s.src[s.pos] != c {
+		err = s.errorf("expected %q, found %q", c, s.src[s.pos])
+	} else {
+		s.pos += 1
+	}
+	return
+}
+
+func (s *cStructuredScanner) jsonValue(path []string) (err error) {
+	if s.pos >= len(s.src) {
+		err = s.errorf("unexpected end of content")
+		return
+	}
+	switch s.src[s.pos] {
+	case '{':
+		s.pos += 1
+		if s.space(false); s.pos < len(s.src) && s.src[s.pos] == '}' {
+			s.pos += 1
+			return
+		}
+		for {
+			s.space(false)
+			if s.pos >= len(s.src) || s.src[s.pos] != '"' {
+				err = s.errorf("expected object key")
+				return
+			}
+			keyStart := s.pos
+			if _, _, err = s.quoted(true); err != nil {
+				return
+			}
+			var key string
+			if err = json.Unmarshal([]byte(s.src[keyStart:s.pos]), &key); err != nil {
+				err = s.errorf("invalid object key: %v", err)
+				return
+			}
+			s.space(false)
+			if err = s.expect(':'); err != nil {
+				return
+			}
+			s.space(false)
+			if err = s.jsonValue(appendPath(path, key)); err != nil {
+				return
+			}
+			s.space(false)
+			if s.pos < len(s.src) && s.src[s.pos] == ',' {
+				s.pos += 1
+				continue
+			}
+			err = s.expect('}')
+			return
+		}
+	case '[':
+		s.pos += 1
+		if s.space(false); s.pos < len(s.src) && s.src[s.pos] == ']' {
+			s.pos += 1
+			return
+		}
+		for idx := 0; ; idx++ {
+			s.space(false)
+			if err = s.jsonValue(appendPath(path, strconv.Itoa(idx))); err != nil {
+				return
+			}
+			s.space(false)
+			if s.pos < len(s.src) && s.src[s.pos] == ',' {
+				s.pos += 1
+				continue
+			}
+			err = s.expect(']')
+			return
+		}
+	case '"':
+		var start, end int
+		if start, end, err = s.quoted(true); err == nil {
+			s.spans = append(s.spans, cValueSpan{path: path, start: start, end: end, quoting: quotingBackslash})
+		}
+	default:
+		var start, end int
+		if start, end, err = s.bare(",}] \t\r\n"); err == nil {
+			if !json.Valid([]byte(s.src[start:end])) {
+				err = s.errorf("invalid value %q", s.src[start:end])
+				return
+			}
+			s.spans = append(s.spans, cValueSpan{path: path, start: start, end: end})
+		}
+	}
+	return
+}
+
+// tomlValueSpans returns the spans of all the scalar values in the TOML
+// content given, the elements of arrays of tables are indexed in the order
+// they appear
+func tomlValueSpans(content string) (spans []cValueSpan, err error) {
+	s := &cStructuredScanner{src: content}
+	var table []string
+	arrays := make(map[string]int)
+	for {
+		if s.space(true); s.pos >= len(s.src) {
+			break
+		}
+		if s.src[s.pos] == '[' {
+			array := strings.HasPrefix(s.src[s.pos:], "[[")
+			if array {
+				s.pos += 2
+			} else {
+				s.pos += 1
+			}
+			if table, err = s.tomlKey(nil); err != nil {
+				return
+			}
+			if array {
+				name := strings.Join(table, "\x00")
+				table = append(table, strconv.Itoa(arrays[name]))
+				arrays[name] += 1
+				if err = s.expect(']'); err != nil {
+					return
+				}
+			}
+			if err = s.expect(']'); err != nil {
+				return
+			}
+		} else if err = s.tomlKeyValue(table); err != nil {
+			return
+		}
+		// nothing else is allowed on the line, except comments
+		for s.pos < len(s.src) && (s.src[s.pos] == ' ' || s.src[s.pos] == '\t' || s.src[s.pos] == '\r') {
+			s.pos += 1
+		}
+		if s.pos < len(s.src) && s.src[s.pos] != '\n' && s.src[s.pos] != '#' {
+			err = s.errorf("unexpected %q after value", s.src[s.pos])
+			return
+		}
+	}
+	spans = s.spans
+	return
+}
+
+// tomlKey scans a possibly dotted and quoted key, appending the parts to the
+// path given
+func (s *cStructuredScanner) tomlKey(path []string) (key []string, err error) {
+	key = append(key, path...)
+	for {
+		for s.pos < len(s.src) && (s.src[s.pos] == ' ' || s.src[s.pos] == '\t') {
+			s.pos += 1
+		}
+		if s.pos >= len(s.src) {
+			err = s.errorf("unexpected end of content")
+			return
+		}
+		var part string
+		switch s.src[s.pos] {
+		case '"':
+			start := s.pos
+			if _, _, err = s.quoted(true); err != nil {
+				return
+			} else if err = json.Unmarshal([]byte(s.src[start:s.pos]), &part); err != nil {
+				err = s.errorf("invalid key: %v", err)
+				return
+			}
+		case '\'':
+			var start, end int
+			if start, end, err = s.quoted(false); err != nil {
+				return
+			}
+			part = s.src[start:end]
+		default:
+			var start, end int
+			if start, end, err = s.bare(".=] \t\r\n#"); err != nil {
+				return
+			}
+			part = s.src[start:end]
+		}
+		key = append(key, part)
+		for s.pos < len(s.src) && (s.src[s.pos] == ' ' || s.src[s.pos] == '\t') {
+			s.pos += 1
+		}
+		if s.pos < len(s.src) && s.src[s.pos] == '.' {
+			s.pos += 1
+			continue
+		}
+		return
+	}
+}
+
+func (s *cStructuredScanner) tomlKeyValue(table []string) (err error) {
+	var key []string
+	if key, err = s.tomlKey(table); err != nil {
+		return
+	} else if err = s.expect('='); err != nil {
+		return
+	}
+	for s.pos < len(s.src) && (s.src[s.pos] == ' ' || s.src[s.pos] == '\t') {
+		s.pos += 1
+	}
+	err = s.tomlValue(key)
+	return
+}
+
+func (s *cStructuredScanner) tomlValue(path []string) (err error) {
+	if s.pos >= len(s.src) {
+		err = s.errorf("unexpected end of content")
+		return
+	}
+	var start, end int
+	quoting := quotingNone
+	switch s.src[s.pos] {
+	case '[':
+		s.pos += 1
+		for idx := 0; ; idx++ {
+			if s.space(true); s.pos < len(s.src) && s.src[s.pos] == ']' {
+				s.pos += 1
+				return
+			}
+			if err = s.tomlValue(appendPath(path, strconv.Itoa(idx))); err != nil {
+				return
+			}
+			if s.space(true); s.pos < len(s.src) && s.src[s.pos] == ',' {
+				s.pos += 1
+				continue
+			}
+			err = s.expect(']')
+			return
+		}
+	case '{':
+		s.pos += 1
+		for {
+			for s.pos < len(s.src) && (s.src[s.pos] == ' ' || s.src[s.pos] == '\t') {
+				s.pos += 1
+			}
+			if s.pos < len(s.src) && s.src[s.pos] == '}' {
+				s.pos += 1
+				return
+			}
+			if err = s.tomlKeyValue(path); err != nil {
+				return
+			}
+			for s.pos < len(s.src) && (s.src[s.pos] == ' ' || s.src[s.pos] == '\t') {
+				s.pos += 1
+			}
+			if s.pos < len(s.src) && s.src[s.pos] == ',' {
+				s.pos += 1
+				continue
+			}
+			err = s.expect('}')
+			return
+		}
+	case '"':
+		if quoting = quotingBackslash; strings.HasPrefix(s.src[s.pos:], `"""`) {
+			quoting = quotingMultiLine
+		}
+		start, end, err = s.quoted(true)
+	case '\'':
+		if quoting = quotingLiteral; strings.HasPrefix(s.src[s.pos:], "'''") {
+			quoting = quotingMultiLiteral
+		}
+		start, end, err = s.quoted(false)
+	default:
+		start, end, err = s.bare(",]}#\r\n")
+	}
+	if err == nil {
+		s.spans = append(s.spans, cValueSpan{path: path, start: start, end: end, quoting: quoting})
+	}
+	return
+}
+
+// yamlValueSpans returns the spans of all the scalar values in the YAML
+// content given, including all documents of multi-document streams
+func yamlValueSpans(content string) (spans []cValueSpan, err error) {
+	lines := newLineIndex(content)
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	for {
+		var node yaml.Node
+		if err = decoder.Decode(&node); errors.Is(err, io.EOF) {
+			err = nil
+			return
+		} else if err != nil {
+			return
+		}
+		spans = appendYAMLSpans(spans, lines, &node, nil)
+	}
+}
+
+func appendYAMLSpans(spans []cValueSpan, lines *cLineIndex, node *yaml.Node, path []string) []cValueSpan {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			spans = appendYAMLSpans(spans, lines, child, path)
+		}
+	case yaml.MappingNode:
+		for idx := 0; idx+1 < len(node.Content); idx += 2 {
+			spans = appendYAMLSpans(spans, lines, node.Content[idx+1], appendPath(path, node.Content[idx].Value))
+		}
+	case yaml.SequenceNode:
+		for idx, child := range node.Content {
+			spans = appendYAMLSpans(spans, lines, child, appendPath(path, strconv.Itoa(idx)))
+		}
+	case yaml.ScalarNode:
+		if start, end, quoting, ok := yamlScalarSpan(lines, node); ok {
+			spans = append(spans, cValueSpan{path: path, start: start, end: end, quoting: quoting})
+		}
+	}
+	return spans
+}
+
+// yamlScalarSpan locates the scalar node within the source, ok is false for
+// scalars which cannot be located exactly, such as multi-line plain scalars
+func yamlScalarSpan(lines *cLineIndex, node *yaml.Node) (start, end int, quoting cQuoting, ok bool) {
+	if node.Line < 1 || node.Line > lines.count() {
+		return
+	}
+	src := lines.source
+	// the column is counted in characters
+	offset := lines.start(node.Line - 1)
+	for col := 1; col < node.Column && offset < len(src); col++ {
+		_, size := utf8.DecodeRuneInString(src[offset:])
+		offset += size
+	}
+	if offset >= len(src) {
+		return
+	}
+
+	switch {
+	case node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0:
+		s := &cStructuredScanner{src: src, pos: offset}
+		if quote := src[offset]; quote == '"' || quote == '\'' {
+			double := quote == '"'
+			if quoting = quotingDoubled; double {
+				quoting = quotingBackslash
+			}
+			s.pos += 1
+			start = s.pos
+			for s.pos < len(src) {
+				if double && src[s.pos] == '\\' {
+					s.pos += 2
+				} else if !double && strings.HasPrefix(src[s.pos:], "''") {
+					s.pos += 2
+				} else if src[s.pos] == quote {
+					end, ok = s.pos, true
+					return
+				} else {
+					s.pos += 1
+				}
+			}
+		}
+	case node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		// the block is the lines following the header line, indented at
+		// least as much as the first of them, starting after the indentation
+		indent := -1
+		for line := node.Line; line < lines.count(); line++ {
+			text := strings.TrimRight(lines.line(line), "\r\n")
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			n := len(text) - len(strings.TrimLeft(text, " "))
+			if indent < 0 {
+				indent = n
+				start = lines.start(line) + n
+			} else if n < indent {
+				break
+			}
+			end = lines.start(line) + len(text)
+		}
+		ok = indent > 0
+	default:
+		if ok = strings.HasPrefix(src[offset:], node.Value) && !strings.Contains(node.Value, "\n"); ok {
+			start, end = offset, offset+len(node.Value)
+		}
+	}
+	return
+}
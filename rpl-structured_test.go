@@ -0,0 +1,211 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"encoding/json"
+	"io/fs"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStructured(t *testing.T) {
+	t.Parallel()
+
+	Convey("Key Paths", t, func() {
+		kp, err := ParseKeyPath("services.*.ports[0]")
+		So(err, ShouldBeNil)
+		So(kp, ShouldEqual, KeyPath{"services", "*", "ports", "0"})
+		So(kp.Match([]string{"services", "web", "ports", "0"}), ShouldBeTrue)
+		So(kp.Match([]string{"services", "web", "ports", "1"}), ShouldBeFalse)
+		So(kp.Match([]string{"services", "web", "ports"}), ShouldBeFalse)
+
+		kp, err = ParseKeyPath("**.image")
+		So(err, ShouldBeNil)
+		So(kp.Match([]string{"image"}), ShouldBeTrue)
+		So(kp.Match([]string{"a", "b", "image"}), ShouldBeTrue)
+		So(kp.Match([]string{"a", "image", "tag"}), ShouldBeFalse)
+
+		_, err = ParseKeyPath("a..b")
+		So(err, ShouldNotBeNil)
+		_, err = ParseKeyPath("a.[")
+		So(err, ShouldNotBeNil)
+	})
+
+	values := func(content string, spans []cValueSpan) (found map[string]string) {
+		found = make(map[string]string)
+		for _, span := range spans {
+			found[KeyPath(span.path).String()] = content[span.start:span.end]
+		}
+		return
+	}
+
+	Convey("Value Spans", t, func() {
+		content := `{"name": "old", "list": [1, "old\"s", {"k.x": true}], "n": null}`
+		spans, err := jsonValueSpans(content)
+		So(err, ShouldBeNil)
+		So(values(content, spans), ShouldEqual, map[string]string{
+			"name":       "old",
+			"list.0":     "1",
+			"list.1":     `old\"s`,
+			"list.2.k.x": "true",
+			"n":          "null",
+		})
+		_, err = jsonValueSpans(`{"name": "old",}`)
+		So(err, ShouldNotBeNil)
+
+		content = "# comment\nname: old # trailing\nlist:\n  - 'it''s old'\n  - \"old\"\ntext: |\n  old one\n  old two\n---\nnext: plain old\n"
+		spans, err = yamlValueSpans(content)
+		So(err, ShouldBeNil)
+		So(values(content, spans), ShouldEqual, map[string]string{
+			"name":   "old",
+			"list.0": "it''s old",
+			"list.1": "old",
+			"text":   "old one\n  old two",
+			"next":   "plain old",
+		})
+		_, err = yamlValueSpans("a: [\n")
+		So(err, ShouldNotBeNil)
+
+		content = "title = \"old\" # comment\n[owner]\nname = 'old'\n\"dotted.key\".x = 1\n[[servers]]\nhost = \"\"\"\nold\"\"\"\n[[servers]]\nhost = \"old\"\nports = [ 80, 443 ]\ninline = { a = \"old\" }\n"
+		spans, err = tomlValueSpans(content)
+		So(err, ShouldBeNil)
+		So(values(content, spans), ShouldEqual, map[string]string{
+			"title":              "old",
+			"owner.name":         "old",
+			"owner.dotted.key.x": "1",
+			"servers.0.host":     "\nold",
+			"servers.1.host":     "old",
+			"servers.1.ports.0":  "80",
+			"servers.1.ports.1":  "443",
+			"servers.1.inline.a": "old",
+		})
+		_, err = tomlValueSpans("title = \"old\" extra\n")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Escaped Values", t, func() {
+		replace := func(file, content, search, replacement string, options ...Option) (output string, err error) {
+			m := NewMemFileSystem(map[string]string{file: content})
+			var w *Worker
+			if w, err = New(append([]Option{
+				WithFileSystem(m),
+				WithQuiet(true),
+				WithSearch(search, replacement),
+				WithPaths(file),
+			}, options...)...); err != nil {
+				return
+			} else if err = w.InitTargets(nil); err != nil {
+				return
+			}
+			var scanned error
+			if err = w.FindMatching(func(file string, matched bool, ee error) {
+				scanned = ee
+			}); err != nil {
+				return
+			} else if err = scanned; err != nil {
+				return
+			}
+			for iter := w.StartIterating(); iter.Valid(); iter.Next() {
+				if _, _, _, err = iter.ApplyAll(); err != nil {
+					return
+				}
+			}
+			data, _ := fs.ReadFile(m, file[1:])
+			output = string(data)
+			return
+		}
+
+		// matching is against the decoded value
+		output, err := replace("/a.json", `{"k": "say \"hi\" to C:\\dir \u0041"}`, `hi" to C:\dir A`, "ok", WithJSONPaths("k"))
+		So(err, ShouldBeNil)
+		So(output, ShouldEqual, `{"k": "say \"ok"}`)
+		// replacements are escaped for the quoting
+		output, err = replace("/a.json", `{"k": "path", "n": 1}`, "path", `C:\new "dir"`+"\n", WithJSONPaths("k"))
+		So(err, ShouldBeNil)
+		So(output, ShouldEqual, `{"k": "C:\\new \"dir\"\n", "n": 1}`)
+		So(json.Valid([]byte(output)), ShouldBeTrue)
+		// partial escape sequences are not replaced
+		output, err = replace("/a.json", `{"k": "a\nb"}`, "n", "x", WithJSONPaths("k"))
+		So(err, ShouldBeNil)
+		So(output, ShouldEqual, `{"k": "a\nb"}`)
+		output, err = replace("/a.json", `{"k": "\ud83d\ude00!"}`, "\U0001F600", `"`, WithJSONPaths("k"))
+		So(err, ShouldBeNil)
+		So(output, ShouldEqual, `{"k": "\"!"}`)
+
+		content := "a = \"x\\\\y\"\nb = 'x\\y'\nc = \"\"\"\nx\"y\"\"\"\nd = '''x'''\n"
+		output, err = replace("/a.toml", content, `x\y`, `"q"\`, WithTOMLPaths("a"))
+		So(err, ShouldBeNil)
+		So(output, ShouldEqual, "a = \"\\\"q\\\"\\\\\"\nb = 'x\\y'\nc = \"\"\"\nx\"y\"\"\"\nd = '''x'''\n")
+		output, err = replace("/a.toml", content, `x\y`, `z\z`, WithTOMLPaths("b"))
+		So(err, ShouldBeNil)
+		So(output, ShouldEqual, "a = \"x\\\\y\"\nb = 'z\\z'\nc = \"\"\"\nx\"y\"\"\"\nd = '''x'''\n")
+		output, err = replace("/a.toml", content, `x"y`, "1\n\"2\"", WithTOMLPaths("c"))
+		So(err, ShouldBeNil)
+		So(output, ShouldEqual, "a = \"x\\\\y\"\nb = 'x\\y'\nc = \"\"\"\n1\n\\\"2\\\"\"\"\"\nd = '''x'''\n")
+		output, err = replace("/a.toml", content, "x", "it's", WithTOMLPaths("d"))
+		So(err, ShouldBeNil)
+		So(output, ShouldEqual, "a = \"x\\\\y\"\nb = 'x\\y'\nc = \"\"\"\nx\"y\"\"\"\nd = '''it's'''\n")
+		// literal strings cannot have quotes written within them
+		_, err = replace("/a.toml", content, `x\y`, "it's", WithTOMLPaths("b"))
+		So(err, ShouldNotBeNil)
+
+		output, err = replace("/a.yml", "a: 'it''s'\nb: \"\\\"x\\\"\"\n", "it's", `"it's"`, WithYAMLPaths("a"))
+		So(err, ShouldBeNil)
+		So(output, ShouldEqual, "a: '\"it''s\"'\nb: \"\\\"x\\\"\"\n")
+		output, err = replace("/a.yml", "a: 'it''s'\nb: \"\\\"x\\\"\"\n", `"x"`, `\`, WithYAMLPaths("b"))
+		So(err, ShouldBeNil)
+		So(output, ShouldEqual, "a: 'it''s'\nb: \"\\\\\"\n")
+	})
+
+	Convey("Worker Key Paths", t, func() {
+		compose := "services:\n  web:\n    image: nginx:1.25 # nginx:1.25\n    command: nginx:1.25\n  db:\n    image: \"nginx:1.25\"\n"
+		m := NewMemFileSystem(map[string]string{
+			"/src/compose.yml":  compose,
+			"/src/package.json": "{\n  \"version\": \"1.25\",\n  \"dependencies\": {\"a\": \"1.25\"}\n}\n",
+			"/src/notes.txt":    "nginx:1.25\n",
+		})
+
+		w, err := New(
+			WithFileSystem(m),
+			WithQuiet(true),
+			WithSearch("1.25", "1.27"),
+			WithPaths("/src"),
+			WithRecurse(true),
+			WithYAMLPaths("services.*.image"),
+			WithJSONPaths("version"),
+		)
+		So(err, ShouldBeNil)
+		So(w.InitTargets(nil), ShouldBeNil)
+		So(w.FindMatching(nil), ShouldBeNil)
+		So(w.Matched, ShouldEqual, []string{"/src/compose.yml", "/src/package.json"})
+
+		for iter := w.StartIterating(); iter.Valid(); iter.Next() {
+			count, _, _, ee := iter.ApplyAll()
+			So(ee, ShouldBeNil)
+			So(count, ShouldBeGreaterThan, 0)
+		}
+		data, _ := fs.ReadFile(m, "src/compose.yml")
+		So(string(data), ShouldEqual, "services:\n  web:\n    image: nginx:1.27 # nginx:1.25\n    command: nginx:1.25\n  db:\n    image: \"nginx:1.27\"\n")
+		data, _ = fs.ReadFile(m, "src/package.json")
+		So(string(data), ShouldEqual, "{\n  \"version\": \"1.27\",\n  \"dependencies\": {\"a\": \"1.25\"}\n}\n")
+		data, _ = fs.ReadFile(m, "src/notes.txt")
+		So(string(data), ShouldEqual, "nginx:1.25\n")
+
+		_, err = New(WithYAMLPaths("a..b"))
+		So(err, ShouldNotBeNil)
+	})
+}
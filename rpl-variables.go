@@ -0,0 +1,222 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// VariableFile is the path of the file being replaced
+	VariableFile = "FILE"
+	// VariableBasename is the last element of the file path
+	VariableBasename = "BASENAME"
+	// VariableDir is the file path less the last element
+	VariableDir = "DIR"
+	// VariableLine is the line number the replaced text starts on
+	VariableLine = "LINE"
+	// VariableMatchIndex counts the replacements within each file, from 1
+	VariableMatchIndex = "MATCH_INDEX"
+	// VariableGlobalIndex counts the replacements across all files, from 1
+	VariableGlobalIndex = "GLOBAL_INDEX"
+)
+
+// ReplaceVariables is the list of variables always available to replacement
+// text, as ${NAME}
+var ReplaceVariables = []string{
+	VariableFile,
+	VariableBasename,
+	VariableDir,
+	VariableLine,
+	VariableMatchIndex,
+	VariableGlobalIndex,
+}
+
+var (
+	gVariableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// gVariableRune is the first of the private use runes standing in for the
+// variables within the replacement until the edits are expanded
+const gVariableRune = rune(0x100000)
+
+// cVariables is the replacement text prepared for expanding variables
+type cVariables struct {
+	// template is the replacement with placeholder runes for variables
+	template string
+	// names are the variable names, indexed by placeholder
+	names []string
+	// last is the file last expanded and base is its GLOBAL_INDEX offset,
+	// which is kept when the edits of the same file are computed again
+	last  string
+	base  int
+	total int
+}
+
+// parseDefines parses the KEY=VALUE definitions given into the defines map
+func parseDefines(defines map[string]string, definitions ...string) (err error) {
+	for _, definition := range definitions {
+		key, value, ok := strings.Cut(definition, "=")
+		if !ok {
+			err = fmt.Errorf("expected KEY=VALUE, found %q", definition)
+			return
+		} else if !gVariableName.MatchString(key) {
+			err = fmt.Errorf("invalid name %q, expected letters, digits and underscores", key)
+			return
+		}
+		for _, name := range ReplaceVariables {
+			if key == name {
+				err = fmt.Errorf("%q is a builtin variable", key)
+				return
+			}
+		}
+		defines[key] = value
+	}
+	return
+}
+
+// isVariable returns true if the name is a builtin or defined variable
+func (w *Worker) isVariable(name string) (ok bool) {
+	if _, ok = w.defines[name]; !ok {
+		for _, known := range ReplaceVariables {
+			if ok = name == known; ok {
+				break
+			}
+		}
+	}
+	return
+}
+
+// variableAt returns the name and size of the known ${NAME} variable at the
+// start of the text given
+func (w *Worker) variableAt(text string) (name string, size int, ok bool) {
+	if strings.HasPrefix(text, "${") {
+		if end := strings.IndexByte(text, '}'); end > 0 {
+			if name = text[2:end]; w.isVariable(name) {
+				size, ok = end+1, true
+			}
+		}
+	}
+	return
+}
+
+// prepareVariables replaces any ${NAME} variables within the replacement text
+// with placeholders, unknown names are left as-is and are regex group
+// references when using --regex. A variable is written literally when given
+// as $${NAME}, any other "$$" is left as-is (and is a literal "$" when using
+// --regex)
+func (w *Worker) prepareVariables() {
+	w.variables = nil
+	var buf strings.Builder
+	var names []string
+	var escaped bool
+	for rest := w.Replace; rest != ""; {
+		idx := strings.IndexByte(rest, '$')
+		if idx < 0 {
+			buf.WriteString(rest)
+			break
+		}
+		buf.WriteString(rest[:idx])
+		rest = rest[idx:]
+		if strings.HasPrefix(rest, "$$") {
+			if _, size, ok := w.variableAt(rest[1:]); ok && !w.Regex {
+				buf.WriteString(rest[1 : 1+size])
+				rest = rest[1+size:]
+				escaped = true
+				continue
+			}
+			buf.WriteString("$$")
+			rest = rest[2:]
+			continue
+		}
+		if name, size, ok := w.variableAt(rest); ok {
+			buf.WriteRune(gVariableRune + rune(len(names)))
+			names = append(names, name)
+			rest = rest[size:]
+			continue
+		}
+		buf.WriteByte('$')
+		rest = rest[1:]
+	}
+	if len(names) > 0 || escaped {
+		w.variables = &cVariables{
+			template: buf.String(),
+			names:    names,
+		}
+	}
+}
+
+// replacement returns the replacement text used when finding edits, with any
+// variables as placeholders for expandVariables
+func (w *Worker) replacement() (text string) {
+	if w.variables != nil {
+		text = w.variables.template
+	} else {
+		text = w.Replace
+	}
+	return
+}
+
+// expandVariables substitutes the variable placeholders within the edits
+// given, files are counted towards GLOBAL_INDEX in the order expanded
+func (w *Worker) expandVariables(e *Edits) {
+	v := w.variables
+	if v == nil || len(v.names) == 0 || e == nil || len(e.edits) == 0 {
+		return
+	}
+
+	w.variablesLock.Lock()
+	if v.last != e.path {
+		v.last, v.base = e.path, v.total
+		v.total += len(e.edits)
+	}
+	base := v.base
+	w.variablesLock.Unlock()
+
+	line, last := 1, 0
+	for idx := range e.edits {
+		edit := &e.edits[idx]
+		line += strings.Count(e.source[last:edit.Start], "\n")
+		last = edit.Start
+		var buf strings.Builder
+		for _, r := range edit.Text {
+			if n := int(r - gVariableRune); n >= 0 && n < len(v.names) {
+				switch name := v.names[n]; name {
+				case VariableFile:
+					buf.WriteString(e.path)
+				case VariableBasename:
+					buf.WriteString(filepath.Base(e.path))
+				case VariableDir:
+					buf.WriteString(filepath.Dir(e.path))
+				case VariableLine:
+					buf.WriteString(strconv.Itoa(line))
+				case VariableMatchIndex:
+					buf.WriteString(strconv.Itoa(idx + 1))
+				case VariableGlobalIndex:
+					buf.WriteString(strconv.Itoa(base + idx + 1))
+				default:
+					buf.WriteString(w.defines[name])
+				}
+				continue
+			}
+			buf.WriteRune(r)
+		}
+		edit.Text = buf.String()
+	}
+}
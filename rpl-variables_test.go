@@ -0,0 +1,151 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replace
+
+import (
+	"bytes"
+	"io/fs"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestVariables(t *testing.T) {
+	t.Parallel()
+
+	Convey("Defines", t, func() {
+		w, err := New(WithDefines("A=1", "B_2=x=y", "EMPTY="))
+		So(err, ShouldBeNil)
+		So(w.defines, ShouldEqual, map[string]string{"A": "1", "B_2": "x=y", "EMPTY": ""})
+		So(w.Defines, ShouldEqual, []string{"A=1", "B_2=x=y", "EMPTY="})
+
+		for _, bad := range []string{"NOVALUE", "1A=x", "A-B=x", "=x", "FILE=x"} {
+			_, err = New(WithDefines(bad))
+			So(err, ShouldNotBeNil)
+		}
+	})
+
+	Convey("Templates", t, func() {
+		w, err := New(WithQuiet(true), WithSearch("a", "${FILE}-${UNKNOWN}-${1}-$"))
+		So(err, ShouldBeNil)
+		So(w.variables, ShouldNotBeNil)
+		So(w.variables.names, ShouldEqual, []string{VariableFile})
+		So(w.replacement(), ShouldEqual, string(gVariableRune)+"-${UNKNOWN}-${1}-$")
+
+		w, err = New(WithQuiet(true), WithSearch("a", "b"))
+		So(err, ShouldBeNil)
+		So(w.variables, ShouldBeNil)
+		So(w.replacement(), ShouldEqual, "b")
+	})
+
+	Convey("Filter", t, func() {
+		filter := func(content string, options ...Option) (output string) {
+			w, err := New(append([]Option{WithQuiet(true)}, options...)...)
+			So(err, ShouldBeNil)
+			var o bytes.Buffer
+			_, _, err = w.FilterContent(strings.NewReader(content), &o)
+			So(err, ShouldBeNil)
+			output = o.String()
+			return
+		}
+
+		So(filter("x\nx x\n\nx\n", WithSearch("x", "${LINE}.${MATCH_INDEX}")), ShouldEqual,
+			"1.1\n2.2 2.3\n\n4.4\n")
+		So(filter("id id", WithSearch("id", "${P}${GLOBAL_INDEX}"), WithDefines("P=item-")), ShouldEqual,
+			"item-1 item-2")
+		So(filter("a1 b2", WithRegex(true), WithSearch(`([a-z])(\d)`, "${2}${1}@${LINE}$${LINE}")), ShouldEqual,
+			"1a@1${LINE} 2b@1${LINE}")
+		// only known variables are escaped with "$$" without --regex
+		So(filter("x x", WithSearch("x", "${LINE}$${LINE}$${OTHER}$$$")), ShouldEqual,
+			"1${LINE}$${OTHER}$$$ 1${LINE}$${OTHER}$$$")
+		So(filter("x", WithSearch("x", "echo $$PID")), ShouldEqual,
+			"echo $$PID")
+		So(filter("a $$ b", WithSearch("$$", "$$")), ShouldEqual,
+			"a $$ b")
+		// defines take precedence over group names
+		So(filter("ab", WithRegex(true), WithSearch(`(?P<N>a)`, "${N}"), WithDefines("N=z")), ShouldEqual,
+			"zb")
+		// variable values are not case preserved
+		So(filter("Foo FOO", WithSearch("foo", "${P}"), WithIgnoreCase(true), WithDefines("P=bar")), ShouldEqual,
+			"bar bar")
+	})
+
+	Convey("Files", t, func() {
+		m := NewMemFileSystem(map[string]string{
+			"/src/a.txt":     "TODO\nTODO\n",
+			"/src/sub/b.txt": "none\nTODO\n",
+		})
+		w, err := New(
+			WithFileSystem(m),
+			WithQuiet(true),
+			WithSearch("TODO", "${DIR}/${BASENAME}:${LINE} ${MATCH_INDEX}/${GLOBAL_INDEX} ${FILE}"),
+			WithPaths("/src"),
+			WithRecurse(true),
+		)
+		So(err, ShouldBeNil)
+		So(w.InitTargets(nil), ShouldBeNil)
+		So(w.FindMatching(nil), ShouldBeNil)
+		So(w.Matched, ShouldEqual, []string{"/src/a.txt", "/src/sub/b.txt"})
+
+		iter := w.StartIterating()
+		// computing the edits again keeps the same global indexes
+		_, err = iter.Edits()
+		So(err, ShouldBeNil)
+		_, _, _, err = iter.ApplyAll()
+		So(err, ShouldBeNil)
+		iter.Next()
+		_, _, _, err = iter.ApplyAll()
+		So(err, ShouldBeNil)
+
+		data, _ := fs.ReadFile(m, "src/a.txt")
+		So(string(data), ShouldEqual, "/src/a.txt:1 1/1 /src/a.txt\n/src/a.txt:2 2/2 /src/a.txt\n")
+		data, _ = fs.ReadFile(m, "src/sub/b.txt")
+		So(string(data), ShouldEqual, "none\n/src/sub/b.txt:2 1/3 /src/sub/b.txt\n")
+	})
+
+	Convey("Batches", t, func() {
+		m := NewMemFileSystem(map[string]string{
+			"/src/a.txt": "hello\n",
+			"/src/b.txt": "hello\n",
+			"/src/c.txt": "hello\n",
+		})
+		w, err := New(
+			WithFileSystem(m),
+			WithQuiet(true),
+			WithSearch("hello", "${GLOBAL_INDEX}"),
+			WithPaths("/src"),
+			WithRecurse(true),
+			WithMaxFiles(1),
+		)
+		So(err, ShouldBeNil)
+		So(w.InitTargets(nil), ShouldBeNil)
+		So(w.FindMatching(nil), ShouldBeNil)
+
+		for {
+			_, _, _, err = w.StartIterating().ApplyAll()
+			So(err, ShouldBeNil)
+			if !w.HasMoreBatches() {
+				break
+			} else if _, err = w.NextBatch(); err != nil || len(w.Matched) == 0 {
+				break
+			}
+		}
+		So(err, ShouldBeNil)
+		// the global index continues across batches
+		data, _ := fs.ReadFile(m, "src/c.txt")
+		So(string(data), ShouldEqual, "3\n")
+	})
+}
@@ -15,16 +15,20 @@
 package replace
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
 
 	"github.com/go-corelibs/filewriter"
 	"github.com/go-corelibs/globs"
 	"github.com/go-corelibs/notify"
-	"github.com/go-corelibs/path"
 	rpl "github.com/go-corelibs/replace"
 	"github.com/go-corelibs/scanners"
 	"github.com/go-corelibs/slices"
@@ -39,12 +43,35 @@ type Worker struct {
 	All             bool
 	IgnoreCase      bool
 	PreserveCase    bool
+	LooseWhitespace bool
+	Fold            string
+	Normalize       string
+	Casing          []string
+	Defines         []string
 	BinAsText       bool
+	Decompress      bool
+	Archives        bool
+	JSONPaths       []KeyPath
+	YAMLPaths       []KeyPath
+	TOMLPaths       []KeyPath
+	Markup          string
+	Markdown        string
 	RelativePath    string
 	Backup          bool
 	BackupExtension string
+	BackupTemplate  string
+	BackupDir       string
+	RunID           string
 	NoLimits        bool
+	MaxFileSize     int64
+	MaxFiles        int
+	Timeout         time.Duration
+	FileTimeout     time.Duration
 	ShowDiff        bool
+	PatchFormat     string
+	ApplyPatchFile  string
+	Fuzz            int
+	Filter          bool
 	Interactive     bool
 	Pause           bool
 	Quiet           bool
@@ -52,10 +79,15 @@ type Worker struct {
 
 	Argv []string
 	Argc int
+	// argv are the command-line arguments given to WithArgv, parsed by New
+	// after all other options
+	argv []string
 
 	Search      string
+	SearchFile  string
 	Pattern     *regexp.Regexp
 	Replace     string
+	ReplaceFile string
 	Stdin       bool
 	Null        bool
 	AddFile     []string
@@ -71,12 +103,57 @@ type Worker struct {
 
 	Notifier notify.Notifier
 
+	// FileSystem is used for all file operations, the OS FileSystem is used
+	// when nil
+	FileSystem FileSystem
+
 	fwo filewriter.FileWriter
 	fwe filewriter.FileWriter
 
 	initLookup map[string]struct{}
+	targetFn   TargetFn
+
+	walker       *cTargetWalker
+	findFn       rpl.FindAllMatchingFn
+	batches      int
+	filesCount   int
+	matchedCount int
+	oversized    []string
+
+	started   time.Time
+	template  *BackupTemplate
+	backupDir *cBackupDir
+	markup    *cMarkup
+	casing    cCasing
+	markdown  *cMarkdown
+	defines   map[string]string
+	variables *cVariables
+
+	ctx        context.Context
+	cancel     context.CancelFunc
+	findCtx    context.Context
+	iter       *Iterator
+	unfinished []string
+	// finished are the files of the current batch which had their changes
+	// applied, counted across all batches by finishedCount
+	finished      []string
+	finishedCount int
+
+	archives       map[string]*cArchive
+	archiveBackups map[string]string
+	archivesLock   sync.Mutex
+
+	variablesLock sync.Mutex
+
+	observers     []cObserver
+	observerID    int
+	observersLock sync.RWMutex
 }
 
+// TargetFn is the callback used by Worker.InitTargets to report each target
+// path as it is added, or the error encountered
+type TargetFn func(target string, err error)
+
 func (w *Worker) getBackupExtension() (extension string) {
 	if extension = w.BackupExtension; extension != "" {
 		return
@@ -85,8 +162,93 @@ func (w *Worker) getBackupExtension() (extension string) {
 	return
 }
 
+// BackupRunPath returns the directory the current run's backups are copied
+// into when BackupDir is set, otherwise returns an empty string
+func (w *Worker) BackupRunPath() (dir string) {
+	if w.backupDir != nil {
+		dir = w.backupDir.run
+	}
+	return
+}
+
+// BackupManifestPath returns the manifest file listing the current run's
+// backups when BackupDir is set, otherwise returns an empty string
+func (w *Worker) BackupManifestPath() (file string) {
+	if w.backupDir != nil {
+		file = w.backupDir.manifest
+	}
+	return
+}
+
+// GetMaxFileSize returns the MaxFileSize if set, otherwise the
+// rpl.MaxFileSize default
+func (w *Worker) GetMaxFileSize() (size int64) {
+	if size = w.MaxFileSize; size > 0 {
+		return
+	}
+	size = rpl.MaxFileSize
+	return
+}
+
+// GetMaxFileSizeLabel returns the humanized GetMaxFileSize value
+func (w *Worker) GetMaxFileSizeLabel() (label string) {
+	label = humanize.Bytes(uint64(w.GetMaxFileSize()))
+	return
+}
+
+// GetMaxFiles returns the MaxFiles if set, otherwise the rpl.MaxFileCount
+// default
+func (w *Worker) GetMaxFiles() (count int) {
+	if count = w.MaxFiles; count > 0 {
+		return
+	}
+	count = rpl.MaxFileCount
+	return
+}
+
 func (w *Worker) Init() (err error) {
 
+	w.started = time.Now()
+	if w.Timeout > 0 && !w.Interactive {
+		// the user's review time is not limited when interactive
+		w.ctx, w.cancel = context.WithTimeout(context.Background(), w.Timeout)
+	} else {
+		w.ctx, w.cancel = context.WithCancel(context.Background())
+	}
+	if w.RunID == "" {
+		w.RunID = fmt.Sprintf("%s-%d", w.started.Format(BackupTimestampFormat), os.Getpid())
+	}
+
+	if w.BackupTemplate != "" {
+		if w.template, err = ParseBackupTemplate(w.BackupTemplate, w.RunID, w.started); err != nil {
+			err = fmt.Errorf("--backup-template %w", err)
+			return
+		}
+	}
+
+	if w.BackupDir != "" {
+		if w.template != nil {
+			err = fmt.Errorf("--backup-dir cannot be used with --backup-template")
+			return
+		} else if w.backupDir, err = newBackupDir(w, w.BackupDir, w.RunID); err != nil {
+			err = fmt.Errorf("--backup-dir %w", err)
+			return
+		}
+	}
+
+	if err = patchFormatValid(w.PatchFormat); err != nil {
+		err = fmt.Errorf("--patch-format %w", err)
+		return
+	} else if w.ApplyPatchFile != "" && w.Interactive {
+		err = fmt.Errorf("--apply-patch cannot be used with --interactive")
+		return
+	}
+
+	if err = w.readSearchFiles(); err != nil {
+		return
+	}
+	w.prepareVariables()
+
 	if w.Regex {
 		if w.Pattern, err = rpl.MakeRegexp(w.Search, w.MultiLine, w.DotMatchNl, w.IgnoreCase); err != nil {
 			err = fmt.Errorf("error compiling %q: %w", w.Search, err)
@@ -102,13 +264,18 @@ func (w *Worker) Init() (err error) {
 		return
 	}
 
-	if !w.All {
-		more, _ := globs.Parse("*" + w.getBackupExtension())
-		w.Exclude = append(w.Exclude, more...)
+	if !w.All && w.backupDir == nil {
+		if w.template != nil {
+			more, _ := globs.Parse(w.template.Globs()...)
+			w.Exclude = append(w.Exclude, more...)
+		} else {
+			more, _ := globs.Parse("*" + w.getBackupExtension())
+			w.Exclude = append(w.Exclude, more...)
+		}
 	}
 
 	if err = w.setupNotifier(); err == nil && w.NoLimits && !w.Quiet {
-		w.Notifier.Error("%s\n", gNoLimitsWarning)
+		w.Notifier.Error("%s\n", noLimitsWarning(w.GetMaxFileSizeLabel()))
 	}
 
 	return
@@ -176,17 +343,17 @@ func (w *Worker) setupNotifier() (err error) {
 	return
 }
 
-func (w *Worker) addTargetFile(target string) (tooMany bool, err error) {
+func (w *Worker) addTargetFile(target string) (err error) {
 	var resolved string
 
-	if resolved, err = filepath.Abs(target); err != nil {
+	if resolved, err = w.abs(target); err != nil {
 		err = fmt.Errorf("%q - %w", target, err)
 		return
 	}
 
 	if w.RelativePath != "" {
 		if w.RelativePath == "." {
-			if w.RelativePath, err = os.Getwd(); err != nil {
+			if w.RelativePath, err = w.getwd(); err != nil {
 				err = fmt.Errorf("%q - %w", target, err)
 				return
 			}
@@ -198,101 +365,143 @@ func (w *Worker) addTargetFile(target string) (tooMany bool, err error) {
 	}
 
 	if _, present := w.initLookup[resolved]; !present {
-		if path.Exists(resolved) {
+		if w.exists(resolved) {
 			w.initLookup[resolved] = struct{}{}
 			w.Targets = append(w.Targets, resolved)
+			w.emit(TargetAddedEvent{File: resolved})
+			if w.targetFn != nil {
+				w.targetFn(resolved, nil)
+			}
 		} else {
 			err = fmt.Errorf("%w: %q", ErrNotFound, resolved)
 			return
 		}
 	}
-	tooMany = !w.NoLimits && len(w.Targets) > rpl.MaxFileCount
 	return
 }
 
 func (w *Worker) scanTargetFn(line string) (stop bool) {
-	var eee error
-	if stop, eee = w.addTargetFile(line); eee != nil {
+	if eee := w.addTargetFile(line); eee != nil {
 		w.Notifier.Error("# error: %v\n", eee)
+		w.emit(ErrorEvent{File: line, Err: eee})
+		if w.targetFn != nil {
+			w.targetFn(line, eee)
+		}
 	}
 	return
 }
 
-func (w *Worker) InitTargets() (err error) {
+// InitTargets builds the list of Worker.Targets from the path arguments, any
+// --file lists and os.Stdin. The optional fn is called for each target added
+// and for each error encountered
+func (w *Worker) InitTargets(fn TargetFn) (err error) {
+	err = w.InitTargetsContext(w.Context(), fn)
+	return
+}
+
+// InitTargetsContext is InitTargets which stops adding targets when the
+// context given is done, returning the context error
+func (w *Worker) InitTargetsContext(ctx context.Context, fn TargetFn) (err error) {
 	w.initLookup = make(map[string]struct{})
+	w.targetFn = fn
 
 	// if not recursive, and "." is present, use the CWD files instead of "."
 	if !w.Recurse && slices.Within(".", w.Paths) {
 		w.Paths = slices.Prune(w.Paths, ".")
-		var files []string
-		if files, err = path.ListFiles(".", w.All); err != nil {
-			return
+		w.Paths = append(w.Paths, w.listFiles(".")...)
+	}
+
+	scanFn := func(line string) (stop bool) {
+		if stop = ctx.Err() != nil; !stop {
+			stop = w.scanTargetFn(line)
 		}
-		w.Paths = append(w.Paths, files...)
+		return
 	}
 
 	// add any path arguments given
-	for _, target := range w.Paths {
-		if tooMany, ee := w.addTargetFile(target); tooMany {
-			return ErrTooManyFiles
-		} else if ee != nil {
+	for idx, target := range w.Paths {
+		if ctx.Err() != nil {
+			// cancelled before these were even checked
+			w.unfinished = append(w.unfinished, w.Paths[idx:]...)
+			break
+		} else if ee := w.addTargetFile(target); ee != nil {
+			w.emit(ErrorEvent{File: target, Err: ee})
 			if w.Verbose {
 				w.Notifier.Error("# error: %v\n", ee)
 			}
+			if fn != nil {
+				fn(target, ee)
+			}
 		}
 	}
 
 	// scan and add any "additional files" given
 	for _, target := range w.AddFile {
-		if stopped, ee := scanners.ScanFileLines(target, w.scanTargetFn); ee != nil {
+		if ctx.Err() != nil {
+			break
+		} else if _, ee := w.scanFileLines(target, scanFn); ee != nil {
+			w.emit(ErrorEvent{File: target, Err: ee})
 			w.Notifier.Error("# error scanning --file %q: %v", target, ee)
-		} else if stopped {
-			return ErrTooManyFiles
 		}
 	}
 
-	if w.Stdin {
+	if w.Stdin && ctx.Err() == nil {
 		// scan and add from os.Stdin
 		if w.Null {
 			// using null-terminated paths
-			if scanners.ScanNulls(os.Stdin, w.scanTargetFn) {
-				return ErrTooManyFiles
-			}
+			scanners.ScanNulls(os.Stdin, scanFn)
 		} else {
 			// using one path per line
-			if scanners.ScanLines(os.Stdin, w.scanTargetFn) {
-				return ErrTooManyFiles
-			}
+			scanners.ScanLines(os.Stdin, scanFn)
 		}
 	}
 
 	// free memory
 	w.initLookup = nil
+	w.targetFn = nil
+	err = ctx.Err()
 	return
 }
 
+// FindMatching searches the first batch of files found within the Targets,
+// see NextBatch for searching the remaining batches. Files are searched in
+// batches of at most GetMaxFiles unless NoLimits is set, and the optional fn
+// given is called for each file searched, for all batches
 func (w *Worker) FindMatching(fn rpl.FindAllMatchingFn) (err error) {
-	if w.Regex {
-		if w.MultiLine {
-			w.Files, w.Matched, err = rpl.FindAllMatchingRegexp(w.Pattern, w.Targets, w.All, w.NoLimits, w.BinAsText, w.Recurse, w.Include, w.Exclude, fn)
-		} else {
-			w.Files, w.Matched, err = rpl.FindAllMatchingRegexpLines(w.Pattern, w.Targets, w.All, w.NoLimits, w.BinAsText, w.Recurse, w.Include, w.Exclude, fn)
-		}
-	} else if w.PreserveCase || w.IgnoreCase {
-		w.Files, w.Matched, err = rpl.FindAllMatchingStringInsensitive(w.Search, w.Targets, w.All, w.NoLimits, w.BinAsText, w.Recurse, w.Include, w.Exclude, fn)
-	} else {
-		w.Files, w.Matched, err = rpl.FindAllMatchingString(w.Search, w.Targets, w.All, w.NoLimits, w.BinAsText, w.Recurse, w.Include, w.Exclude, fn)
-	}
+	err = w.FindMatchingContext(w.Context(), fn)
+	return
+}
+
+// FindMatchingContext is FindMatching which stops searching when the context
+// given is done, returning the context error. The context is also used when
+// searching all subsequent batches
+func (w *Worker) FindMatchingContext(ctx context.Context, fn rpl.FindAllMatchingFn) (err error) {
+	w.findCtx = ctx
+	w.findFn = fn
+	w.walker = newTargetWalker(w)
+	w.batches, w.filesCount, w.matchedCount, w.finishedCount = 0, 0, 0, 0
+	w.oversized = nil
+	err = w.findNextBatch()
 	return
 }
 
 func (w *Worker) StartIterating() (iter *Iterator) {
+	for len(w.Matched) == 0 && w.HasMoreBatches() {
+		// skip over batches without any matches
+		if _, err := w.NextBatch(); err != nil {
+			if !isContextErr(err) {
+				w.Notifier.Error("# error: %v\n", err)
+			}
+			return
+		}
+	}
 	if len(w.Matched) > 0 {
 		iter = &Iterator{
 			w:   w,
 			pos: 0,
 		}
 	}
+	w.iter = iter
 	return
 }
 
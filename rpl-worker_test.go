@@ -16,6 +16,7 @@ package replace
 
 import (
 	"os"
+	"path/filepath"
 	"regexp"
 	"sync"
 	"testing"
@@ -708,7 +709,7 @@ func TestWorker(t *testing.T) {
 
 	Convey("FindMatching", t, func() {
 
-		Convey("Too Many Files (Regex)", func() {
+		Convey("Batches (Regex)", func() {
 			m.Lock()
 			defer m.Unlock()
 			outio, errio, w := makeWorker()
@@ -749,12 +750,14 @@ func TestWorker(t *testing.T) {
 				`include=[];`+
 				`}`)
 			So(err1, ShouldEqual, nil)
-			So(err2, ShouldEqual, rpl.ErrTooManyFiles)
+			So(err2, ShouldEqual, nil)
+			So(len(w.Files), ShouldEqual, 1)
+			So(w.HasMoreBatches(), ShouldEqual, true)
 			So(outData, ShouldEqual, ``)
 			So(errData, ShouldEqual, ``)
 		})
 
-		Convey("Too Many Files (Path)", func() {
+		Convey("Batches (Path)", func() {
 			m.Lock()
 			defer m.Unlock()
 			outio, errio, w := makeWorker()
@@ -792,12 +795,13 @@ func TestWorker(t *testing.T) {
 				`exclude=["*~"];`+
 				`include=[];`+
 				`}`)
-			So(err1, ShouldEqual, ErrTooManyFiles)
+			So(err1, ShouldEqual, nil)
+			So(len(w.Targets), ShouldBeGreaterThan, 1)
 			So(outData, ShouldEqual, ``)
 			So(errData, ShouldEqual, ``)
 		})
 
-		Convey("Too Many Files (--file)", func() {
+		Convey("Batches (--file)", func() {
 			m.Lock()
 			defer m.Unlock()
 			outio, errio, w := makeWorker()
@@ -834,9 +838,11 @@ func TestWorker(t *testing.T) {
 				`exclude=["*~"];`+
 				`include=[];`+
 				`}`)
-			So(err1, ShouldEqual, ErrTooManyFiles)
+			So(err1, ShouldEqual, nil)
+			So(len(w.Targets), ShouldBeGreaterThan, 1)
 			So(outData, ShouldEqual, ``)
-			So(errData, ShouldEqual, ``)
+			cwd, _ := os.Getwd()
+			So(errData, ShouldEqual, "# error: not found: \""+filepath.Join(cwd, "_testing", "largefile.txt")+"\"\n")
 		})
 
 		Convey("Error Scanning --file", func() {
@@ -885,7 +891,7 @@ func TestWorker(t *testing.T) {
 			So(errData, ShouldEqual, "# error scanning --file \""+tmpName+"\": open "+tmpName+": permission denied")
 		})
 
-		Convey("Too Many Files (String)", func() {
+		Convey("Batches (String)", func() {
 			m.Lock()
 			defer m.Unlock()
 			outio, errio, w := makeWorker()
@@ -925,12 +931,14 @@ func TestWorker(t *testing.T) {
 				`include=[];`+
 				`}`)
 			So(err1, ShouldEqual, nil)
-			So(err2, ShouldEqual, rpl.ErrTooManyFiles)
+			So(err2, ShouldEqual, nil)
+			So(len(w.Files), ShouldEqual, 1)
+			So(w.HasMoreBatches(), ShouldEqual, true)
 			So(outData, ShouldEqual, ``)
 			So(errData, ShouldEqual, ``)
 		})
 
-		Convey("Too Many Files (StringInsensitive)", func() {
+		Convey("Batches (StringInsensitive)", func() {
 			m.Lock()
 			defer m.Unlock()
 			outio, errio, w := makeWorker()
@@ -970,12 +978,14 @@ func TestWorker(t *testing.T) {
 				`include=[];`+
 				`}`)
 			So(err1, ShouldEqual, nil)
-			So(err2, ShouldEqual, rpl.ErrTooManyFiles)
+			So(err2, ShouldEqual, nil)
+			So(len(w.Files), ShouldEqual, 1)
+			So(w.HasMoreBatches(), ShouldEqual, true)
 			So(outData, ShouldEqual, ``)
 			So(errData, ShouldEqual, ``)
 		})
 
-		Convey("Too Many Files (os.Stdin)", func() {
+		Convey("Batches (os.Stdin)", func() {
 			m.Lock()
 			defer m.Unlock()
 			inio := stdio.NewStdin([]byte(`_testing/hello.html
@@ -1014,12 +1024,13 @@ _testing/test.txt`))
 				`exclude=["*~"];`+
 				`include=[];`+
 				`}`)
-			So(err1, ShouldEqual, ErrTooManyFiles)
+			So(err1, ShouldEqual, nil)
+			So(len(w.Targets), ShouldBeGreaterThan, 1)
 			So(outData, ShouldEqual, ``)
 			So(errData, ShouldEqual, ``)
 		})
 
-		Convey("Too Many Files (os.Stdin --null)", func() {
+		Convey("Batches (os.Stdin --null)", func() {
 			m.Lock()
 			defer m.Unlock()
 			inio := stdio.NewStdin([]byte(`_testing/hello.html` + string(rune(0)) + `_testing/test.txt`))
@@ -1058,13 +1069,39 @@ _testing/test.txt`))
 				`exclude=["*~"];`+
 				`include=[];`+
 				`}`)
-			So(err1, ShouldEqual, ErrTooManyFiles)
+			So(err1, ShouldEqual, nil)
+			So(len(w.Targets), ShouldBeGreaterThan, 1)
 			So(outData, ShouldEqual, ``)
 			So(errData, ShouldEqual, ``)
 		})
 
 	})
 
+	Convey("Max File Size", t, func() {
+		m.Lock()
+		defer m.Unlock()
+		outio, errio, w := makeWorker()
+		defer outio.Restore()
+		defer errio.Restore()
+		w.Recurse = true
+		w.Paths = []string{"_testing"}
+		w.Search = "hello"
+		w.Replace = "olleh"
+		w.IgnoreCase = true
+		w.MaxFileSize = 200
+		err := w.Init()
+		err1 := w.InitTargets(nil)
+		err2 := w.FindMatching(nil)
+		So(err, ShouldEqual, nil)
+		So(err1, ShouldEqual, nil)
+		So(err2, ShouldEqual, nil)
+		So(w.GetMaxFileSizeLabel(), ShouldEqual, "200 B")
+		cwd, _ := os.Getwd()
+		So(w.Oversized(), ShouldContain, filepath.Join(cwd, "_testing", "limitfile.txt"))
+		So(w.Oversized(), ShouldNotContain, filepath.Join(cwd, "_testing", "test.txt"))
+		So(w.Matched, ShouldContain, filepath.Join(cwd, "_testing", "test.txt"))
+	})
+
 	Convey("StartIterating", t, func() {
 		m.Lock()
 		defer m.Unlock()
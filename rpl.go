@@ -37,11 +37,25 @@ var (
 )
 
 var (
-	ErrNotFound      = errors.New("not found")
+	ErrNotFound     = errors.New("not found")
+	ErrFileModified = errors.New("file modified since changes were computed")
+	ErrFileTimeout  = errors.New("file timeout exceeded")
+	// ErrTooManyFiles was returned when there were more than rpl.MaxFileCount
+	// targets
+	//
+	// Deprecated: targets are now searched in batches and this is no longer
+	// returned
 	ErrTooManyFiles  = fmt.Errorf("%w; try batches of %d or less", rpl.ErrTooManyFiles, rpl.MaxFileCount)
-	gNoLimitsWarning = fmt.Sprintf("# WARNING: files larger than %s can consume all available memory\n", MaxFileSizeLabel)
+	gNoLimitsWarning = noLimitsWarning(MaxFileSizeLabel)
 )
 
 var (
+	// MaxFileSizeLabel is the humanized default maximum file size, see
+	// Worker.GetMaxFileSizeLabel for the value actually in effect
 	MaxFileSizeLabel = humanize.Bytes(uint64(rpl.MaxFileSize))
 )
+
+func noLimitsWarning(label string) (warning string) {
+	warning = fmt.Sprintf("# WARNING: files larger than %s can consume all available memory\n", label)
+	return
+}
@@ -28,6 +28,8 @@ const (
 	KeepGroupAccelLabel    = "_Keep Group <F4>"
 	SkipFileAccelLabel     = "_Skip File <F8>"
 	SaveFileAccelLabel     = "Save _File <F9>"
+	SideBySideAccelLabel   = "Side _by Side <F6>"
+	UnifiedAccelLabel      = "_Unified <F6>"
 	QuitAccelLabel         = "_Quit <F10>"
 )
 
@@ -39,6 +41,8 @@ const (
 	KeepGroupAccelTooltip    = "keep this group of changes"
 	SkipFileAccelTooltip     = "skip this file and proceed"
 	SaveFileAccelTooltip     = "save this file and proceed"
+	SideBySideAccelTooltip   = "show the changes side by side"
+	UnifiedAccelTooltip      = "show the changes as a unified diff"
 	QuitAccelTooltip         = ""
 )
 
@@ -49,6 +53,7 @@ const (
 	KeepGroupAccelKey    = cdk.KeyF4
 	SkipFileAccelKey     = cdk.KeyF8
 	SaveFileAccelKey     = cdk.KeyF9
+	LayoutAccelKey       = cdk.KeyF6
 )
 
 // Accelerator Paths
@@ -58,6 +63,7 @@ const (
 	KeepGroupAccelPath    = "<rpl-window>/File/KeepGroup"
 	SkipFileAccelPath     = "<rpl-window>/File/SkipFile"
 	SaveFileAccelPath     = "<rpl-window>/File/SaveFile"
+	LayoutAccelPath       = "<rpl-window>/File/Layout"
 	QuitAccelPath         = "<rpl-window>/File/Quit"
 	ExitAccelPath         = "<rpl-window>/File/Exit"
 )
@@ -70,6 +76,7 @@ const (
 	KeepGroupAccelHandle    = "keep-group-accel"
 	SkipFileAccelHandle     = "skip-file-accel"
 	SaveFileAccelHandle     = "save-file-accel"
+	LayoutAccelHandle       = "layout-accel"
 	QuitAccelHandle         = "quit-accel"
 	ExitAccelHandle         = "ctrl-c-accel"
 )
@@ -81,6 +88,7 @@ func (u *CUI) initAccelGroups() {
 	u.WorkAccel.ConnectByPath(KeepGroupAccelPath, KeepGroupAccelHandle, u.accelKeepGroup)
 	u.WorkAccel.ConnectByPath(SkipFileAccelPath, SkipFileAccelHandle, u.accelSkipFile)
 	u.WorkAccel.ConnectByPath(SaveFileAccelPath, SaveFileAccelHandle, u.accelSaveFile)
+	u.WorkAccel.ConnectByPath(LayoutAccelPath, LayoutAccelHandle, u.accelLayout)
 	u.Window.AddAccelGroup(u.WorkAccel)
 
 	ag := ctk.NewAccelGroup()
@@ -95,13 +103,13 @@ func (u *CUI) reportAccel(handle string) {
 
 func (u *CUI) quitAccel(_ ...interface{}) (handled bool) {
 	u.reportAccel(QuitAccelHandle)
-	u.requestQuit()
+	u.requestCancel()
 	return
 }
 
 func (u *CUI) exitAccel(_ ...interface{}) (handled bool) {
 	u.reportAccel(ExitAccelHandle + " (ctrl+c)")
-	u.requestQuit()
+	u.requestCancel()
 	return
 }
 
@@ -152,3 +160,11 @@ func (u *CUI) accelSaveFile(_ ...interface{}) (handled bool) {
 	}
 	return
 }
+
+func (u *CUI) accelLayout(_ ...interface{}) (handled bool) {
+	if u.LayoutButton.IsVisible() {
+		u.reportAccel(LayoutAccelHandle)
+		u.toggleLayout()
+	}
+	return
+}
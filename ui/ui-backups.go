@@ -0,0 +1,193 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ui
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/urfave/cli/v2"
+
+	replace "github.com/go-curses/coreutils-replace"
+)
+
+// cBackupsAction manages the backup files found with the lookup given, for
+// the command-line arguments given (less any @preset)
+type cBackupsAction func(ctx *cli.Context, lookup replace.BackupLookup, args []string) (err error)
+
+// backupsAction returns the action of the --list-backups, --diff-backup,
+// --restore-backup or --prune-backups flag given, which manage the backup
+// files made by the -b, -B, --backup-template and --backup-dir flags, or nil
+// if none were given
+func (u *CUI) backupsAction(ctx *cli.Context) (action cBackupsAction, err error) {
+	var selected string
+	for _, mode := range []struct {
+		flag   *cli.BoolFlag
+		action cBackupsAction
+	}{
+		{replace.BackupListFlag, u.backupsList},
+		{replace.BackupDiffFlag, u.backupsDiff},
+		{replace.BackupRestoreFlag, u.backupsRestore},
+		{replace.BackupPruneFlag, u.backupsPrune},
+	} {
+		if !ctx.Bool(mode.flag.Name) {
+			continue
+		} else if action != nil {
+			err = fmt.Errorf("--%s cannot be used with --%s", selected, mode.flag.Name)
+			action = nil
+			return
+		}
+		action, selected = mode.action, mode.flag.Name
+	}
+	return
+}
+
+// manageBackups applies any configuration to the cli.Context given before
+// running the backups action, so that the configured backup naming is used
+func (u *CUI) manageBackups(ctx *cli.Context, action cBackupsAction) (err error) {
+	var args []string
+	if _, _, args, err = replace.ApplyConfig(ctx); err != nil {
+		return
+	}
+	var lookup replace.BackupLookup
+	if lookup, err = replace.MakeBackupLookup(ctx); err != nil {
+		return
+	}
+	err = action(ctx, lookup, args)
+	return
+}
+
+func (u *CUI) findBackupChains(ctx *cli.Context, lookup replace.BackupLookup, paths []string) (chains []*replace.BackupChain, err error) {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	for _, target := range paths {
+		var found []*replace.BackupChain
+		if found, err = lookup.Find(target, ctx.Bool(replace.RecurseFlag.Name), ctx.Bool(replace.AllFlag.Name)); err != nil {
+			return
+		}
+		chains = append(chains, found...)
+	}
+	return
+}
+
+func (u *CUI) backupsList(ctx *cli.Context, lookup replace.BackupLookup, args []string) (err error) {
+	var chains []*replace.BackupChain
+	if chains, err = u.findBackupChains(ctx, lookup, args); err != nil {
+		return
+	}
+	for _, chain := range chains {
+		if chain.Exists() {
+			u.notifier.Info("%s\n", chain.Original)
+		} else {
+			u.notifier.Info("%s (missing)\n", chain.Original)
+		}
+		for _, backup := range chain.Backups {
+			u.notifier.Info(
+				"  %3d  %-8s  %-16s  %s\n",
+				backup.Generation,
+				humanize.Bytes(uint64(backup.Size)),
+				humanize.Time(backup.ModTime),
+				backup.Path,
+			)
+		}
+	}
+	return
+}
+
+func (u *CUI) backupsDiff(ctx *cli.Context, lookup replace.BackupLookup, args []string) (err error) {
+	if len(args) == 0 {
+		err = fmt.Errorf("--%s requires at least one file", replace.BackupDiffFlag.Name)
+		return
+	}
+	for _, file := range args {
+		var chain *replace.BackupChain
+		var backup replace.BackupFile
+		if chain, backup, err = lookup.FindFile(file, ctx.Int(replace.BackupGenerationFlag.Name)); err != nil {
+			return
+		}
+		var unified string
+		if unified, err = chain.Diff(backup); err != nil {
+			return
+		}
+		u.notifier.Info(unified)
+	}
+	return
+}
+
+func (u *CUI) backupsRestore(ctx *cli.Context, lookup replace.BackupLookup, args []string) (err error) {
+	if len(args) == 0 {
+		err = fmt.Errorf("--%s requires at least one file", replace.BackupRestoreFlag.Name)
+		return
+	}
+	keep := !ctx.Bool(replace.BackupNoBackupFlag.Name)
+	nop := ctx.Bool(replace.NopFlag.Name)
+	for _, file := range args {
+		var chain *replace.BackupChain
+		var backup replace.BackupFile
+		if chain, backup, err = lookup.FindFile(file, ctx.Int(replace.BackupGenerationFlag.Name)); err != nil {
+			return
+		}
+		if nop {
+			u.notifier.Error("# [nop] would have restored %q from %q\n", chain.Original, backup.Path)
+			continue
+		}
+		var saved string
+		if saved, err = lookup.Restore(chain, backup, keep); err != nil {
+			return
+		} else if saved != "" {
+			u.notifier.Error("# backed up %q to %q\n", chain.Original, saved)
+		}
+		u.notifier.Error("# restored %q from %q\n", chain.Original, backup.Path)
+	}
+	return
+}
+
+func (u *CUI) backupsPrune(ctx *cli.Context, lookup replace.BackupLookup, args []string) (err error) {
+	keep := ctx.Int(replace.BackupKeepFlag.Name)
+	var olderThan time.Duration
+	if value := ctx.String(replace.BackupOlderThanFlag.Name); value != "" {
+		if olderThan, err = replace.ParseAge(value); err != nil {
+			err = fmt.Errorf("--%s %w", replace.BackupOlderThanFlag.Name, err)
+			return
+		}
+	}
+	if keep <= 0 && olderThan <= 0 {
+		err = fmt.Errorf("--%s requires --%s and/or --%s", replace.BackupPruneFlag.Name, replace.BackupKeepFlag.Name, replace.BackupOlderThanFlag.Name)
+		return
+	}
+
+	var chains []*replace.BackupChain
+	if chains, err = u.findBackupChains(ctx, lookup, args); err != nil {
+		return
+	}
+
+	nop := ctx.Bool(replace.NopFlag.Name)
+	now := time.Now()
+	for _, chain := range chains {
+		for _, backup := range chain.Prunable(keep, olderThan, now) {
+			if nop {
+				u.notifier.Error("# [nop] would have removed %q\n", backup.Path)
+			} else if err = os.Remove(backup.Path); err != nil {
+				return
+			} else {
+				u.notifier.Error("# removed %q\n", backup.Path)
+			}
+		}
+	}
+	return
+}
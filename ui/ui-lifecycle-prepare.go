@@ -50,6 +50,14 @@ func (u *CUI) prepare(data []interface{}, argv ...interface{}) cenums.EventFlag
 		return cenums.EVENT_STOP
 	}
 
+	if action, err := u.backupsAction(ctx); err != nil || action != nil {
+		// managing backups is instead of searching and replacing
+		if u.LastError = err; err == nil {
+			u.LastError = u.manageBackups(ctx, action)
+		}
+		return cenums.EVENT_STOP
+	}
+
 	if worker, eventFlag, err := replace.MakeWorker(ctx, u.notifier); err != nil || eventFlag == cenums.EVENT_STOP {
 		u.LastError = err
 		return cenums.EVENT_STOP
@@ -15,10 +15,15 @@
 package ui
 
 import (
+	"github.com/go-curses/cdk"
 	cenums "github.com/go-curses/cdk/lib/enums"
 )
 
 func (u *CUI) resize(data []interface{}, argv ...interface{}) cenums.EventFlag {
+	if u.updateDiffLayout(false) {
+		// draw again once the resized window has been laid out
+		cdk.Go(u.requestDrawAndShow)
+	}
 	switch u.view {
 	case FileView:
 		u.updateFileWorkStatus()
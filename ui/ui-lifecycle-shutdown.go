@@ -15,13 +15,20 @@
 package ui
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
+	"github.com/dustin/go-humanize"
+
+	"github.com/go-corelibs/path"
 	rpl "github.com/go-corelibs/replace"
 	cenums "github.com/go-curses/cdk/lib/enums"
+
 	replace "github.com/go-curses/coreutils-replace"
 )
 
@@ -31,6 +38,9 @@ func (u *CUI) shutdown(data []interface{}, argv ...interface{}) cenums.EventFlag
 	if u.LastError != nil {
 		u.notifier.Error("# error: %v\n", strings.TrimSuffix(u.LastError.Error(), "\n"))
 		return cenums.EVENT_PASS
+	} else if u.worker == nil {
+		// backups were managed instead
+		return cenums.EVENT_PASS
 	}
 
 	if u.worker.Interactive {
@@ -49,34 +59,64 @@ func (u *CUI) shutdown(data []interface{}, argv ...interface{}) cenums.EventFlag
 			})
 			_ = e.Remove()
 		}
+		u.shutdownReportOversized()
+		u.shutdownReportBackupDir()
+		if len(u.worker.Unfinished()) > 0 {
+			u.shutdownReportUnfinished()
+		}
 		return cenums.EVENT_PASS
 	}
 
+	stop := u.cancelOnInterrupt()
+	defer stop()
 	return u.shutdownRunCLI()
 }
 
-func (u *CUI) shutdownRunMatchingFn(file string, matched bool, err error) {
-	if err != nil {
-		if u.worker.Verbose && errors.Is(err, rpl.ErrLargeFile) {
-			u.notifier.Error("# ignoring large file (max %v): %q\n", replace.MaxFileSizeLabel, file)
-		} else if u.worker.Verbose && errors.Is(err, rpl.ErrBinaryFile) {
-			u.notifier.Error("# ignoring binary file: %q\n", file)
-		} else {
-			u.notifier.Error("# error: %v - %q\n", err, file)
-		}
+func (u *CUI) shutdownObserveCLI(event replace.Event) {
+	e, ok := event.(replace.ScanFinishedEvent)
+	if !ok || e.Err == nil {
 		return
 	}
+	if u.worker.Verbose && errors.Is(e.Err, rpl.ErrLargeFile) {
+		u.notifier.Error("# ignoring large file (max %v): %q\n", u.worker.GetMaxFileSizeLabel(), e.File)
+	} else if errors.Is(e.Err, rpl.ErrLargeFile) {
+		// reported in the summary
+	} else if errors.Is(e.Err, context.Canceled) || errors.Is(e.Err, context.DeadlineExceeded) {
+		// reported in the summary
+	} else if u.worker.Verbose && errors.Is(e.Err, rpl.ErrBinaryFile) {
+		u.notifier.Error("# ignoring binary file: %q\n", e.File)
+	} else {
+		u.notifier.Error("# error: %v - %q\n", e.Err, e.File)
+	}
 }
 
 func (u *CUI) shutdownRunCLI() cenums.EventFlag {
 
-	if err := u.worker.InitTargets(); err != nil {
-		u.notifier.Error("# error: %v\n", err)
+	if u.worker.ApplyPatchFile != "" {
+		return u.shutdownApplyPatch()
+	} else if u.worker.Filter {
+		return u.shutdownFilter()
+	}
+
+	if err := u.worker.InitTargets(nil); err != nil {
+		if u.worker.Cancelled() != nil {
+			u.shutdownReportUnfinished()
+		} else {
+			u.notifier.Error("# error: %v\n", err)
+		}
 		return cenums.EVENT_PASS
 	}
 
-	if err := u.worker.FindMatching(u.shutdownRunMatchingFn); err != nil {
-		u.notifier.Error("# error: %v\n", err)
+	unsubscribe := u.worker.Subscribe(replace.ObserverFunc(u.shutdownObserveCLI))
+	defer unsubscribe()
+
+	if err := u.worker.FindMatching(nil); err != nil {
+		if u.worker.Cancelled() != nil {
+			u.shutdownReportOversized()
+			u.shutdownReportUnfinished()
+		} else {
+			u.notifier.Error("# error: %v\n", err)
+		}
 		return cenums.EVENT_PASS
 	}
 
@@ -87,13 +127,16 @@ func (u *CUI) shutdownRunCLI() cenums.EventFlag {
 		} else {
 			format = "# replacing"
 		}
-		format += " %q with %q in %d of %d files\n"
+		format += " %q with %q in %d of %d files"
+		if u.worker.HasMoreBatches() {
+			format += " (first batch)"
+		}
 		u.notifier.Error(
-			format,
+			format+"\n",
 			u.worker.Search,
 			u.worker.Replace,
-			len(u.worker.Matched),
-			len(u.worker.Files),
+			u.worker.MatchedCount(),
+			u.worker.FilesCount(),
 		)
 	}
 
@@ -102,7 +145,11 @@ func (u *CUI) shutdownRunCLI() cenums.EventFlag {
 		var unified, backup string
 		var err error
 		if count, unified, backup, err = iter.ApplyAll(); err != nil {
-			u.notifier.Error("# %q error: %v\n", err)
+			if u.worker.Cancelled() != nil {
+				// nothing more is written
+				break
+			}
+			u.notifier.Error("# %q error: %v\n", iter.Name(), err)
 			continue
 		}
 
@@ -123,5 +170,117 @@ func (u *CUI) shutdownRunCLI() cenums.EventFlag {
 		}
 	}
 
+	u.shutdownReportOversized()
+	u.shutdownReportBackupDir()
+	if u.worker.Cancelled() != nil || len(u.worker.Unfinished()) > 0 {
+		u.shutdownReportUnfinished()
+	}
+	return cenums.EVENT_PASS
+}
+
+func (u *CUI) shutdownApplyPatch() cenums.EventFlag {
+	var prefix string
+	if u.worker.Nop {
+		prefix = "[nop] "
+	}
+	err := u.worker.ApplyPatch(u.worker.ApplyPatchFile, func(result replace.PatchResult, err error) {
+		if err != nil {
+			u.notifier.Error("# %q error: %v\n", result.Target, err)
+			return
+		}
+		for idx, hunk := range result.Hunks {
+			if hunk.Rejected {
+				u.notifier.Error("# %shunk #%d FAILED at %d: %q\n", prefix, idx+1, hunk.Hunk.OldStart, result.Target)
+			} else if hunk.Offset != 0 || hunk.Fuzz != 0 {
+				u.notifier.Error("# %shunk #%d succeeded at %d (offset %d lines, fuzz %d): %q\n", prefix, idx+1, hunk.Line, hunk.Offset, hunk.Fuzz, result.Target)
+			}
+		}
+		if result.Backup != "" {
+			u.notifier.Error("# %sbacked up %q to %q\n", prefix, result.Target, result.Backup)
+		}
+		if rejected := result.Rejected(); rejected > 0 && u.worker.Nop {
+			u.notifier.Error("# %s%d of %d hunks FAILED: %q\n", prefix, rejected, len(result.Hunks), result.Target)
+		} else if rejected > 0 {
+			u.notifier.Error("# %d of %d hunks FAILED, rejects saved to: %q\n", rejected, len(result.Hunks), result.Rejects)
+		}
+		if applied := len(result.Hunks) - result.Rejected(); applied > 0 {
+			u.notifier.Error("# %sapplied %d of %d hunks to: %q\n", prefix, applied, len(result.Hunks), result.Target)
+		}
+	})
+	if err != nil && u.worker.Cancelled() != nil {
+		u.shutdownReportUnfinished()
+	} else if err != nil {
+		u.notifier.Error("# error: %v\n", err)
+	}
+	return cenums.EVENT_PASS
+}
+
+func (u *CUI) shutdownFilter() cenums.EventFlag {
+	count, unified, err := u.worker.FilterContent(os.Stdin, os.Stdout)
+	if err != nil {
+		u.notifier.Error("# error: %v\n", err)
+		return cenums.EVENT_PASS
+	}
+	if u.worker.ShowDiff && unified != "" {
+		// stdout is the filtered content
+		u.notifier.Error("%s", unified)
+	}
+	if u.worker.Verbose && u.worker.Nop {
+		u.notifier.Error("# [nop] would have made %d changes to: stdin\n", count)
+	} else if u.worker.Verbose {
+		u.notifier.Error("# made %d changes to: stdin\n", count)
+	}
 	return cenums.EVENT_PASS
 }
+
+func (u *CUI) shutdownReportOversized() {
+	if oversized := u.worker.Oversized(); len(oversized) > 0 {
+		u.notifier.Error("# skipped %d files larger than %v:\n", len(oversized), u.worker.GetMaxFileSizeLabel())
+		for _, file := range oversized {
+			u.notifier.Error("#   %q (%v)\n", file, humanize.Bytes(uint64(u.worker.FileSize(file))))
+		}
+	}
+}
+
+func (u *CUI) shutdownReportBackupDir() {
+	if dir := u.worker.BackupRunPath(); dir != "" && path.IsDir(dir) {
+		u.notifier.Error("# backups of this run saved to: %q\n", dir)
+	}
+}
+
+// cancelOnInterrupt cancels the worker upon the first interrupt or terminate
+// signal, in the same way as quitting the user interface, any subsequent
+// signals are handled normally
+func (u *CUI) cancelOnInterrupt() (stop func()) {
+	sig := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sig:
+			signal.Stop(sig)
+			u.worker.Cancel()
+		case <-done:
+		}
+	}()
+	stop = func() {
+		signal.Stop(sig)
+		close(done)
+	}
+	return
+}
+
+func (u *CUI) shutdownReportUnfinished() {
+	if err := u.worker.Cancelled(); errors.Is(err, context.DeadlineExceeded) {
+		u.notifier.Error("# timed out after %v\n", u.worker.Timeout)
+	} else if err != nil {
+		u.notifier.Error("# cancelled\n")
+	}
+	// the finished files were each reported as they were changed
+	u.notifier.Error("# finished %d files\n", u.worker.FinishedCount())
+	unfinished := u.worker.Unfinished()
+	u.notifier.Error("# unfinished %d files:\n", len(unfinished))
+	for _, file := range unfinished {
+		u.notifier.Error("#   %q\n", file)
+	}
+}
@@ -94,6 +94,17 @@ func (u *CUI) startup(_ []interface{}, argv ...interface{}) cenums.EventFlag {
 		u.DiffLabel.SetLineWrapMode(cenums.WRAP_NONE)
 		u.DiffView.Add(u.DiffLabel)
 
+		u.SideBySideBox = ctk.NewHBox(true, 1)
+		u.SideBySideBox.SetName("side-by-side")
+		u.SideBySideBox.Hide()
+		vbox.PackStart(u.SideBySideBox, true, true, 0)
+
+		u.BeforeView, u.BeforeLabel = u.makeSideView("before")
+		u.SideBySideBox.PackStart(u.BeforeView, true, true, 0)
+		u.AfterView, u.AfterLabel = u.makeSideView("after")
+		u.SideBySideBox.PackStart(u.AfterView, true, true, 0)
+		u.syncSideViews()
+
 		u.FooterLabel = ctk.NewLabel("")
 		u.FooterLabel.Hide()
 		u.FooterLabel.SetUseMarkup(true)
@@ -163,6 +174,12 @@ func (u *CUI) startup(_ []interface{}, argv ...interface{}) cenums.EventFlag {
 		u.SaveFileButton.Hide()
 		workButtonsArea.PackStart(u.SaveFileButton, false, false, 0)
 
+		u.LayoutButton = mkButton("layout", SideBySideAccelLabel, SideBySideAccelTooltip, LayoutAccelHandle, func() {
+			u.WorkAccel.Activate(LayoutAccelKey, 0)
+		})
+		u.LayoutButton.Hide()
+		workButtonsArea.PackStart(u.LayoutButton, false, false, 0)
+
 		waRightSep := ctk.NewSeparator()
 		waRightSep.Show()
 		workButtonsArea.PackStart(waRightSep, true, true, 0)
@@ -186,7 +203,7 @@ func (u *CUI) startup(_ []interface{}, argv ...interface{}) cenums.EventFlag {
 		u.StatusLabel.SetSingleLineMode(true)
 		u.ActionArea.PackStart(u.StatusLabel, true, true, 1)
 
-		u.QuitButton = mkButton("quit", QuitAccelLabel, QuitAccelTooltip, QuitAccelHandle, u.requestQuit)
+		u.QuitButton = mkButton("quit", QuitAccelLabel, QuitAccelTooltip, QuitAccelHandle, u.requestCancel)
 		u.QuitButton.Show()
 		u.ActionArea.PackStart(u.QuitButton, false, false, 0)
 
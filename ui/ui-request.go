@@ -25,6 +25,14 @@ func (u *CUI) requestQuit() {
 	u.Display.RequestQuit()
 }
 
+// requestCancel stops any work in progress before quitting
+func (u *CUI) requestCancel() {
+	if u.worker != nil {
+		u.worker.Cancel()
+	}
+	u.requestQuit()
+}
+
 func (u *CUI) requestDrawAndShow() {
 	u.Window.Resize()
 	u.Display.RequestDraw()
@@ -0,0 +1,283 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ui
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-corelibs/diff"
+	cenums "github.com/go-curses/cdk/lib/enums"
+	"github.com/go-curses/ctk"
+	"github.com/go-curses/ctk/lib/enums"
+)
+
+// SideBySideMinWidth is the narrowest screen width using the side-by-side
+// layout, narrower screens fall back to the unified layout
+const SideBySideMinWidth = 120
+
+var (
+	gTangoRender = diff.TangoRender.(*diff.CRender)
+	gHunkHeader  = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+)
+
+// cSideLine is one line of one column of the side-by-side layout
+type cSideLine struct {
+	// kind is the unified diff line prefix, or zero for padding
+	kind   byte
+	number int
+	markup string
+}
+
+// renderSideBySide renders the unified diff given as two columns of Tango
+// markup for the before and after content, aligned by line and with the
+// changed spans of paired lines highlighted
+func renderSideBySide(unified string) (before, after string) {
+	var left, right []cSideLine
+	var removed, added []string
+	var a, b int
+
+	pad := func() {
+		for len(left) < len(right) {
+			left = append(left, cSideLine{})
+		}
+		for len(right) < len(left) {
+			right = append(right, cSideLine{})
+		}
+	}
+
+	flush := func() {
+		for idx := 0; idx < len(removed) || idx < len(added); idx++ {
+			if idx < len(removed) && idx < len(added) {
+				ma, mb := gTangoRender.RenderLine(removed[idx], added[idx])
+				left = append(left, cSideLine{kind: '-', number: a, markup: ma})
+				right = append(right, cSideLine{kind: '+', number: b, markup: mb})
+				a, b = a+1, b+1
+			} else if idx < len(removed) {
+				left = append(left, cSideLine{kind: '-', number: a, markup: html.EscapeString(removed[idx])})
+				a += 1
+			} else {
+				right = append(right, cSideLine{kind: '+', number: b, markup: html.EscapeString(added[idx])})
+				b += 1
+			}
+		}
+		removed, added = nil, nil
+		pad()
+	}
+
+	var hunks bool
+	for _, line := range strings.Split(unified, "\n") {
+		if line == "" {
+			continue
+		}
+		switch kind, text := line[0], line[1:]; {
+		case kind == '@':
+			flush()
+			if m := gHunkHeader.FindStringSubmatch(line); m != nil {
+				a, _ = strconv.Atoi(m[1])
+				b, _ = strconv.Atoi(m[2])
+			}
+			hunks = true
+			comment := cSideLine{kind: kind, markup: html.EscapeString(line)}
+			left, right = append(left, comment), append(right, comment)
+		case !hunks && kind == '-':
+			left = append(left, cSideLine{kind: '#', markup: html.EscapeString(line)})
+		case !hunks && kind == '+':
+			right = append(right, cSideLine{kind: '#', markup: html.EscapeString(line)})
+		case kind == '-':
+			if len(added) > 0 {
+				flush()
+			}
+			removed = append(removed, text)
+		case kind == '+':
+			added = append(added, text)
+		case kind == ' ':
+			flush()
+			left = append(left, cSideLine{kind: kind, number: a, markup: html.EscapeString(text)})
+			right = append(right, cSideLine{kind: kind, number: b, markup: html.EscapeString(text)})
+			a, b = a+1, b+1
+		default:
+			flush()
+			comment := cSideLine{kind: '#', markup: html.EscapeString(line)}
+			left, right = append(left, comment), append(right, comment)
+		}
+	}
+	flush()
+
+	width := len(strconv.Itoa(max(a, b)))
+	before, after = renderSideLines(left, width), renderSideLines(right, width)
+	return
+}
+
+// renderSideLines renders one column of the side-by-side layout, with line
+// numbers right-aligned to the width given
+func renderSideLines(lines []cSideLine, width int) (markup string) {
+	var buf strings.Builder
+	for _, line := range lines {
+		gutter := strings.Repeat(" ", width)
+		if line.number > 0 {
+			gutter = fmt.Sprintf("%*d", width, line.number)
+		}
+		switch line.kind {
+		case '-':
+			buf.WriteString(gTangoRender.Line.Rem.Open + gutter + " " + line.markup + gTangoRender.Line.Rem.Close)
+		case '+':
+			buf.WriteString(gTangoRender.Line.Add.Open + gutter + " " + line.markup + gTangoRender.Line.Add.Close)
+		case ' ':
+			buf.WriteString(gTangoRender.Normal.Open + gutter + " " + line.markup + gTangoRender.Normal.Close)
+		case 0:
+			buf.WriteString(gTangoRender.Normal.Open + gutter + gTangoRender.Normal.Close)
+		default:
+			buf.WriteString(gTangoRender.Comment.Open + line.markup + gTangoRender.Comment.Close)
+		}
+		buf.WriteString("\n")
+	}
+	markup = buf.String()
+	return
+}
+
+// makeSideView constructs one column of the side-by-side layout
+func (u *CUI) makeSideView(name string) (view ctk.ScrolledViewport, label ctk.Label) {
+	view = ctk.NewScrolledViewport()
+	view.SetName(name + "-view")
+	view.Show()
+	view.SetPolicy(enums.PolicyAutomatic, enums.PolicyAutomatic)
+
+	label = ctk.NewLabel("")
+	label.SetName(name + "-text")
+	label.Show()
+	label.SetUseMarkup(true)
+	label.SetSingleLineMode(false)
+	label.SetJustify(cenums.JUSTIFY_NONE)
+	label.SetLineWrap(false)
+	label.SetLineWrapMode(cenums.WRAP_NONE)
+	view.Add(label)
+	return
+}
+
+// syncSideViews scrolls each column of the side-by-side layout along with
+// the other
+func (u *CUI) syncSideViews() {
+	sync := func(handle string, from, to ctk.ScrolledViewport, adjustment func(v ctk.ScrolledViewport) ctk.Adjustment) {
+		adjustment(from).Connect(ctk.SignalValueChanged, handle, func(data []interface{}, argv ...interface{}) cenums.EventFlag {
+			if value, other := adjustment(from).GetValue(), adjustment(to); other.GetValue() != value {
+				other.SetValue(value)
+				to.Resize()
+			}
+			return cenums.EVENT_PASS
+		})
+	}
+	vertical := func(v ctk.ScrolledViewport) ctk.Adjustment { return v.GetVAdjustment() }
+	horizontal := func(v ctk.ScrolledViewport) ctk.Adjustment { return v.GetHAdjustment() }
+	sync("before-vertical-sync", u.BeforeView, u.AfterView, vertical)
+	sync("before-horizontal-sync", u.BeforeView, u.AfterView, horizontal)
+	sync("after-vertical-sync", u.AfterView, u.BeforeView, vertical)
+	sync("after-horizontal-sync", u.AfterView, u.BeforeView, horizontal)
+}
+
+// isSideBySide returns true if the side-by-side layout is selected and the
+// screen is wide enough to use it
+func (u *CUI) isSideBySide() (ok bool) {
+	if u.sideBySide {
+		w, _ := u.Display.Screen().Size()
+		ok = w >= SideBySideMinWidth
+	}
+	return
+}
+
+// showDiffViews shows the diff views of the current layout
+func (u *CUI) showDiffViews() {
+	if u.split {
+		u.DiffView.Hide()
+		u.SideBySideBox.Show()
+	} else {
+		u.SideBySideBox.Hide()
+		u.DiffView.Show()
+	}
+}
+
+// setSideLabel sets the markup of one column of the side-by-side layout
+func (u *CUI) setSideLabel(label ctk.Label, markup string) {
+	if err := label.SetMarkup(markup); err != nil {
+		label.LogErr(err)
+	}
+	label.SetSizeRequest(label.GetPlainTextInfo())
+	label.Resize()
+}
+
+// updateDiffLayout renders the current patch in the unified or side-by-side
+// layout when forced or when the layout presented is no longer the one to use,
+// scrolling to the top
+func (u *CUI) updateDiffLayout(force bool) (switched bool) {
+	split := u.patch != "" && u.isSideBySide()
+	if switched = split != u.split; !force && !switched {
+		u.updateLayoutButton()
+		return
+	}
+	focused := u.DiffView.HasFocus() || u.BeforeView.HasFocus() || u.AfterView.HasFocus()
+	u.split = split
+
+	if u.split {
+		before, after := renderSideBySide(u.patch)
+		u.setSideLabel(u.BeforeLabel, before)
+		u.setSideLabel(u.AfterLabel, after)
+		u.BeforeView.ScrollTop()
+		u.AfterView.ScrollTop()
+	} else {
+		u.setDiffLabel(diff.TangoRender.RenderDiff(u.patch), true)
+		u.DiffView.ScrollTop()
+	}
+
+	if u.DiffView.IsVisible() || u.SideBySideBox.IsVisible() {
+		u.showDiffViews()
+	}
+	if focused {
+		if u.split {
+			u.BeforeView.GrabFocus()
+		} else {
+			u.DiffView.GrabFocus()
+		}
+	}
+	u.updateLayoutButton()
+	u.Window.Resize()
+	return
+}
+
+// updateLayoutButton shows the layout toggle when there is a patch and the
+// screen is wide enough for the side-by-side layout
+func (u *CUI) updateLayoutButton() {
+	if w, _ := u.Display.Screen().Size(); u.patch == "" || w < SideBySideMinWidth {
+		u.LayoutButton.Hide()
+		return
+	}
+	if u.sideBySide {
+		u.LayoutButton.SetLabel(UnifiedAccelLabel)
+		u.LayoutButton.SetTooltipText(UnifiedAccelTooltip)
+	} else {
+		u.LayoutButton.SetLabel(SideBySideAccelLabel)
+		u.LayoutButton.SetTooltipText(SideBySideAccelTooltip)
+	}
+	u.LayoutButton.Show()
+}
+
+// toggleLayout switches between the unified and side-by-side layouts
+func (u *CUI) toggleLayout() {
+	u.sideBySide = !u.sideBySide
+	u.updateDiffLayout(false)
+	u.requestDrawAndShow()
+}
@@ -18,7 +18,6 @@ import (
 	"fmt"
 	"path/filepath"
 
-	"github.com/go-corelibs/diff"
 	"github.com/go-curses/cdk/lib/math"
 	"github.com/go-curses/ctk/lib/enums"
 )
@@ -55,9 +54,10 @@ func (u *CUI) setFocusLabels(focused bool) {
 	vbox := u.Window.GetVBox()
 	if focused {
 		u.DiffView.Hide()
+		u.SideBySideBox.Hide()
 		vbox.SetChildPacking(u.HeaderLabel, true, true, 0, enums.PackStart)
 	} else {
-		u.DiffView.Show()
+		u.showDiffViews()
 		vbox.SetChildPacking(u.HeaderLabel, false, false, 0, enums.PackStart)
 	}
 }
@@ -88,9 +88,8 @@ func (u *CUI) setDiffLabel(text string, markup bool) {
 }
 
 func (u *CUI) setDiffPatch(unified string) {
-	markup := diff.TangoRender.RenderDiff(unified)
-	u.setDiffLabel(markup, true)
-	u.DiffView.ScrollTop()
+	u.patch = unified
+	u.updateDiffLayout(true)
 }
 
 func (u *CUI) setFooterLabel(text string) {
@@ -138,7 +137,12 @@ func (u *CUI) finishInitWorkStatus(maxLen int) {
 }
 
 func (u *CUI) updateStatusLine() {
-	status := fmt.Sprintf("%d/%d: ", u.iter.Pos()+1, len(u.worker.Matched))
+	status := fmt.Sprintf("%d/%d", u.iter.Pos()+1, u.worker.MatchedCount())
+	if u.worker.HasMoreBatches() {
+		// more files yet to be searched
+		status += "+"
+	}
+	status += ": "
 	w, _ := u.Display.Screen().Size()
 	alloc := u.QuitButton.GetAllocation()
 	padding := 10
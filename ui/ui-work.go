@@ -15,9 +15,13 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
 
+	"github.com/go-curses/cdk"
 	"github.com/go-curses/cdk/lib/math"
+
+	replace "github.com/go-curses/coreutils-replace"
 )
 
 func (u *CUI) initWork() {
@@ -25,30 +29,56 @@ func (u *CUI) initWork() {
 	u.DiffView.Show()
 	u.DiffLabel.Show()
 
+	cdk.Go(func() {
+		// quit when cancelled or the --timeout is exceeded, unfinished files
+		// are reported during shutdown
+		<-u.worker.Context().Done()
+		u.requestQuit()
+	})
+
 	w, h := u.Display.Screen().Size()
 	maxWidth := math.FloorI((w/2)-2, 10)
 	maxHeight := math.FloorI(h-10, 1)
-	if u.LastError = u.worker.InitTargets(); u.LastError != nil {
+	if err := u.worker.InitTargets(func(target string, err error) {
+		if err == nil {
+			u.setStatusLabel(cFindResult{target: target}.Status(maxWidth))
+		}
+	}); err != nil {
+		if u.worker.Cancelled() == nil {
+			u.LastError = err
+		}
 		u.requestQuit()
 		return
 	}
-	if u.LastError = u.worker.FindMatching(func(file string, matched bool, err error) {
+	if err := u.worker.FindMatching(func(file string, matched bool, err error) {
+		if u.iter != nil {
+			// searching subsequent batches while working through files
+			if err != nil {
+				u.notifier.Error("# error: %v - %q\n", err, file)
+			}
+			return
+		}
 		u.updateInitWorkStatus(maxWidth, maxHeight, cFindResult{
 			target:  file,
 			matched: matched,
 			err:     err,
 		})
-	}); u.LastError != nil {
+	}); err != nil {
+		if u.worker.Cancelled() == nil {
+			u.LastError = err
+		}
 		u.requestQuit()
 		return
 	}
 
 	var count int
-	if count = len(u.worker.Matched); count == 0 {
+	if count = len(u.worker.Matched); count == 0 && !u.worker.HasMoreBatches() {
 		u.setHeaderLabel(u.getSearchText("no files contain"))
 		u.setFocusLabels(true)
 	} else {
-		if count == 1 {
+		if u.worker.HasMoreBatches() {
+			u.setHeaderLabel(u.getSearchText(fmt.Sprintf("%d files in the first batch of %d contain", count, len(u.worker.Files))))
+		} else if count == 1 {
 			u.setHeaderLabel(u.getSearchText("one file contains"))
 		} else {
 			u.setHeaderLabel(u.getSearchText(fmt.Sprintf("%d files contain", count)))
@@ -60,7 +90,7 @@ func (u *CUI) initWork() {
 
 	if u.worker.Pause {
 		u.finishInitWorkStatus(maxWidth)
-		if count > 0 {
+		if count > 0 || u.worker.HasMoreBatches() {
 			u.ContinueButton.Show()
 			u.ContinueButton.GrabFocus()
 		}
@@ -78,15 +108,19 @@ func (u *CUI) startWork() {
 	u.DiffView.ScrollTop()
 
 	u.iter = u.worker.StartIterating()
-	if len(u.worker.Matched) > 0 {
+	if u.iter != nil {
 		// work to do
-		u.processNextFile()
-		u.DiffView.GrabFocus()
+		u.processCurrentFile()
+		if u.split {
+			u.BeforeView.GrabFocus()
+		} else {
+			u.DiffView.GrabFocus()
+		}
 		return
 	}
 
 	// no work to do
-	if len(u.worker.Files) > 0 {
+	if u.worker.FilesCount() > 0 {
 		u.setHeaderLabel(fmt.Sprintf("no files match search: %q", u.worker.Search))
 	} else {
 		u.setHeaderLabel("no files to search")
@@ -100,9 +134,15 @@ func (u *CUI) startWork() {
 func (u *CUI) saveFileAndProcessNextFile() {
 	if u.iter != nil && u.delta != nil {
 		if u.worker.Nop {
-			u.notifier.Info(u.delta.UnifiedEdits())
+			u.notifier.Info(u.worker.FormatPatch(u.iter.Name(), u.delta.UnifiedEdits()))
 		} else {
-			if _, unified, backup, err := u.iter.ApplySpecific(u.delta); err != nil {
+			if _, unified, backup, err := u.iter.ApplySpecific(u.delta); errors.Is(err, replace.ErrFileModified) {
+				// the file changed on disk while the user was reviewing it,
+				// recompute the changes and ask the user again
+				u.notifier.Error("# %v\n", err)
+				u.reviewCurrentFile()
+				return
+			} else if err != nil {
 				u.notifier.Error("# error applying changes to %q: %v\n", u.iter.Name(), err)
 			} else {
 				if u.worker.Verbose && backup != "" {
@@ -116,38 +156,56 @@ func (u *CUI) saveFileAndProcessNextFile() {
 	}
 }
 
+// processNextFile moves past the current file and presents the next
 func (u *CUI) processNextFile() {
-	if u.delta == nil {
-		// just started working
-	} else {
-		// move to the next
-		u.iter.Next()
-	}
+	u.iter.Next()
+	u.processCurrentFile()
+}
 
-	if !u.iter.Valid() {
-		// all done!
+// processCurrentFile presents the current file, or the next file with changes
+// computed without error, quitting when none remain or the work is cancelled
+func (u *CUI) processCurrentFile() {
+	if !u.computeNextFile() {
+		// all done, quit or timed out
 		u.requestQuit()
 		return
 	}
+	u.setDiffPatch(u.delta.UnifiedEdits())
+	u.presentFileView()
+}
 
-	var err error
-	if _, _, u.count, u.delta, err = u.iter.Replace(); err != nil {
-		u.notifier.Error(err.Error())
+func (u *CUI) reviewCurrentFile() {
+	u.group = -1
+	if !u.computeCurrentFile() {
 		u.processNextFile()
 		return
 	}
+	u.setDiffPatch(u.delta.UnifiedEdits())
+	u.presentFileView()
+	u.setHeaderLabel("file changed on disk, please review the changes again")
+}
 
-	u.delta.KeepAll()
-	unified := u.delta.UnifiedEdits()
-	u.setDiffPatch(unified)
-
-	if count := u.delta.EditGroupsLen(); count > 1 {
-		u.setFooterLabel("one group of changes")
-	} else {
-		u.setFooterLabel(fmt.Sprintf("%d groups of changes", count))
+// computeNextFile computes the changes of the current file, moving past any
+// files which fail to compute, and returns false when no files remain or the
+// work is cancelled
+func (u *CUI) computeNextFile() (ok bool) {
+	for ; u.iter.Valid() && u.worker.Cancelled() == nil; u.iter.Next() {
+		if ok = u.computeCurrentFile(); ok {
+			return
+		}
 	}
+	return
+}
 
-	u.presentFileView()
+func (u *CUI) computeCurrentFile() (ok bool) {
+	var err error
+	if _, _, u.count, u.delta, err = u.iter.Replace(); err != nil {
+		u.notifier.Error("# error: %v - %q\n", err, u.iter.Name())
+		return
+	}
+	u.delta.KeepAll()
+	ok = true
+	return
 }
 
 func (u *CUI) keepCurrentGroup() {
@@ -198,9 +256,9 @@ func (u *CUI) presentFileView() {
 	u.SkipGroupButton.Hide()
 	u.KeepGroupButton.Hide()
 
-	numMatched := len(u.worker.Matched)
+	numMatched := u.worker.MatchedCount()
 
-	if numMatched > 1 {
+	if numMatched > 1 || u.worker.HasMoreBatches() {
 		u.SkipFileButton.Show()
 	} else {
 		u.SkipFileButton.Hide()
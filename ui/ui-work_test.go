@@ -0,0 +1,71 @@
+// Copyright (c) 2024  The Go-Curses Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/go-corelibs/notify"
+
+	replace "github.com/go-curses/coreutils-replace"
+)
+
+func TestWork(t *testing.T) {
+	t.Parallel()
+
+	// makeCUI returns a CUI iterating over the files given, all of which are
+	// matched before the files named in missing are removed
+	makeCUI := func(files []string, missing ...string) (u *CUI, paths []string) {
+		dir := t.TempDir()
+		for _, file := range files {
+			paths = append(paths, filepath.Join(dir, file))
+			So(os.WriteFile(paths[len(paths)-1], []byte("hello world\n"), 0644), ShouldEqual, nil)
+		}
+		w, err := replace.New(
+			replace.WithQuiet(true),
+			replace.WithSearch("hello", "olleh"),
+			replace.WithPaths(paths...),
+		)
+		So(err, ShouldEqual, nil)
+		So(w.InitTargets(nil), ShouldEqual, nil)
+		So(w.FindMatching(nil), ShouldEqual, nil)
+		So(w.Matched, ShouldEqual, paths)
+		for _, file := range missing {
+			So(os.Remove(filepath.Join(dir, file)), ShouldEqual, nil)
+		}
+		u = &CUI{
+			notifier: notify.New(notify.Quiet).Make(),
+			worker:   w,
+			iter:     w.StartIterating(),
+		}
+		return
+	}
+
+	Convey("Compute Errors", t, func() {
+		u, paths := makeCUI([]string{"a.txt", "b.txt", "c.txt"}, "a.txt", "b.txt")
+		So(u.computeNextFile(), ShouldEqual, true)
+		So(u.iter.Name(), ShouldEqual, paths[2])
+		So(u.count, ShouldEqual, 1)
+		So(u.delta, ShouldNotBeNil)
+
+		u, _ = makeCUI([]string{"a.txt", "b.txt"}, "a.txt", "b.txt")
+		So(u.computeNextFile(), ShouldEqual, false)
+		So(u.iter.Valid(), ShouldEqual, false)
+	})
+}
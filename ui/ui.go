@@ -49,6 +49,11 @@ type CUI struct {
 	FooterLabel        ctk.Label
 	DiffView           ctk.ScrolledViewport
 	DiffLabel          ctk.Label
+	SideBySideBox      ctk.HBox
+	BeforeView         ctk.ScrolledViewport
+	BeforeLabel        ctk.Label
+	AfterView          ctk.ScrolledViewport
+	AfterLabel         ctk.Label
 	WorkAccel          ctk.AccelGroup
 	ContinueButton     ctk.Button
 	SelectGroupsButton ctk.Button
@@ -56,6 +61,7 @@ type CUI struct {
 	SkipGroupButton    ctk.Button
 	SkipFileButton     ctk.Button
 	SaveFileButton     ctk.Button
+	LayoutButton       ctk.Button
 	QuitButton         ctk.Button
 
 	ActionArea ctk.HButtonBox
@@ -74,6 +80,12 @@ type CUI struct {
 
 	pause bool
 
+	// patch is the unified diff presented, sideBySide is the layout selected
+	// and split is true when the side-by-side layout is presented
+	patch      string
+	sideBySide bool
+	split      bool
+
 	results cFindResults
 
 	view ViewType
@@ -108,21 +120,54 @@ func NewUI(name, usage, description, manual, version, release, tag, title, ttyPa
 	c.Flags = append(c.Flags,
 		replace.BackupFlag,
 		replace.BackupExtensionFlag,
+		replace.BackupTemplateFlag,
+		replace.BackupDirFlag,
+		replace.BackupListFlag,
+		replace.BackupDiffFlag,
+		replace.BackupRestoreFlag,
+		replace.BackupPruneFlag,
+		replace.BackupGenerationFlag,
+		replace.BackupKeepFlag,
+		replace.BackupOlderThanFlag,
+		replace.BackupNoBackupFlag,
 		replace.IgnoreCaseFlag,
 		replace.PreserveCaseFlag,
+		replace.FoldFlag,
+		replace.NormalizeFlag,
+		replace.CasingFlag,
 		replace.NopFlag,
 		replace.NoLimitsFlag,
+		replace.MaxFileSizeFlag,
+		replace.MaxFilesFlag,
+		replace.TimeoutFlag,
+		replace.FileTimeoutFlag,
+		replace.ApplyPatchFlag,
+		replace.FuzzFlag,
+		replace.SearchFileFlag,
+		replace.ReplaceFileFlag,
+		replace.LooseWhitespaceFlag,
+		replace.DefineFlag,
+		replace.DecompressFlag,
+		replace.FilterFlag,
 
 		replace.ShowDiffFlag,
+		replace.PatchFormatFlag,
 		replace.InteractiveFlag,
 		replace.PauseFlag,
 
 		replace.RecurseFlag,
 		replace.AllFlag,
+		replace.ArchivesFlag,
 		replace.NullFlag,
 		replace.FileFlag,
 		replace.ExcludeFlag,
+		replace.ExcludeSetFlag,
 		replace.IncludeFlag,
+		replace.JSONPathFlag,
+		replace.YAMLPathFlag,
+		replace.TOMLPathFlag,
+		replace.MarkupFlag,
+		replace.MarkdownFlag,
 
 		replace.RegexFlag,
 		replace.MultiLineFlag,
@@ -131,6 +176,7 @@ func NewUI(name, usage, description, manual, version, release, tag, title, ttyPa
 		replace.HelpFlag,
 		replace.QuietFlag,
 		replace.VerboseFlag,
+		replace.NoConfigFlag,
 	)
 
 	clcli.ClearEmptyCategories(c.Flags)